@@ -3,11 +3,16 @@ package basics
 import (
 	"crypto/ecdsa"
 	"errors"
-	"log"
+	"fmt"
+	"os"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi-go-provider/infer"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
 
 	hdwallet "github.com/miguelmota/go-ethereum-hdwallet"
 )
@@ -24,14 +29,53 @@ import (
 // - WireDependencies: Control how outputs and secrets flows through values.
 type TwentySixAccount struct{}
 
+func (account *TwentySixAccount) Annotate(a infer.Annotator) {
+	a.Describe(&account, "An aleph account resolved from a private key, a mnemonic or a keystore, "+
+		"passed to the other resources to sign their messages. It has no remote state.")
+}
+
 // Each resource has an input struct, defining what arguments it accepts.
 type TwentySixAccountArgs struct {
 	// Fields projected into Pulumi must be public and hava a `pulumi:"..."` tag.
 	// The pulumi tag doesn't need to match the field name, but it's generally a
 	// good idea.
-	PrivateKey     string `pulumi:"privateKey,optional"`
-	Mnemonic       string `pulumi:"mnemonic,optional"`
+	PrivateKey     string `pulumi:"privateKey,optional" provider:"secret"`
+	Mnemonic       string `pulumi:"mnemonic,optional" provider:"secret"`
 	DerivationPath string `pulumi:"derivationPath,optional"`
+
+	// Names of environment variables of the provider process holding the private key or
+	// the mnemonic, read when privateKey and mnemonic are empty so that the key never
+	// appears in the program.
+	PrivateKeyEnv string `pulumi:"privateKeyEnv,optional"`
+	MnemonicEnv   string `pulumi:"mnemonicEnv,optional"`
+
+	// Encrypted keystore JSON (Web3 Secret Storage), exclusive with privateKey and mnemonic.
+	Keystore           string `pulumi:"keystore,optional" provider:"secret"`
+	KeystorePassphrase string `pulumi:"keystorePassphrase,optional" provider:"secret"`
+
+	// Chain the account signs its messages for, ETH by default. A SOL account takes a
+	// base58 privateKey, its address is the base58 public key.
+	Chain MessageChain `pulumi:"chain,optional"`
+}
+
+// chain returns the chain the account signs its messages for.
+func (args TwentySixAccountArgs) chain() MessageChain {
+	if args.Chain == "" {
+		return EthereumChain
+	}
+
+	return args.Chain
+}
+
+func (args *TwentySixAccountArgs) Annotate(a infer.Annotator) {
+	a.Describe(&args.PrivateKey, "Hex private key (0x followed by 32 bytes) of an ETH account, or base58 key of a SOL account.")
+	a.Describe(&args.Mnemonic, "BIP-39 mnemonic the ETH key is derived from.")
+	a.Describe(&args.DerivationPath, "Derivation path of the key in the mnemonic, m/44'/60'/0'/0/0 when empty.")
+	a.Describe(&args.PrivateKeyEnv, "Environment variable of the provider process holding the private key, read when privateKey is empty.")
+	a.Describe(&args.MnemonicEnv, "Environment variable of the provider process holding the mnemonic, read when mnemonic is empty.")
+	a.Describe(&args.Keystore, "Encrypted keystore JSON (Web3 Secret Storage), exclusive with privateKey and mnemonic.")
+	a.Describe(&args.KeystorePassphrase, "Passphrase decrypting the keystore.")
+	a.Describe(&args.Chain, "Chain the account signs its messages for, ETH or SOL. ETH when empty.")
 }
 
 // Each resource has a state, describing the fields that exist on the created resource.
@@ -41,6 +85,25 @@ type TwentySixAccountState struct {
 
 	Address   string `pulumi:"address"`
 	PublicKey string `pulumi:"publicKey"`
+
+	// Key derived from the mnemonic, decrypted from the keystore or read from the
+	// environment. It is kept out of privateKey, which would no longer match the inputs.
+	SigningKey string `pulumi:"signingKey,optional" provider:"secret"`
+}
+
+func (state *TwentySixAccountState) Annotate(a infer.Annotator) {
+	a.Describe(&state.Address, "Address of the account, the sender of its messages.")
+	a.Describe(&state.PublicKey, "Public key of the account.")
+	a.Describe(&state.SigningKey, "Private key the messages are signed with when it isn't the privateKey input.")
+}
+
+// signingKey returns the private key the messages of the account are signed with.
+func (state TwentySixAccountState) signingKey() string {
+	if len(state.SigningKey) > 0 {
+		return state.SigningKey
+	}
+
+	return state.PrivateKey
 }
 
 // All resources must implement Create at a minimum.
@@ -50,12 +113,45 @@ func (account TwentySixAccount) Create(ctx p.Context, name string, input TwentyS
 		return name, state, nil
 	}
 
-	if len(state.PrivateKey) > 0 {
-		privateKeyBytes, err := hexutil.Decode(input.PrivateKey)
+	// credentials read from the environment stay out of the recorded inputs
+	privateKey, mnemonic, err := input.resolveEnvCredentials()
+	if err != nil {
+		return "", TwentySixAccountState{}, err
+	}
+
+	if state.chain() == SolanaChain {
+		key, err := solanaKey(privateKey)
 		if err != nil {
-			return "", TwentySixAccountState{}, errors.New("error casting public key to bytes")
+			return "", TwentySixAccountState{}, err
 		}
 
+		state.Address = solanaAddress(key)
+		state.PublicKey = state.Address
+		state.SigningKey = envSigningKey(input.PrivateKey, privateKey)
+
+		return name, state, nil
+	}
+
+	if len(state.Keystore) > 0 {
+		key, err := keystore.DecryptKey([]byte(state.Keystore), state.KeystorePassphrase)
+		if err != nil {
+			return "", TwentySixAccountState{}, fmt.Errorf("error decrypting keystore: %w", err)
+		}
+
+		state.SigningKey = hexutil.Encode(crypto.FromECDSA(key.PrivateKey))
+		state.PublicKey = hexutil.Encode(crypto.FromECDSAPub(&key.PrivateKey.PublicKey))
+		state.Address = key.Address.Hex()
+
+		return name, state, nil
+	}
+
+	if len(privateKey) > 0 {
+		privateKeyBytes, err := decodePrivateKey(privateKey)
+		if err != nil {
+			return "", TwentySixAccountState{}, err
+		}
+		state.SigningKey = envSigningKey(input.PrivateKey, privateKey)
+
 		privateKey, err := crypto.ToECDSA(privateKeyBytes)
 		if err != nil {
 			return "", TwentySixAccountState{}, errors.New("error casting public key to ECDSA")
@@ -74,17 +170,22 @@ func (account TwentySixAccount) Create(ctx p.Context, name string, input TwentyS
 		return name, state, nil
 	}
 
-	if len(state.Mnemonic) > 0 {
-		wallet, err := hdwallet.NewFromMnemonic(state.Mnemonic)
+	if len(mnemonic) > 0 {
+		wallet, err := hdwallet.NewFromMnemonic(mnemonic)
 		if err != nil {
-			log.Fatal(err)
+			return "", TwentySixAccountState{}, fmt.Errorf("invalid mnemonic: %w", err)
 		}
 
-		if len(state.DerivationPath) == 0 {
-			state.DerivationPath = "m/44'/60'/0'/0/0"
+		derivationPath := state.DerivationPath
+		if len(derivationPath) == 0 {
+			derivationPath = "m/44'/60'/0'/0/0"
+		}
+
+		path, err := hdwallet.ParseDerivationPath(derivationPath)
+		if err != nil {
+			return "", TwentySixAccountState{}, fmt.Errorf("invalid derivation path: %w", err)
 		}
 
-		path := hdwallet.MustParseDerivationPath(state.DerivationPath)
 		account, err := wallet.Derive(path, true)
 		if err != nil {
 			return "", TwentySixAccountState{}, err
@@ -105,12 +206,134 @@ func (account TwentySixAccount) Create(ctx p.Context, name string, input TwentyS
 			return "", TwentySixAccountState{}, err
 		}
 
-		state.PrivateKey = hexutil.Encode(privateKey)
+		state.SigningKey = hexutil.Encode(privateKey)
 		state.PublicKey = hexutil.Encode(publicKey)
 		state.Address = address
 
 		return name, state, nil
 	}
 
-	return "", TwentySixAccountState{}, errors.New("no private key or mnemonic provided")
+	return "", TwentySixAccountState{}, errors.New("no private key, mnemonic or keystore provided")
+}
+
+// decodePrivateKey decodes an ETH private key, a 0x-prefixed 32-byte hex string.
+func decodePrivateKey(key string) ([]byte, error) {
+	if !strings.HasPrefix(key, "0x") || len(key) != 2+2*32 {
+		return nil, errors.New("invalid private key: expected 32-byte hex")
+	}
+
+	privateKeyBytes, err := hexutil.Decode(key)
+	if err != nil {
+		return nil, errors.New("invalid private key: expected 32-byte hex")
+	}
+
+	return privateKeyBytes, nil
+}
+
+// resolveEnvCredentials returns the private key and the mnemonic, read from their
+// environment variables when they aren't set directly.
+func (args TwentySixAccountArgs) resolveEnvCredentials() (string, string, error) {
+	privateKey := args.PrivateKey
+	if len(privateKey) == 0 && len(args.PrivateKeyEnv) > 0 {
+		value, err := lookupCredentialEnv(args.PrivateKeyEnv)
+		if err != nil {
+			return "", "", err
+		}
+		privateKey = value
+	}
+
+	mnemonic := args.Mnemonic
+	if len(mnemonic) == 0 && len(args.MnemonicEnv) > 0 {
+		value, err := lookupCredentialEnv(args.MnemonicEnv)
+		if err != nil {
+			return "", "", err
+		}
+		mnemonic = value
+	}
+
+	return privateKey, mnemonic, nil
+}
+
+// envSigningKey returns the private key read from the environment, empty when the
+// messages are signed with the privateKey input.
+func envSigningKey(inputKey string, privateKey string) string {
+	if privateKey == inputKey {
+		return ""
+	}
+
+	return privateKey
+}
+
+func lookupCredentialEnv(name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return "", fmt.Errorf("environment variable %s is empty", name)
+	}
+
+	return value, nil
+}
+
+func (account TwentySixAccount) Check(ctx p.Context, name string, oldInputs resource.PropertyMap, newInputs resource.PropertyMap) (TwentySixAccountArgs, []p.CheckFailure, error) {
+	args, failures, err := infer.DefaultCheck[TwentySixAccountArgs](newInputs)
+	if err != nil {
+		return args, failures, err
+	}
+
+	if len(args.PrivateKey) > 0 && len(args.PrivateKeyEnv) > 0 {
+		failures = append(failures, p.CheckFailure{
+			Property: "privateKeyEnv",
+			Reason:   "privateKeyEnv can't be set along with privateKey",
+		})
+	}
+
+	if len(args.Mnemonic) > 0 && len(args.MnemonicEnv) > 0 {
+		failures = append(failures, p.CheckFailure{
+			Property: "mnemonicEnv",
+			Reason:   "mnemonicEnv can't be set along with mnemonic",
+		})
+	}
+
+	switch args.Chain {
+	case "", EthereumChain:
+	case SolanaChain:
+		if len(args.Mnemonic) > 0 || len(args.MnemonicEnv) > 0 || len(args.Keystore) > 0 {
+			failures = append(failures, p.CheckFailure{
+				Property: "chain",
+				Reason:   "a SOL account only takes a privateKey",
+			})
+		}
+	default:
+		failures = append(failures, p.CheckFailure{
+			Property: "chain",
+			Reason:   fmt.Sprintf("invalid chain %q: expected %q or %q", args.Chain, EthereumChain, SolanaChain),
+		})
+	}
+
+	if len(args.Keystore) > 0 {
+		if len(args.PrivateKey) > 0 || len(args.Mnemonic) > 0 || len(args.PrivateKeyEnv) > 0 || len(args.MnemonicEnv) > 0 {
+			failures = append(failures, p.CheckFailure{
+				Property: "keystore",
+				Reason:   "keystore can't be set along with privateKey or mnemonic",
+			})
+		}
+
+		if len(args.KeystorePassphrase) == 0 {
+			failures = append(failures, p.CheckFailure{
+				Property: "keystorePassphrase",
+				Reason:   "keystorePassphrase is required to decrypt the keystore",
+			})
+		}
+	} else if len(args.KeystorePassphrase) > 0 {
+		failures = append(failures, p.CheckFailure{
+			Property: "keystorePassphrase",
+			Reason:   "keystorePassphrase is set without a keystore",
+		})
+	}
+
+	return args, failures, nil
 }
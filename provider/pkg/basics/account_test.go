@@ -0,0 +1,171 @@
+package basics
+
+import (
+	"crypto/ed25519"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/mr-tron/base58"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+func TestResolveEnvCredentials(t *testing.T) {
+	t.Setenv("TWENTYSIX_TEST_PRIVATE_KEY", " 0x01\n")
+
+	args := TwentySixAccountArgs{PrivateKeyEnv: "TWENTYSIX_TEST_PRIVATE_KEY"}
+	privateKey, _, err := args.resolveEnvCredentials()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if privateKey != "0x01" {
+		t.Fatalf("expected the private key from the environment, got %q", privateKey)
+	}
+	if args.PrivateKey != "" {
+		t.Fatal("the private key from the environment must not be written into the inputs")
+	}
+
+	args = TwentySixAccountArgs{PrivateKey: "0x02", PrivateKeyEnv: "TWENTYSIX_TEST_PRIVATE_KEY"}
+	if privateKey, _, err = args.resolveEnvCredentials(); err != nil {
+		t.Fatal(err)
+	}
+	if privateKey != "0x02" {
+		t.Fatalf("expected the direct private key to win, got %q", privateKey)
+	}
+
+	args = TwentySixAccountArgs{MnemonicEnv: "TWENTYSIX_TEST_UNSET_MNEMONIC"}
+	_, _, err = args.resolveEnvCredentials()
+	if err == nil || err.Error() != "environment variable TWENTYSIX_TEST_UNSET_MNEMONIC is not set" {
+		t.Fatalf("expected an unset variable error, got %v", err)
+	}
+}
+
+func TestCreateAccountFromEnv(t *testing.T) {
+	privateKey := "0x" + strings.Repeat("01", 32)
+	t.Setenv("TWENTYSIX_TEST_PRIVATE_KEY", privateKey)
+
+	args := TwentySixAccountArgs{PrivateKeyEnv: "TWENTYSIX_TEST_PRIVATE_KEY"}
+	_, state, err := TwentySixAccount{}.Create(nil, "env", args, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the recorded inputs must match the program, or the account is replaced on every update
+	if state.TwentySixAccountArgs != args {
+		t.Fatalf("the key from the environment must not be written into the inputs, got %+v", state.TwentySixAccountArgs)
+	}
+	if state.signingKey() != privateKey {
+		t.Fatalf("expected to sign with the key from the environment, got %q", state.signingKey())
+	}
+
+	_, direct, err := TwentySixAccount{}.Create(nil, "direct", TwentySixAccountArgs{PrivateKey: privateKey}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if direct.Address != state.Address || direct.SigningKey != "" {
+		t.Fatalf("expected the direct key to sign as %s, got %+v", state.Address, direct)
+	}
+}
+
+func TestCreateAccountFromMnemonic(t *testing.T) {
+	const mnemonic = "test test test test test test test test test test test junk"
+
+	args := TwentySixAccountArgs{Mnemonic: mnemonic}
+	_, state, err := TwentySixAccount{}.Create(nil, "mnemonic", args, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state.TwentySixAccountArgs != args {
+		t.Fatalf("the derived key and the default path must not be written into the inputs, got %+v", state.TwentySixAccountArgs)
+	}
+	if state.Address != "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266" {
+		t.Fatalf("unexpected address %s", state.Address)
+	}
+	if len(state.signingKey()) != 2+2*32 {
+		t.Fatalf("expected the derived key to sign, got %q", state.signingKey())
+	}
+}
+
+func TestCreateSolanaAccount(t *testing.T) {
+	seed := make([]byte, ed25519.SeedSize)
+	seed[0] = 26
+	args := TwentySixAccountArgs{Chain: SolanaChain, PrivateKey: base58.Encode(seed)}
+
+	_, state, err := TwentySixAccount{}.Create(nil, "sol", args, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := base58.Encode(ed25519.NewKeyFromSeed(seed).Public().(ed25519.PublicKey))
+	if state.Address != expected || state.PublicKey != expected {
+		t.Fatalf("expected the base58 public key %s as address, got %s", expected, state.Address)
+	}
+}
+
+func TestCreateAccountMalformedKey(t *testing.T) {
+	keys := []string{"0x", "02", "0x02", "0x" + strings.Repeat("zz", 32), strings.Repeat("01", 33)}
+	for i := 0; i < len(keys); i++ {
+		_, _, err := TwentySixAccount{}.Create(nil, "eth", TwentySixAccountArgs{PrivateKey: keys[i]}, false)
+		if err == nil || err.Error() != "invalid private key: expected 32-byte hex" {
+			t.Fatalf("%q: expected an invalid private key error, got %v", keys[i], err)
+		}
+	}
+
+	_, state, err := TwentySixAccount{}.Create(nil, "eth", TwentySixAccountArgs{PrivateKey: "0x" + strings.Repeat("01", 32)}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Address == "" {
+		t.Fatal("expected the address of the key")
+	}
+}
+
+func TestCreateAccountMalformedMnemonic(t *testing.T) {
+	_, _, err := TwentySixAccount{}.Create(nil, "eth", TwentySixAccountArgs{Mnemonic: "not a valid mnemonic"}, false)
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid mnemonic") {
+		t.Fatalf("expected an invalid mnemonic error, got %v", err)
+	}
+
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	_, _, err = TwentySixAccount{}.Create(nil, "eth", TwentySixAccountArgs{Mnemonic: mnemonic, DerivationPath: "m/x"}, false)
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid derivation path") {
+		t.Fatalf("expected an invalid derivation path error, got %v", err)
+	}
+}
+
+func TestCreateAccountFromKeystore(t *testing.T) {
+	privateKey := "0x" + strings.Repeat("01", 32)
+	key, err := crypto.HexToECDSA(privateKey[2:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyJson, err := keystore.EncryptKey(&keystore.Key{Address: crypto.PubkeyToAddress(key.PublicKey), PrivateKey: key}, "secret", keystore.LightScryptN, keystore.LightScryptP)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args := TwentySixAccountArgs{Keystore: string(keyJson), KeystorePassphrase: "secret"}
+	_, state, err := TwentySixAccount{}.Create(nil, "keystore", args, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state.Address != crypto.PubkeyToAddress(key.PublicKey).Hex() {
+		t.Fatalf("unexpected address %s", state.Address)
+	}
+	if state.signingKey() != privateKey {
+		t.Fatalf("expected to sign with the decrypted key, got %q", state.signingKey())
+	}
+	if state.TwentySixAccountArgs != args {
+		t.Fatal("the decrypted key must not be written into the inputs")
+	}
+
+	// the inputs recorded in the state are checked again on the next deployment
+	_, failures, err := TwentySixAccount{}.Check(nil, "keystore", nil, resource.NewPropertyMap(state.TwentySixAccountArgs))
+	if err != nil || len(failures) != 0 {
+		t.Fatalf("expected the recorded inputs to pass Check, got %v %v", failures, err)
+	}
+}
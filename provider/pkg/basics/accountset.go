@@ -0,0 +1,143 @@
+package basics
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi-go-provider/infer"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+	hdwallet "github.com/miguelmota/go-ethereum-hdwallet"
+)
+
+// TwentySixAccountSet derives several accounts from a single mnemonic.
+type TwentySixAccountSet struct{}
+
+type TwentySixAccountSetArgs struct {
+	Mnemonic string `pulumi:"mnemonic" provider:"secret"`
+
+	// Explicit derivation paths, derived along with the count paths below.
+	DerivationPaths []string `pulumi:"derivationPaths,optional"`
+
+	// Derive basePath+start up to basePath+(start+count-1), basePath defaults to m/44'/60'/0'/0/.
+	BasePath string `pulumi:"basePath,optional"`
+	Start    int    `pulumi:"start,optional"`
+	Count    int    `pulumi:"count,optional"`
+
+	// Export the private keys of the derived accounts, as secrets.
+	ExportPrivateKeys bool `pulumi:"exportPrivateKeys,optional"`
+}
+
+type TwentySixAccountSetEntry struct {
+	Address    string `pulumi:"address"`
+	PublicKey  string `pulumi:"publicKey"`
+	PrivateKey string `pulumi:"privateKey,optional" provider:"secret"`
+}
+
+type TwentySixAccountSetState struct {
+	TwentySixAccountSetArgs
+
+	// Derived accounts keyed by derivation path.
+	Accounts map[string]TwentySixAccountSetEntry `pulumi:"accounts"`
+}
+
+const defaultDerivationBasePath = "m/44'/60'/0'/0/"
+
+// derivationPaths lists the explicit paths followed by the paths of the range.
+func (args TwentySixAccountSetArgs) derivationPaths() []string {
+	paths := append([]string{}, args.DerivationPaths...)
+
+	basePath := args.BasePath
+	if basePath == "" {
+		basePath = defaultDerivationBasePath
+	}
+
+	for i := args.Start; i < args.Start+args.Count; i++ {
+		paths = append(paths, fmt.Sprintf("%s%d", basePath, i))
+	}
+
+	return paths
+}
+
+func (accountSet TwentySixAccountSet) Create(ctx p.Context, name string, input TwentySixAccountSetArgs, preview bool) (string, TwentySixAccountSetState, error) {
+	state := TwentySixAccountSetState{TwentySixAccountSetArgs: input}
+	if preview {
+		return name, state, nil
+	}
+
+	wallet, err := hdwallet.NewFromMnemonic(input.Mnemonic)
+	if err != nil {
+		return "", TwentySixAccountSetState{}, err
+	}
+
+	state.Accounts = map[string]TwentySixAccountSetEntry{}
+	for _, derivationPath := range input.derivationPaths() {
+		path, err := hdwallet.ParseDerivationPath(derivationPath)
+		if err != nil {
+			return "", TwentySixAccountSetState{}, fmt.Errorf("invalid derivation path %q: %w", derivationPath, err)
+		}
+
+		account, err := wallet.Derive(path, false)
+		if err != nil {
+			return "", TwentySixAccountSetState{}, err
+		}
+
+		publicKey, err := wallet.PublicKeyBytes(account)
+		if err != nil {
+			return "", TwentySixAccountSetState{}, err
+		}
+
+		entry := TwentySixAccountSetEntry{
+			Address:   account.Address.Hex(),
+			PublicKey: hexutil.Encode(publicKey),
+		}
+
+		if input.ExportPrivateKeys {
+			privateKey, err := wallet.PrivateKeyBytes(account)
+			if err != nil {
+				return "", TwentySixAccountSetState{}, err
+			}
+			entry.PrivateKey = hexutil.Encode(privateKey)
+		}
+
+		state.Accounts[derivationPath] = entry
+	}
+
+	return name, state, nil
+}
+
+func (accountSet TwentySixAccountSet) Check(ctx p.Context, name string, oldInputs resource.PropertyMap, newInputs resource.PropertyMap) (TwentySixAccountSetArgs, []p.CheckFailure, error) {
+	args, failures, err := infer.DefaultCheck[TwentySixAccountSetArgs](newInputs)
+	if err != nil {
+		return args, failures, err
+	}
+
+	if args.Start < 0 || args.Count < 0 {
+		failures = append(failures, p.CheckFailure{
+			Property: "count",
+			Reason:   "start and count can't be negative",
+		})
+	}
+
+	paths := args.derivationPaths()
+	if len(paths) == 0 {
+		failures = append(failures, p.CheckFailure{
+			Property: "derivationPaths",
+			Reason:   "no derivation path: set derivationPaths or count",
+		})
+	}
+
+	seen := map[string]bool{}
+	for _, path := range paths {
+		if seen[path] {
+			failures = append(failures, p.CheckFailure{
+				Property: "derivationPaths",
+				Reason:   fmt.Sprintf("derivation path %q is listed twice", path),
+			})
+		}
+		seen[path] = true
+	}
+
+	return args, failures, nil
+}
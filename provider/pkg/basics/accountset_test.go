@@ -0,0 +1,32 @@
+package basics
+
+import "testing"
+
+func TestAccountSetDerivesRange(t *testing.T) {
+	args := TwentySixAccountSetArgs{
+		Mnemonic: "test test test test test test test test test test test junk",
+		Count:    2,
+	}
+
+	_, state, err := TwentySixAccountSet{}.Create(nil, "set", args, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]string{
+		"m/44'/60'/0'/0/0": "0xf39Fd6e51aad88F6F4ce6aB8827279cffFb92266",
+		"m/44'/60'/0'/0/1": "0x70997970C51812dc3A010C7d01b50e0d17dc79C8",
+	}
+	if len(state.Accounts) != len(expected) {
+		t.Fatalf("expected %d accounts, got %d", len(expected), len(state.Accounts))
+	}
+	for path, address := range expected {
+		entry := state.Accounts[path]
+		if entry.Address != address {
+			t.Errorf("%s: expected address %s, got %s", path, address, entry.Address)
+		}
+		if entry.PrivateKey != "" {
+			t.Errorf("%s: private key exported without exportPrivateKeys", path)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package basics
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+)
+
+// ErrAggregateNotFound is returned when the address has no content under the key.
+var ErrAggregateNotFound = errors.New("aggregate not found")
+
+type GetAggregateResponse struct {
+	Address string                            `json:"address"`
+	Data    map[string]map[string]interface{} `json:"data"`
+}
+
+// GetAggregate fetches the current, merged content of an aggregate key.
+func (client *TwentySixClient) GetAggregate(address string, key string) (map[string]interface{}, error) {
+	params := url.Values{}
+	params.Add("keys", key)
+
+	request, err := http.NewRequest("GET", AlephApiUrl+client.apiPath("/aggregates/")+address+".json?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Add("Accept", "application/json")
+
+	response, err := client.http.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return nil, ErrAggregateNotFound
+	}
+
+	resultBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result GetAggregateResponse
+	if err := json.Unmarshal(resultBody, &result); err != nil {
+		return nil, err
+	}
+
+	content, ok := result.Data[key]
+	if !ok {
+		return nil, ErrAggregateNotFound
+	}
+
+	return content, nil
+}
+
+// trackedAggregateContent keeps the entries of the merged content the resource set.
+// Other writers of the key may hold the other ones, which must not be cleared.
+func trackedAggregateContent(content map[string]interface{}, tracked map[string]interface{}) map[string]interface{} {
+	kept := map[string]interface{}{}
+	for key := range tracked {
+		if value, ok := content[key]; ok {
+			kept[key] = value
+		}
+	}
+
+	return kept
+}
+
+// normalizeAggregateContent round trips the content through JSON so values compare the same
+// whatever their Go representation (e.g. int and float64 numbers, typed and untyped maps).
+func normalizeAggregateContent(content map[string]interface{}) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+
+	normalized := map[string]interface{}{}
+	if err := json.Unmarshal(encoded, &normalized); err != nil {
+		return nil, err
+	}
+
+	return normalized, nil
+}
+
+// AggregateContentDiff lists the top level keys of an aggregate that changed.
+type AggregateContentDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+func (diff AggregateContentDiff) HasChanges() bool {
+	return len(diff.Added) > 0 || len(diff.Removed) > 0 || len(diff.Changed) > 0
+}
+
+// diffAggregateContent compares two aggregate contents structurally, key order and
+// number representation don't matter.
+func diffAggregateContent(olds map[string]interface{}, news map[string]interface{}) (AggregateContentDiff, error) {
+	oldContent, err := normalizeAggregateContent(olds)
+	if err != nil {
+		return AggregateContentDiff{}, err
+	}
+
+	newContent, err := normalizeAggregateContent(news)
+	if err != nil {
+		return AggregateContentDiff{}, err
+	}
+
+	diff := AggregateContentDiff{Added: []string{}, Removed: []string{}, Changed: []string{}}
+	for key, newValue := range newContent {
+		oldValue, ok := oldContent[key]
+		if !ok {
+			diff.Added = append(diff.Added, key)
+		} else if !reflect.DeepEqual(oldValue, newValue) {
+			diff.Changed = append(diff.Changed, key)
+		}
+	}
+
+	for key := range oldContent {
+		if _, ok := newContent[key]; !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+
+	return diff, nil
+}
+
+// aggregateUpdateContent is the partial content to publish for a diff. Aleph merges the top
+// level keys of aggregate messages, so only added and changed keys are sent and removed keys
+// are cleared with a null value.
+func aggregateUpdateContent(news map[string]interface{}, diff AggregateContentDiff) map[string]interface{} {
+	content := map[string]interface{}{}
+	for _, key := range diff.Added {
+		content[key] = news[key]
+	}
+
+	for _, key := range diff.Changed {
+		content[key] = news[key]
+	}
+
+	for _, key := range diff.Removed {
+		content[key] = nil
+	}
+
+	return content
+}
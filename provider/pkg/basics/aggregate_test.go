@@ -0,0 +1,144 @@
+package basics
+
+import (
+	"reflect"
+	"testing"
+
+	p "github.com/pulumi/pulumi-go-provider"
+)
+
+func TestDiffAggregateContentIgnoresRepresentation(t *testing.T) {
+	olds := map[string]interface{}{
+		"replicas": 3,
+		"settings": map[string]interface{}{"b": true, "a": "x"},
+	}
+	news := map[string]interface{}{
+		"settings": map[string]interface{}{"a": "x", "b": true},
+		"replicas": float64(3),
+	}
+
+	diff, err := diffAggregateContent(olds, news)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff.HasChanges() {
+		t.Fatalf("expected no changes, got %+v", diff)
+	}
+}
+
+func TestDiffAggregateContentChanges(t *testing.T) {
+	olds := map[string]interface{}{
+		"kept":    "same",
+		"removed": "gone",
+		"changed": map[string]interface{}{"value": 1},
+	}
+	news := map[string]interface{}{
+		"kept":    "same",
+		"added":   []interface{}{"new"},
+		"changed": map[string]interface{}{"value": 2},
+	}
+
+	diff, err := diffAggregateContent(olds, news)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := AggregateContentDiff{
+		Added:   []string{"added"},
+		Removed: []string{"removed"},
+		Changed: []string{"changed"},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, diff)
+	}
+
+	content := aggregateUpdateContent(news, diff)
+	if len(content) != 3 {
+		t.Fatalf("expected 3 keys in the partial update, got %d", len(content))
+	}
+	if value, ok := content["removed"]; !ok || value != nil {
+		t.Fatalf("expected removed key to be cleared, got %v", value)
+	}
+	if _, ok := content["kept"]; ok {
+		t.Fatal("unchanged keys must not be published")
+	}
+}
+
+func TestAggregateRevisions(t *testing.T) {
+	messages := []Message{
+		{ItemHash: "second", ItemContent: `{"key":"config","time":2,"content":{"replicas":3,"debug":null}}`},
+		{ItemHash: "other", ItemContent: `{"key":"other","time":1,"content":{"ignored":true}}`},
+		{ItemHash: "first", ItemContent: `{"key":"config","time":1,"content":{"replicas":2,"debug":true}}`},
+	}
+
+	revisions, err := aggregateRevisions(messages, "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(revisions) != 2 || revisions[0].MessageHash != "first" || revisions[1].MessageHash != "second" {
+		t.Fatalf("expected the two revisions of the key oldest first, got %+v", revisions)
+	}
+	if revisions[0].Content != `{"debug":true,"replicas":2}` {
+		t.Fatalf("unexpected first content %s", revisions[0].Content)
+	}
+	if revisions[1].Content != `{"replicas":3}` {
+		t.Fatalf("expected the null value to remove the key, got %s", revisions[1].Content)
+	}
+}
+
+func TestAggregateDiff(t *testing.T) {
+	olds := TwentySixAggregateState{TwentySixAggregateArgs: TwentySixAggregateArgs{
+		Channel: "TEST",
+		Key:     "profile",
+		Content: map[string]interface{}{"name": "before", "age": 1},
+	}}
+
+	news := olds.TwentySixAggregateArgs
+	news.Content = map[string]interface{}{"name": "before", "age": float64(1)}
+	response, err := TwentySixAggregate{}.Diff(nil, "profile", olds, news)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.HasChanges {
+		t.Fatalf("expected no changes, got %v", response.DetailedDiff)
+	}
+
+	news.Content = map[string]interface{}{"name": "after"}
+	response, err = TwentySixAggregate{}.Diff(nil, "profile", olds, news)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !response.HasChanges || response.DeleteBeforeReplace {
+		t.Fatalf("expected a content change to update the aggregate, got %v", response.DetailedDiff)
+	}
+
+	news.Key = "settings"
+	response, err = TwentySixAggregate{}.Diff(nil, "profile", olds, news)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kind := response.DetailedDiff["key"].Kind; kind != p.UpdateReplace {
+		t.Fatalf("expected a key change to replace the aggregate, got %q", kind)
+	}
+}
+
+func TestTrackedAggregateContent(t *testing.T) {
+	merged := map[string]interface{}{"name": "refreshed", "owner": "someone else"}
+	tracked := map[string]interface{}{"name": "before", "age": 1}
+
+	kept := trackedAggregateContent(merged, tracked)
+	if !reflect.DeepEqual(kept, map[string]interface{}{"name": "refreshed"}) {
+		t.Fatalf("expected only the tracked keys, got %v", kept)
+	}
+
+	// the keys of other writers must not be cleared by the next update
+	contentDiff, err := diffAggregateContent(kept, tracked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	update := aggregateUpdateContent(tracked, contentDiff)
+	if _, ok := update["owner"]; ok || len(update) != 2 {
+		t.Fatalf("expected an update of the tracked keys only, got %v", update)
+	}
+}
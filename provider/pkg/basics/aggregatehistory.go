@@ -0,0 +1,116 @@
+package basics
+
+import (
+	"encoding/json"
+	"sort"
+
+	p "github.com/pulumi/pulumi-go-provider"
+)
+
+type aggregateMessageContent struct {
+	Address string                 `json:"address"`
+	Key     string                 `json:"key"`
+	Content map[string]interface{} `json:"content"`
+	Time    float64                `json:"time"`
+}
+
+// TwentySixAggregateRevision is an AGGREGATE message of a key: the top level keys it
+// set, null for the removed ones, and the content of the aggregate once merged.
+// Both are JSON encoded.
+type TwentySixAggregateRevision struct {
+	MessageHash string  `pulumi:"messageHash"`
+	Time        float64 `pulumi:"time"`
+	Changes     string  `pulumi:"changes"`
+	Content     string  `pulumi:"content"`
+}
+
+// GetAggregateHistory returns the revisions of an aggregate key, oldest first, by
+// replaying the AGGREGATE messages of the address.
+func (client *TwentySixClient) GetAggregateHistory(address string, key string) ([]TwentySixAggregateRevision, error) {
+	messages, err := client.GetAllMessages(client.ctx, MessageFilter{
+		Addresses:    []string{address},
+		MessageTypes: []MessageType{AggregateMessageType},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return aggregateRevisions(messages, key)
+}
+
+// aggregateRevisions merges the contents of the messages of the key in time order, as
+// aleph merges the top level keys of an aggregate.
+func aggregateRevisions(messages []Message, key string) ([]TwentySixAggregateRevision, error) {
+	type revision struct {
+		hash    string
+		content aggregateMessageContent
+	}
+
+	revisions := []revision{}
+	for i := 0; i < len(messages); i++ {
+		var content aggregateMessageContent
+		if err := json.Unmarshal(messageContent(messages[i]), &content); err != nil || content.Key != key {
+			continue
+		}
+
+		revisions = append(revisions, revision{hash: string(messages[i].ItemHash), content: content})
+	}
+
+	sort.SliceStable(revisions, func(i, j int) bool {
+		return revisions[i].content.Time < revisions[j].content.Time
+	})
+
+	history := []TwentySixAggregateRevision{}
+	merged := map[string]interface{}{}
+	for i := 0; i < len(revisions); i++ {
+		for field, value := range revisions[i].content.Content {
+			if value == nil {
+				delete(merged, field)
+			} else {
+				merged[field] = value
+			}
+		}
+
+		changes, err := json.Marshal(revisions[i].content.Content)
+		if err != nil {
+			return nil, err
+		}
+
+		content, err := json.Marshal(merged)
+		if err != nil {
+			return nil, err
+		}
+
+		history = append(history, TwentySixAggregateRevision{
+			MessageHash: revisions[i].hash,
+			Time:        revisions[i].content.Time,
+			Changes:     string(changes),
+			Content:     string(content),
+		})
+	}
+
+	return history, nil
+}
+
+// GetAggregateHistory is a provider function listing how an aggregate key changed.
+type GetAggregateHistory struct{}
+
+type GetAggregateHistoryArgs struct {
+	Address string `pulumi:"address"`
+	Key     string `pulumi:"key"`
+}
+
+type GetAggregateHistoryResult struct {
+	Revisions []TwentySixAggregateRevision `pulumi:"revisions"`
+}
+
+func (GetAggregateHistory) Call(ctx p.Context, args GetAggregateHistoryArgs) (GetAggregateHistoryResult, error) {
+	client := NewConfiguredClient(ctx, TwentySixAccountState{}, "")
+
+	revisions, err := client.GetAggregateHistory(args.Address, args.Key)
+	if err != nil {
+		return GetAggregateHistoryResult{}, err
+	}
+
+	return GetAggregateHistoryResult{Revisions: revisions}, nil
+}
@@ -0,0 +1,226 @@
+package basics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	p "github.com/pulumi/pulumi-go-provider"
+)
+
+// TwentySixAggregate manages the content of an aggregate key of the account. Aleph merges
+// the top level keys of the aggregate messages of a key, so an update only publishes the
+// keys which changed, and a delete clears the managed keys.
+type TwentySixAggregate struct{}
+
+type TwentySixAggregateArgs struct {
+	Account TwentySixAccountState `pulumi:"account"`
+	Channel string                `pulumi:"channel"`
+
+	Key     string                 `pulumi:"key"`
+	Content map[string]interface{} `pulumi:"content"`
+}
+
+type TwentySixAggregateState struct {
+	TwentySixAggregateArgs
+
+	// Hash of the last message published for the key.
+	MessageHash string `pulumi:"messageHash"`
+}
+
+// CreateAggregate publishes an aggregate message merging the content into the key.
+func (client *TwentySixClient) CreateAggregate(key string, content map[string]interface{}) (Message, MessageResponse, error) {
+	now := client.now()
+
+	aggregateMessage := AggregateMessageContent{
+		Key:     key,
+		Address: client.account.Address,
+		Time:    now,
+		Content: content,
+	}
+
+	jsonItem, err := marshalItemContent(aggregateMessage)
+	if err != nil {
+		return Message{}, MessageResponse{}, err
+	}
+
+	message := Message{
+		Chain:       client.account.chain(),
+		Sender:      client.account.Address,
+		Channel:     client.channel,
+		Time:        now,
+		Type:        AggregateMessageType,
+		ItemType:    InlineMessageItem,
+		ItemHash:    itemHash(jsonItem),
+		ItemContent: string(jsonItem),
+	}
+
+	if err := client.offloadItemContent(&message); err != nil {
+		return Message{}, MessageResponse{}, err
+	}
+
+	message.SignMessage(client.account.signingKey())
+
+	if err := client.ValidateMessage(message); err != nil {
+		return Message{}, MessageResponse{}, err
+	}
+
+	messageJSON, err := json.Marshal(BroadcastRequest{Sync: false, Message: message})
+	if err != nil {
+		return Message{}, MessageResponse{}, err
+	}
+
+	if err := checkMessageSize(message); err != nil {
+		return Message{}, MessageResponse{}, err
+	}
+
+	request, err := http.NewRequest("POST", AlephApiUrl+client.apiPath("/messages"), bytes.NewBuffer(messageJSON))
+	if err != nil {
+		return Message{}, MessageResponse{}, err
+	}
+
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Accept", "application/json")
+
+	response, err := client.http.Do(request)
+	if err != nil {
+		return Message{}, MessageResponse{}, err
+	}
+
+	defer response.Body.Close()
+
+	resultBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return Message{}, MessageResponse{}, err
+	}
+
+	var aggregateResponse MessageResponse
+	if err := json.Unmarshal(resultBody, &aggregateResponse); err != nil {
+		return Message{}, MessageResponse{}, err
+	}
+
+	if err := aggregateResponse.publicationError("aggregate " + key); err != nil {
+		return Message{}, MessageResponse{}, err
+	}
+
+	return message, aggregateResponse, nil
+}
+
+// publishAggregate sends the content of the aggregate and returns the hash of its message.
+func publishAggregate(client *TwentySixClient, key string, content map[string]interface{}) (string, error) {
+	message, _, err := client.CreateAggregate(key, content)
+	if err != nil {
+		return "", err
+	}
+
+	return message.ItemHash, nil
+}
+
+func (aggregate TwentySixAggregate) Create(ctx p.Context, name string, input TwentySixAggregateArgs, preview bool) (string, TwentySixAggregateState, error) {
+	ctx, cancel := startOperation(ctx, "creating aggregate "+name)
+	defer cancel()
+
+	state := TwentySixAggregateState{TwentySixAggregateArgs: input}
+	if preview {
+		return name, state, nil
+	}
+
+	client := NewConfiguredClient(ctx, input.Account, input.Channel)
+	hash, err := publishAggregate(&client, input.Key, input.Content)
+	if err != nil {
+		return "", TwentySixAggregateState{}, err
+	}
+
+	state.MessageHash = hash
+
+	return name, state, nil
+}
+
+func (aggregate TwentySixAggregate) Diff(ctx p.Context, name string, olds TwentySixAggregateState, news TwentySixAggregateArgs) (p.DiffResponse, error) {
+	contentDiff, err := diffAggregateContent(olds.Content, news.Content)
+	if err != nil {
+		return p.DiffResponse{}, err
+	}
+
+	// the content is compared structurally, the other inputs select the aggregate
+	oldArgs := olds.TwentySixAggregateArgs
+	oldArgs.Content = nil
+	newArgs := news
+	newArgs.Content = nil
+
+	diff := diffArgs(oldArgs, newArgs)
+	if contentDiff.HasChanges() {
+		diff["content"] = p.PropertyDiff{Kind: p.Update, InputDiff: true}
+	}
+
+	return diffResponse(diff), nil
+}
+
+func (aggregate TwentySixAggregate) Update(ctx p.Context, name string, olds TwentySixAggregateState, news TwentySixAggregateArgs, preview bool) (TwentySixAggregateState, error) {
+	ctx, cancel := startOperation(ctx, "updating aggregate "+name)
+	defer cancel()
+
+	state := olds
+	state.TwentySixAggregateArgs = news
+
+	contentDiff, err := diffAggregateContent(olds.Content, news.Content)
+	if err != nil {
+		return TwentySixAggregateState{}, err
+	}
+
+	if preview || !contentDiff.HasChanges() {
+		return state, nil
+	}
+
+	client := NewConfiguredClient(ctx, news.Account, news.Channel)
+	hash, err := publishAggregate(&client, news.Key, aggregateUpdateContent(news.Content, contentDiff))
+	if err != nil {
+		return TwentySixAggregateState{}, err
+	}
+
+	state.MessageHash = hash
+
+	return state, nil
+}
+
+func (aggregate TwentySixAggregate) Read(ctx p.Context, id string, inputs TwentySixAggregateArgs, state TwentySixAggregateState) (string, TwentySixAggregateArgs, TwentySixAggregateState, error) {
+	ctx, cancel := startOperation(ctx, "reading aggregate "+id)
+	defer cancel()
+
+	client := NewConfiguredClient(ctx, state.Account, state.Channel)
+	content, err := client.GetAggregate(state.Account.Address, state.Key)
+	if err != nil {
+		if errors.Is(err, ErrAggregateNotFound) {
+			return "", TwentySixAggregateArgs{}, TwentySixAggregateState{}, nil
+		}
+		return "", TwentySixAggregateArgs{}, TwentySixAggregateState{}, err
+	}
+
+	state.Content = trackedAggregateContent(content, state.Content)
+
+	return id, inputs, state, nil
+}
+
+func (aggregate TwentySixAggregate) Delete(ctx p.Context, name string, olds TwentySixAggregateState) error {
+	ctx, cancel := startOperation(ctx, "deleting aggregate "+name)
+	defer cancel()
+
+	contentDiff, err := diffAggregateContent(olds.Content, map[string]interface{}{})
+	if err != nil {
+		return err
+	}
+
+	if !contentDiff.HasChanges() {
+		return nil
+	}
+
+	client := NewConfiguredClient(ctx, olds.Account, olds.Channel)
+	if _, err := publishAggregate(&client, olds.Key, aggregateUpdateContent(nil, contentDiff)); err != nil {
+		return fmt.Errorf("clearing aggregate %s: %w", olds.Key, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,139 @@
+package basics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	DefaultVerifyBootTimeout  int64 = 300
+	DefaultVerifyBootInterval int64 = 10
+
+	verifyBootDialTimeout = 10 * time.Second
+)
+
+// TwentySixInstanceVerifyBoot makes Create wait for the VM to accept SSH connections,
+// and optionally to run a health command successfully, before returning.
+type TwentySixInstanceVerifyBoot struct {
+	// Seconds to wait for the VM once allocated, and between two attempts. Default
+	// to 300 and 10.
+	Timeout  int64 `pulumi:"timeout,optional"`
+	Interval int64 `pulumi:"interval,optional"`
+
+	// Command run over SSH as user, root by default, with the private key of one of
+	// the authorized keys. The VM is up once it exits with status 0.
+	HealthCommand string `pulumi:"healthCommand,optional"`
+	User          string `pulumi:"user,optional"`
+	PrivateKey    string `pulumi:"privateKey,optional" provider:"secret"`
+}
+
+func (options TwentySixInstanceVerifyBoot) waitOptions() WaitOptions {
+	timeout := options.Timeout
+	if timeout == 0 {
+		timeout = DefaultVerifyBootTimeout
+	}
+
+	interval := options.Interval
+	if interval == 0 {
+		interval = DefaultVerifyBootInterval
+	}
+
+	return secondsWaitOptions(timeout, interval)
+}
+
+func (options TwentySixInstanceVerifyBoot) check() []p.CheckFailure {
+	failures := []p.CheckFailure{}
+
+	if options.Timeout < 0 || options.Interval < 0 {
+		failures = append(failures, p.CheckFailure{
+			Property: "verifyBoot",
+			Reason:   "timeout and interval can't be negative",
+		})
+	}
+
+	if options.HealthCommand != "" {
+		if _, err := ssh.ParsePrivateKey([]byte(options.PrivateKey)); err != nil {
+			failures = append(failures, p.CheckFailure{
+				Property: "verifyBoot.privateKey",
+				Reason:   "healthCommand requires the private key of an authorized key: " + err.Error(),
+			})
+		}
+	}
+
+	return failures
+}
+
+// VerifyBoot waits until the allocated VM accepts SSH connections and, when set,
+// runs the health command successfully.
+func (client *TwentySixClient) VerifyBoot(allocation SchedulerAllocation, options TwentySixInstanceVerifyBoot) error {
+	address := vmAddress(allocation)
+	if address == "" {
+		return fmt.Errorf("vm %s has no ipv6 address to verify its boot", allocation.VmHash)
+	}
+	address = net.JoinHostPort(address, "22")
+
+	var lastErr error
+	err := waitUntil(client.ctx, options.waitOptions(), func() (bool, error) {
+		lastErr = checkBoot(address, options)
+		return lastErr == nil, nil
+	})
+	if errors.Is(err, errWaitTimeout) {
+		return fmt.Errorf("vm %s didn't boot: %w", allocation.VmHash, lastErr)
+	}
+
+	return err
+}
+
+func checkBoot(address string, options TwentySixInstanceVerifyBoot) error {
+	if options.HealthCommand == "" {
+		conn, err := net.DialTimeout("tcp", address, verifyBootDialTimeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	signer, err := ssh.ParsePrivateKey([]byte(options.PrivateKey))
+	if err != nil {
+		return err
+	}
+
+	user := options.User
+	if user == "" {
+		user = "root"
+	}
+
+	// the host key isn't known before the first boot, it is read once the VM is up
+	sshClient, err := ssh.Dial("tcp", address, &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         verifyBootDialTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	session, err := sshClient.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	var output bytes.Buffer
+	session.Stdout = &output
+	session.Stderr = &output
+
+	if err := session.Run(options.HealthCommand); err != nil {
+		return fmt.Errorf("health command failed: %w: %s", err, output.String())
+	}
+
+	return nil
+}
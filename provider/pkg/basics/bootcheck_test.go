@@ -0,0 +1,39 @@
+package basics
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyBootReachable(t *testing.T) {
+	listener, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skip("ipv6 loopback unavailable:", err)
+	}
+	defer listener.Close()
+
+	if err := checkBoot(listener.Addr().String(), TwentySixInstanceVerifyBoot{}); err != nil {
+		t.Fatalf("expected the listening port to be reachable: %v", err)
+	}
+}
+
+func TestVerifyBootTimeout(t *testing.T) {
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+
+	if conn, err := net.Dial("tcp", "[::1]:22"); err == nil {
+		conn.Close()
+		t.Skip("an ssh server listens on the loopback address")
+	}
+
+	options := TwentySixInstanceVerifyBoot{Timeout: 1, Interval: 1}
+	start := time.Now()
+	err := client.VerifyBoot(SchedulerAllocation{VmHash: "vm", VmIPV6: "::1/128"}, options)
+	if err == nil || !strings.HasPrefix(err.Error(), "vm vm didn't boot") {
+		t.Fatalf("expected a boot timeout, got %v", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Fatal("expected the wait to honor the timeout")
+	}
+}
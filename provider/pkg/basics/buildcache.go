@@ -0,0 +1,73 @@
+package basics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+// buildSlots bounds the number of squashfs images built at the same time by the
+// provider process, whatever the number of volumes Pulumi creates in parallel.
+var buildSlots = make(chan struct{}, runtime.NumCPU())
+
+func setMaxConcurrentBuilds(builds int) {
+	buildSlots = make(chan struct{}, builds)
+}
+
+// buildCacheKey addresses an image by the content of its folder and the options it
+// is packed with.
+func buildCacheKey(folderHash string, options squashfsOptions) string {
+	hash := sha256.Sum256([]byte(folderHash + "\n" + options.cacheKey()))
+	return hex.EncodeToString(hash[:])
+}
+
+// buildVolumeImage packs the folder into a squashfs image and returns its path, along
+// with a cleanup removing it once uploaded. With a cache directory the image is kept
+// there under its content key, and an identical folder packed again is a cache hit.
+func buildVolumeImage(cacheDir string, folder string, folderHash string, options squashfsOptions) (string, func(), error) {
+	if cacheDir == "" {
+		imagePath := squashfsTempPath()
+		if err := buildSquashfsBounded(folder, imagePath, options); err != nil {
+			os.Remove(imagePath)
+			return "", nil, err
+		}
+
+		return imagePath, func() { os.Remove(imagePath) }, nil
+	}
+
+	imagePath := filepath.Join(cacheDir, buildCacheKey(folderHash, options)+squashfsTempSuffix)
+	if _, err := os.Stat(imagePath); err == nil {
+		return imagePath, func() {}, nil
+	}
+
+	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+		return "", nil, err
+	}
+
+	// build next to the cached image and rename it, so that a concurrent or crashed
+	// build never leaves a partial image under the content key
+	buildPath := imagePath + fmt.Sprintf(".%d.tmp", time.Now().UnixNano())
+	if err := buildSquashfsBounded(folder, buildPath, options); err != nil {
+		os.Remove(buildPath)
+		return "", nil, err
+	}
+
+	if err := os.Rename(buildPath, imagePath); err != nil {
+		os.Remove(buildPath)
+		return "", nil, err
+	}
+
+	return imagePath, func() {}, nil
+}
+
+func buildSquashfsBounded(folder string, target string, options squashfsOptions) error {
+	slots := buildSlots
+	slots <- struct{}{}
+	defer func() { <-slots }()
+
+	return buildSquashfsRetrying(folder, target, options)
+}
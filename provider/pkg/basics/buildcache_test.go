@@ -0,0 +1,51 @@
+package basics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildCacheKey(t *testing.T) {
+	key := buildCacheKey("folder", squashfsOptions{})
+
+	if buildCacheKey("folder", squashfsOptions{}) != key {
+		t.Fatal("expected a stable key")
+	}
+	if buildCacheKey("other", squashfsOptions{}) == key {
+		t.Fatal("expected the folder content to change the key")
+	}
+	if buildCacheKey("folder", squashfsOptions{NoFragments: true}) == key {
+		t.Fatal("expected the build options to change the key")
+	}
+	if buildCacheKey("folder", squashfsOptions{BlockSize: 4096}) == key {
+		t.Fatal("expected the block size to change the key")
+	}
+	if buildCacheKey("folder", squashfsOptions{DirMode: "0755"}) == buildCacheKey("folder", squashfsOptions{FileMode: "0755"}) {
+		t.Fatal("expected every forced mode to key the cache apart")
+	}
+}
+
+func TestBuildVolumeImageCacheHit(t *testing.T) {
+	cacheDir := t.TempDir()
+	options := squashfsOptions{}
+
+	cached := filepath.Join(cacheDir, buildCacheKey("folder", options)+squashfsTempSuffix)
+	if err := os.WriteFile(cached, []byte("hsqs"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the folder doesn't exist, any build would fail
+	imagePath, cleanup, err := buildVolumeImage(cacheDir, filepath.Join(cacheDir, "missing"), "folder", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup()
+
+	if imagePath != cached {
+		t.Fatalf("expected the cached image %s, got %s", cached, imagePath)
+	}
+	if _, err := os.Stat(cached); err != nil {
+		t.Fatalf("the cleanup must keep cached images: %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package basics
+
+import (
+	"regexp"
+	"sync"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi-go-provider/infer"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+var channelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// Channels received as secrets by a Check of this provider process. They are
+// redacted from the diff logs of every resource using them.
+var secretChannels = struct {
+	sync.Mutex
+	values map[string]bool
+}{values: map[string]bool{}}
+
+// checkSecretChannel records a channel input marked secret and validates it, since its
+// value is hidden from the user. The failure doesn't repeat the value.
+func checkSecretChannel(inputs resource.PropertyMap) []p.CheckFailure {
+	input, ok := inputs["channel"]
+	if !ok || !input.ContainsSecrets() {
+		return nil
+	}
+
+	value := input
+	for value.IsSecret() {
+		value = value.SecretValue().Element
+	}
+
+	if value.IsComputed() || !value.IsString() {
+		return nil
+	}
+
+	channel := value.StringValue()
+
+	secretChannels.Lock()
+	secretChannels.values[channel] = true
+	secretChannels.Unlock()
+
+	if !channelPattern.MatchString(channel) {
+		return []p.CheckFailure{{
+			Property: "channel",
+			Reason:   "the secret channel must be 1 to 64 letters, digits, '_', '.' or '-', starting with a letter or digit",
+		}}
+	}
+
+	return nil
+}
+
+// wireChannel keeps every output depending on every input, as infer assumes without
+// WireDependencies, and ties the messageChannel output to the channel input so that it
+// is a secret whenever the channel is.
+func wireChannel(f infer.FieldSelector, args any, state any, channel *string, messageChannel *string) {
+	f.OutputField(state).DependsOn(f.InputField(args))
+	f.OutputField(messageChannel).DependsOn(f.InputField(channel))
+}
+
+func isSecretChannel(channel string) bool {
+	secretChannels.Lock()
+	defer secretChannels.Unlock()
+
+	return secretChannels.values[channel]
+}
@@ -0,0 +1,47 @@
+package basics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+func TestSecretChannelRedacted(t *testing.T) {
+	inputs := resource.PropertyMap{"channel": resource.MakeSecret(resource.NewStringProperty("PRIVATE-NS"))}
+	if failures := checkSecretChannel(inputs); len(failures) != 0 {
+		t.Fatalf("expected a valid channel, got %v", failures)
+	}
+
+	olds := testInstanceArgs()
+	news := testInstanceArgs()
+	news.Channel = "PRIVATE-NS"
+
+	for _, reason := range changeReasons(olds, news) {
+		if strings.Contains(reason, "PRIVATE-NS") {
+			t.Fatalf("secret channel printed in %q", reason)
+		}
+	}
+
+	news.Channel = "PUBLIC"
+	if reasons := changeReasons(olds, news); len(reasons) != 1 || reasons[0] != "channel: TEST -> PUBLIC" {
+		t.Fatalf("expected a plain channel change, got %v", reasons)
+	}
+}
+
+func TestSecretChannelValidated(t *testing.T) {
+	inputs := resource.PropertyMap{"channel": resource.MakeSecret(resource.NewStringProperty("my channel"))}
+
+	failures := checkSecretChannel(inputs)
+	if len(failures) != 1 {
+		t.Fatalf("expected a failure for an invalid channel, got %v", failures)
+	}
+	if strings.Contains(failures[0].Reason, "my channel") {
+		t.Fatalf("secret channel printed in %q", failures[0].Reason)
+	}
+
+	plain := resource.PropertyMap{"channel": resource.NewStringProperty("my channel")}
+	if failures := checkSecretChannel(plain); len(failures) != 0 {
+		t.Fatalf("plain channels are not validated, got %v", failures)
+	}
+}
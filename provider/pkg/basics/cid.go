@@ -0,0 +1,72 @@
+package basics
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	chunker "github.com/ipfs/boxo/chunker"
+	"github.com/ipfs/boxo/ipld/merkledag"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/balanced"
+	"github.com/ipfs/boxo/ipld/unixfs/importer/helpers"
+	"github.com/ipfs/go-cid"
+	ipld "github.com/ipfs/go-ipld-format"
+)
+
+// discardDAGService drops the blocks it is given: computing a CID only needs the
+// blocks to be hashed, not kept.
+type discardDAGService struct{}
+
+func (discardDAGService) Get(ctx context.Context, c cid.Cid) (ipld.Node, error) {
+	return nil, ipld.ErrNotFound{Cid: c}
+}
+
+func (discardDAGService) GetMany(ctx context.Context, cids []cid.Cid) <-chan *ipld.NodeOption {
+	out := make(chan *ipld.NodeOption)
+	close(out)
+	return out
+}
+
+func (discardDAGService) Add(ctx context.Context, node ipld.Node) error        { return nil }
+func (discardDAGService) AddMany(ctx context.Context, nodes []ipld.Node) error { return nil }
+func (discardDAGService) Remove(ctx context.Context, c cid.Cid) error          { return nil }
+func (discardDAGService) RemoveMany(ctx context.Context, cids []cid.Cid) error { return nil }
+
+// localCid computes the CID an IPFS node adding the file with the given options would
+// return: default 256 KiB chunks laid out as a balanced unixfs DAG.
+func localCid(filePath string, options IpfsAddOptions) (string, error) {
+	if options.CidVersion > 1 {
+		return "", errors.New("ipfs cid version must be 0 or 1")
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	defer file.Close()
+
+	prefix, err := merkledag.PrefixForCidVersion(int(options.CidVersion))
+	if err != nil {
+		return "", err
+	}
+
+	params := helpers.DagBuilderParams{
+		Maxlinks:   helpers.DefaultLinksPerBlock,
+		RawLeaves:  options.RawLeaves,
+		CidBuilder: prefix,
+		Dagserv:    discardDAGService{},
+	}
+
+	builder, err := params.New(chunker.DefaultSplitter(file))
+	if err != nil {
+		return "", err
+	}
+
+	root, err := balanced.Layout(builder)
+	if err != nil {
+		return "", err
+	}
+
+	return root.Cid().String(), nil
+}
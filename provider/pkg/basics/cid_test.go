@@ -0,0 +1,31 @@
+package basics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalCidOfEmptyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(path, []byte{}, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		options  IpfsAddOptions
+		expected string
+	}{
+		{IpfsAddOptions{CidVersion: 0}, "QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH"},
+		{IpfsAddOptions{CidVersion: 1, RawLeaves: true}, "bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku"},
+	}
+	for _, c := range cases {
+		actual, err := localCid(path, c.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != c.expected {
+			t.Errorf("%+v: expected cid %s, got %s", c.options, c.expected, actual)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package basics
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
@@ -9,27 +10,117 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"mime/multipart"
 	"net/http"
 	"net/url"
 	"os"
-	"path/filepath"
+	"strings"
 	"time"
 )
 
 const AlephApiUrl string = "https://api3.aleph.im"
 
+const SchedulerApiUrl string = "https://scheduler.api.aleph.sh"
+
+const DefaultApiVersion string = "v0"
+
+// SupportedApiVersions are the API versions endpoint paths can be built for.
+var SupportedApiVersions = []string{"v0", "v1"}
+
+// DefaultReadNodes are queried when the load balancer does not know a message yet.
+var DefaultReadNodes = []string{"https://api2.aleph.im"}
+
 type TwentySixClient struct {
 	account TwentySixAccountState
 	channel string
 
+	// Context of the resource operation using the client, it bounds the waits and is
+	// set on every request by NewConfiguredClient.
+	ctx context.Context
+
 	http http.Client
+	// Transport routing the API requests, set by NewConfiguredClient.
+	failover *failoverTransport
+
+	lastUpload UploadStats
+
+	confirmationPolling ConfirmationPolling
+	confirmOnProcessed  bool
+
+	timeSynced bool
+	timeOffset time.Duration
+
+	readNodes []string
+
+	ipfsGateways []string
+
+	forgetTimeout        int64
+	forgetInterval       int64
+	forgetTimeoutWarning bool
+
+	apiVersion string
+
+	stampProviderMetadata bool
+
+	storageContentThreshold int64
+
+	schedulerPollInterval int64
+	schedulerPollJitter   int64
+
+	propagationTimeout int64
+	verifyQuorum       int
+
+	buildCacheDir string
+
+	detectContentType bool
+}
+
+// IpfsAddOptions are forwarded as query parameters to the ipfs/add_file endpoint
+// and control how the resulting CID is computed and whether the content is pinned.
+type IpfsAddOptions struct {
+	Pin        bool
+	CidVersion uint64
+	RawLeaves  bool
+}
+
+func (options IpfsAddOptions) query() url.Values {
+	params := url.Values{}
+
+	params.Add("pin", fmt.Sprint(options.Pin))
+	params.Add("cid-version", fmt.Sprint(options.CidVersion))
+	params.Add("raw-leaves", fmt.Sprint(options.RawLeaves))
+
+	return params
+}
+
+// apiPath prefixes an endpoint path with the API version of the client, e.g. /api/v0/messages.
+func (client *TwentySixClient) apiPath(path string) string {
+	return "/api/" + client.apiVersion + path
+}
+
+// readUrls lists the nodes reads are attempted against, the load balancer first,
+// then the configured read nodes which may already have synced a fresh message.
+func (client *TwentySixClient) readUrls() []string {
+	return append([]string{AlephApiUrl}, client.readNodes...)
 }
 
 func (client *TwentySixClient) GetMessageByHash(hash string) (Message, error) {
+	var message Message
+	var err error
+
+	for _, apiUrl := range client.readUrls() {
+		message, err = client.getMessageByHash(apiUrl, hash)
+		if err == nil || err.Error() != "message not found" {
+			return message, err
+		}
+	}
+
+	return message, err
+}
+
+func (client *TwentySixClient) getMessageByHash(apiUrl string, hash string) (Message, error) {
 
 	//https://api2.aleph.im/api/v0/messages.json?hashes=d51f34748974a1e652becd28c28249c2eb5a0cfaf8b718dde7121034d5733981
-	messageEndpoint := AlephApiUrl + "/api/v0/messages.json?hashes=" + hash
+	messageEndpoint := apiUrl + client.apiPath("/messages.json?hashes=") + hash
 	request, err := http.NewRequest("GET", messageEndpoint, bytes.NewBuffer([]byte("")))
 	if err != nil {
 		return Message{}, err
@@ -55,61 +146,135 @@ func (client *TwentySixClient) GetMessageByHash(hash string) (Message, error) {
 
 	defer response.Body.Close()
 
-	if result.PaginationTotal != 1 {
+	if result.PaginationTotal == 0 || len(result.Messages) == 0 {
 		return Message{}, errors.New("message not found")
-	} else {
-		return result.Messages[0], nil
 	}
+
+	// a hash identifies a single message, never pick one of several matches
+	if result.PaginationTotal > 1 || len(result.Messages) > 1 {
+		return Message{}, fmt.Errorf("%d messages found for hash %s, expected one", max(result.PaginationTotal, uint64(len(result.Messages))), hash)
+	}
+
+	return result.Messages[0], nil
 }
 
-func (client *TwentySixClient) WaitMessageConfirmation(hash string, timeout int64, interval int64) error {
-	var startAt int64 = time.Now().Unix()
-	var message Message
+func (client *TwentySixClient) GetMessageStatus(hash string) (MessageStatusResponse, error) {
+	statusEndpoint := AlephApiUrl + client.apiPath("/messages/") + hash + "/status"
+	request, err := http.NewRequest("GET", statusEndpoint, nil)
+	if err != nil {
+		return MessageStatusResponse{}, err
+	}
+
+	request.Header.Add("Accept", "application/json")
+
+	response, err := client.http.Do(request)
+	if err != nil {
+		return MessageStatusResponse{}, err
+	}
+
+	defer response.Body.Close()
 
-	message, err := client.GetMessageByHash(hash)
+	if response.StatusCode == http.StatusNotFound {
+		return MessageStatusResponse{}, errors.New("message not found")
+	}
+
+	resultBody, err := io.ReadAll(response.Body)
 	if err != nil {
-		return err
+		return MessageStatusResponse{}, err
 	}
 
-	for !message.Confirmed {
-		time.Sleep(time.Duration(interval) * time.Second)
+	var result MessageStatusResponse
+	if err := json.Unmarshal(resultBody, &result); err != nil {
+		return MessageStatusResponse{}, err
+	}
 
-		message, err = client.GetMessageByHash(hash)
+	return result, nil
+}
+
+// WaitMessageConfirmation waits for the on-chain confirmation of the message, or only
+// for aleph to process it with confirmOnProcessed.
+func (client *TwentySixClient) WaitMessageConfirmation(hash string, options WaitOptions) error {
+	if client.confirmationPolling == HeavyConfirmationPolling {
+		return client.waitMessageConfirmationHeavy(hash, options)
+	}
+
+	processed := false
+	err := waitUntil(client.ctx, options, func() (bool, error) {
+		if !processed {
+			status, err := client.GetMessageStatus(hash)
+			if err != nil {
+				// the message may not have reached the node yet
+				return false, ignoreMessageNotFound(err)
+			}
+
+			if status.Status == RejectedMessageStatus || status.Status == ForgottenMessageStatus {
+				return false, fmt.Errorf("message %s is %s", hash, status.Status)
+			}
+
+			if status.Status != ProcessedMessageStatus {
+				return false, nil
+			}
+			processed = true
+		}
+
+		// the full message is only fetched once it has been processed
+		message, err := client.GetMessageByHash(hash)
 		if err != nil {
-			return err
+			return false, err
 		}
 
-		now := time.Now().Unix()
-		if now > startAt+timeout {
-			return errors.New("message confirmation timeout")
+		return client.confirmOnProcessed || message.Confirmed, nil
+	})
+	if errors.Is(err, errWaitTimeout) {
+		return errors.New("message confirmation timeout")
+	}
+
+	return err
+}
+
+func (client *TwentySixClient) waitMessageConfirmationHeavy(hash string, options WaitOptions) error {
+	err := waitUntil(client.ctx, options, func() (bool, error) {
+		message, err := client.GetMessageByHash(hash)
+		if err != nil {
+			return false, ignoreMessageNotFound(err)
 		}
+
+		// the messages endpoint only lists processed messages
+		return client.confirmOnProcessed || message.Confirmed, nil
+	})
+	if errors.Is(err, errWaitTimeout) {
+		return errors.New("message confirmation timeout")
 	}
 
-	return nil
+	return err
 }
 
-func (client *TwentySixClient) SendMessage(msgType MessageType, content interface{}) ([]byte, error) {
+// SendMessage signs and broadcasts an inline message of the content and returns the
+// parsed broadcast response.
+func (client *TwentySixClient) SendMessage(msgType MessageType, content interface{}) (MessageResponse, error) {
 
-	msgContent, err := json.Marshal(content)
+	msgContent, err := marshalItemContent(content)
 	if err != nil {
-		return []byte{}, err
+		return MessageResponse{}, err
 	}
 
-	contentHash := sha256.Sum256(msgContent)
-
 	message := Message{
 		Type:    msgType,
-		Chain:   EthereumChain,
+		Chain:   client.account.chain(),
 		Sender:  client.account.Address,
-		Time:    float64(time.Now().Unix()),
+		Time:    client.now(),
 		Channel: client.channel,
 
-		ItemHash:    hex.EncodeToString(contentHash[:]),
-		ItemType:    IpfsMessageItem,
+		ItemHash:    itemHash(msgContent),
+		ItemType:    InlineMessageItem,
 		ItemContent: string(msgContent),
 	}
 
-	message.SignMessage(client.account.PrivateKey)
+	if err := client.offloadItemContent(&message); err != nil {
+		return MessageResponse{}, err
+	}
+
+	message.SignMessage(client.account.signingKey())
 
 	req := BroadcastRequest{
 		Message: message,
@@ -118,13 +283,17 @@ func (client *TwentySixClient) SendMessage(msgType MessageType, content interfac
 
 	buff, err := json.Marshal(req)
 	if err != nil {
-		return []byte{}, err
+		return MessageResponse{}, err
+	}
+
+	if err := checkMessageSize(message); err != nil {
+		return MessageResponse{}, err
 	}
 
-	storeEndpoint := AlephApiUrl + "/api/v0/messages"
+	storeEndpoint := AlephApiUrl + client.apiPath("/messages")
 	request, err := http.NewRequest("POST", storeEndpoint, bytes.NewBuffer(buff))
 	if err != nil {
-		return []byte{}, err
+		return MessageResponse{}, err
 	}
 
 	request.Header.Add("Content-Type", "application/json")
@@ -132,66 +301,67 @@ func (client *TwentySixClient) SendMessage(msgType MessageType, content interfac
 
 	response, err := client.http.Do(request)
 	if err != nil {
-		return []byte{}, err
+		return MessageResponse{}, err
 	}
 
 	resultBody, err := io.ReadAll(response.Body)
 	if err != nil {
-		return []byte{}, err
+		return MessageResponse{}, err
+	}
+
+	var parsedRes MessageResponse
+	if err := json.Unmarshal(resultBody, &parsedRes); err != nil {
+		return MessageResponse{}, err
+	}
+
+	if err := parsedRes.publicationError(strings.ToLower(string(msgType))); err != nil {
+		return MessageResponse{}, err
 	}
 
-	return resultBody, nil
+	return parsedRes, nil
 }
 
-func (client *TwentySixClient) StoreFile(filePath string) (Message, string, error) {
-	now := float64(time.Now().UnixMilli()) / 1000
+func (client *TwentySixClient) StoreFile(filePath string, metadata map[string]string) (Message, StoreIPFSFileResponse, error) {
+	now := client.now()
 	file, err := os.Open(filePath)
 	if err != nil {
-		return Message{}, "", err
+		return Message{}, StoreIPFSFileResponse{}, err
 	}
 
 	defer file.Close()
 
 	hash := sha256.New()
-	if _, err := io.Copy(hash, file); err != nil {
-		return Message{}, "", err
-	}
-
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-
-	//Generate metadata
-	metadatapart, err := writer.CreateFormField("metadata")
+	uploadSize, err := io.Copy(hash, file)
 	if err != nil {
-		return Message{}, "", err
+		return Message{}, StoreIPFSFileResponse{}, err
 	}
 
+	//Generate metadata
 	itemContent := StoreMessageContent{
 		Address:  client.account.Address,
 		Time:     now,
 		ItemHash: hex.EncodeToString(hash.Sum(nil)),
 		ItemType: StorageMessageItem,
+		Metadata: client.stampMetadata(metadata),
 	}
 
-	jsonItem, err := json.Marshal(itemContent)
+	jsonItem, err := marshalItemContent(itemContent)
 	if err != nil {
-		return Message{}, "", err
+		return Message{}, StoreIPFSFileResponse{}, err
 	}
 
-	contentHash := sha256.Sum256(jsonItem)
-
 	message := Message{
-		Chain:       EthereumChain,
+		Chain:       client.account.chain(),
 		Sender:      client.account.Address,
 		Channel:     client.channel,
 		Time:        now,
 		Type:        StoreMessageType,
 		ItemType:    InlineMessageItem,
-		ItemHash:    hex.EncodeToString(contentHash[:]),
+		ItemHash:    itemHash(jsonItem),
 		ItemContent: string(jsonItem),
 	}
 
-	message.SignMessage(client.account.PrivateKey)
+	message.SignMessage(client.account.signingKey())
 
 	req := BroadcastRequest{
 		Message: message,
@@ -200,90 +370,185 @@ func (client *TwentySixClient) StoreFile(filePath string) (Message, string, erro
 
 	jsonReq, err := json.Marshal(req)
 	if err != nil {
-		return Message{}, "", err
+		return Message{}, StoreIPFSFileResponse{}, err
 	}
 
-	metadata := bytes.NewReader(jsonReq)
-	io.Copy(metadatapart, metadata)
-
 	//Upload file
-	filepart, err := writer.CreateFormFile("file", filepath.Base(file.Name()))
+	upload, err := client.newMultipartUpload(filePath, jsonReq)
 	if err != nil {
-		return Message{}, "", err
+		return Message{}, StoreIPFSFileResponse{}, err
 	}
 
-	file, err = os.Open(filePath)
+	defer upload.Close()
+
+	storeEndpoint := AlephApiUrl + client.apiPath("/storage/add_file")
+	response, err := client.upload(storeEndpoint, upload.contentType, upload.body, upload.size)
 	if err != nil {
-		return Message{}, "", err
+		return Message{}, StoreIPFSFileResponse{}, err
 	}
 
-	defer file.Close()
+	resultBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return Message{}, StoreIPFSFileResponse{}, err
+	}
 
-	io.Copy(filepart, file)
-	writer.Close()
+	defer response.Body.Close()
 
-	storeEndpoint := AlephApiUrl + "/api/v0/storage/add_file"
-	request, err := http.NewRequest("POST", storeEndpoint, body)
+	var storeFileResponse StoreIPFSFileResponse
+	if err := json.Unmarshal(resultBody, &storeFileResponse); err != nil {
+		return Message{}, StoreIPFSFileResponse{}, err
+	}
+
+	if err := storeFilePublicationError(resultBody, storeFileResponse); err != nil {
+		return Message{}, StoreIPFSFileResponse{}, err
+	}
+
+	createdMessage, err := client.waitVolumePropagated(storeFileResponse.Hash)
 	if err != nil {
-		return Message{}, "", err
+		return Message{}, StoreIPFSFileResponse{}, err
 	}
 
-	request.Header.Add("Content-Type", writer.FormDataContentType())
-	request.Header.Add("Accept", "application/json")
+	// the storage engine keeps the file as is
+	if storeFileResponse.Size == 0 {
+		storeFileResponse.Size = uint64(uploadSize)
+	}
 
-	response, err := client.http.Do(request)
+	return createdMessage, storeFileResponse, nil
+}
+
+func (client *TwentySixClient) StoreIPFSFile(filePath string, options IpfsAddOptions, metadata map[string]string) (Message, StoreIPFSFileResponse, error) {
+	upload, err := client.newMultipartUpload(filePath, nil)
+	if err != nil {
+		return Message{}, StoreIPFSFileResponse{}, err
+	}
+
+	defer upload.Close()
+
+	addEndpoint := AlephApiUrl + client.apiPath("/ipfs/add_file?") + options.query().Encode()
+	response, err := client.upload(addEndpoint, upload.contentType, upload.body, upload.size)
 	if err != nil {
-		return Message{}, "", err
+		return Message{}, StoreIPFSFileResponse{}, err
 	}
 
+	defer response.Body.Close()
+
 	resultBody, err := io.ReadAll(response.Body)
 	if err != nil {
-		return Message{}, "", err
+		return Message{}, StoreIPFSFileResponse{}, err
 	}
 
-	var storeFileResponse StoreIPFSFileResponse
-	if err := json.Unmarshal(resultBody, &storeFileResponse); err != nil {
-		return Message{}, "", err
+	var addFileResponse StoreIPFSFileResponse
+	if err := json.Unmarshal(resultBody, &addFileResponse); err != nil {
+		return Message{}, StoreIPFSFileResponse{}, err
 	}
 
-	defer response.Body.Close()
+	if addFileResponse.Hash == "" {
+		return Message{}, StoreIPFSFileResponse{}, errors.New("ipfs upload failed: " + string(resultBody))
+	}
+
+	now := client.now()
+
+	itemContent := StoreMessageContent{
+		Address:  client.account.Address,
+		Time:     now,
+		ItemHash: addFileResponse.Hash,
+		ItemType: IpfsMessageItem,
+		Metadata: client.stampMetadata(metadata),
+	}
+
+	jsonItem, err := marshalItemContent(itemContent)
+	if err != nil {
+		return Message{}, StoreIPFSFileResponse{}, err
+	}
+
+	message := Message{
+		Chain:       client.account.chain(),
+		Sender:      client.account.Address,
+		Channel:     client.channel,
+		Time:        now,
+		Type:        StoreMessageType,
+		ItemType:    InlineMessageItem,
+		ItemHash:    itemHash(jsonItem),
+		ItemContent: string(jsonItem),
+	}
+
+	message.SignMessage(client.account.signingKey())
+
+	req := BroadcastRequest{
+		Message: message,
+		Sync:    false,
+	}
+
+	messageJSON, err := json.Marshal(req)
+	if err != nil {
+		return Message{}, StoreIPFSFileResponse{}, err
+	}
+
+	storeEndpoint := AlephApiUrl + client.apiPath("/messages")
+	storeRequest, err := http.NewRequest("POST", storeEndpoint, bytes.NewBuffer(messageJSON))
+	if err != nil {
+		return Message{}, StoreIPFSFileResponse{}, err
+	}
+
+	storeRequest.Header.Add("Content-Type", "application/json")
+	storeRequest.Header.Add("Accept", "application/json")
+
+	storeResponse, err := client.http.Do(storeRequest)
+	if err != nil {
+		return Message{}, StoreIPFSFileResponse{}, err
+	}
 
-	time.Sleep(5 * time.Second)
+	defer storeResponse.Body.Close()
 
-	createdMessage, err := client.GetVolumeByItemHash(storeFileResponse.Hash)
+	storeBody, err := io.ReadAll(storeResponse.Body)
 	if err != nil {
-		return Message{}, "", err
+		return Message{}, StoreIPFSFileResponse{}, err
+	}
+
+	var parsedRes MessageResponse
+	if err := json.Unmarshal(storeBody, &parsedRes); err != nil {
+		return Message{}, StoreIPFSFileResponse{}, err
 	}
 
-	return createdMessage, storeFileResponse.Hash, nil
+	if err := parsedRes.publicationError("store"); err != nil {
+		return Message{}, StoreIPFSFileResponse{}, err
+	}
+
+	return message, addFileResponse, nil
 }
 
 func (client *TwentySixClient) CreateInstance(instance TwentySixInstanceArgs) (Message, MessageResponse, error) {
-	now := float64(time.Now().UnixMilli()) / 1000
+	now := client.now()
 
 	instanceMessage := client.instanceArgsToMessage(instance)
 	instanceMessage.Time = now
 	instanceMessage.Address = client.account.Address
 
-	jsonItem, err := json.Marshal(instanceMessage)
+	jsonItem, err := marshalItemContent(instanceMessage)
 	if err != nil {
 		return Message{}, MessageResponse{}, err
 	}
 
-	contentHash := sha256.Sum256(jsonItem)
-
 	message := Message{
-		Chain:       EthereumChain,
+		Chain:       client.account.chain(),
 		Sender:      client.account.Address,
 		Channel:     client.channel,
 		Time:        now,
 		Type:        InstanceMessageType,
 		ItemType:    InlineMessageItem,
-		ItemHash:    hex.EncodeToString(contentHash[:]),
+		ItemHash:    itemHash(jsonItem),
 		ItemContent: string(jsonItem),
 	}
 
-	message.SignMessage(client.account.PrivateKey)
+	if err := client.offloadItemContent(&message); err != nil {
+		return Message{}, MessageResponse{}, err
+	}
+
+	message.SignMessage(client.account.signingKey())
+
+	if err := client.ValidateMessage(message); err != nil {
+		return Message{}, MessageResponse{}, err
+	}
 
 	req := BroadcastRequest{
 		Sync:    false,
@@ -295,10 +560,14 @@ func (client *TwentySixClient) CreateInstance(instance TwentySixInstanceArgs) (M
 		return Message{}, MessageResponse{}, err
 	}
 
+	if err := checkMessageSize(message); err != nil {
+		return Message{}, MessageResponse{}, err
+	}
+
 	log.Println("_________________________ instance request _________________________")
 	log.Println(string(messageJSON))
 
-	storeEndpoint := AlephApiUrl + "/api/v0/messages"
+	storeEndpoint := AlephApiUrl + client.apiPath("/messages")
 	request, err := http.NewRequest("POST", storeEndpoint, bytes.NewBuffer(messageJSON))
 	if err != nil {
 		return Message{}, MessageResponse{}, err
@@ -324,35 +593,50 @@ func (client *TwentySixClient) CreateInstance(instance TwentySixInstanceArgs) (M
 		return Message{}, MessageResponse{}, err
 	}
 
+	kind := "instance"
+	if instance.Replaces != "" {
+		kind = "instance amend"
+	}
+
+	if err := createInstanceResponse.publicationError(kind); err != nil {
+		return Message{}, MessageResponse{}, err
+	}
+
 	return message, createInstanceResponse, nil
 }
 
 func (client *TwentySixClient) CreateFunction(function TwentySixFunctionArgs) (Message, MessageResponse, error) {
-	now := float64(time.Now().UnixMilli()) / 1000
+	now := client.now()
 
 	functionMessage := client.functionArgsToMessage(function)
 	functionMessage.Time = now
 	functionMessage.Address = client.account.Address
 
-	jsonItem, err := json.Marshal(functionMessage)
+	jsonItem, err := marshalItemContent(functionMessage)
 	if err != nil {
 		return Message{}, MessageResponse{}, err
 	}
 
-	contentHash := sha256.Sum256(jsonItem)
-
 	message := Message{
-		Chain:       EthereumChain,
+		Chain:       client.account.chain(),
 		Sender:      client.account.Address,
 		Channel:     client.channel,
 		Time:        now,
 		Type:        ProgramMessageType,
 		ItemType:    InlineMessageItem,
-		ItemHash:    hex.EncodeToString(contentHash[:]),
+		ItemHash:    itemHash(jsonItem),
 		ItemContent: string(jsonItem),
 	}
 
-	message.SignMessage(client.account.PrivateKey)
+	if err := client.offloadItemContent(&message); err != nil {
+		return Message{}, MessageResponse{}, err
+	}
+
+	message.SignMessage(client.account.signingKey())
+
+	if err := client.ValidateMessage(message); err != nil {
+		return Message{}, MessageResponse{}, err
+	}
 
 	req := BroadcastRequest{
 		Sync:    false,
@@ -364,10 +648,14 @@ func (client *TwentySixClient) CreateFunction(function TwentySixFunctionArgs) (M
 		return Message{}, MessageResponse{}, err
 	}
 
+	if err := checkMessageSize(message); err != nil {
+		return Message{}, MessageResponse{}, err
+	}
+
 	log.Println("_________________________ function request _________________________")
 	log.Println(string(messageJSON))
 
-	storeEndpoint := AlephApiUrl + "/api/v0/messages"
+	storeEndpoint := AlephApiUrl + client.apiPath("/messages")
 	request, err := http.NewRequest("POST", storeEndpoint, bytes.NewBuffer(messageJSON))
 	if err != nil {
 		return Message{}, MessageResponse{}, err
@@ -393,6 +681,15 @@ func (client *TwentySixClient) CreateFunction(function TwentySixFunctionArgs) (M
 		return Message{}, MessageResponse{}, err
 	}
 
+	kind := "function"
+	if function.Replaces != "" {
+		kind = "function amend"
+	}
+
+	if err := createfunctionResponse.publicationError(kind); err != nil {
+		return Message{}, MessageResponse{}, err
+	}
+
 	return message, createfunctionResponse, nil
 }
 
@@ -407,7 +704,7 @@ func (client *TwentySixClient) instanceArgsToMessage(instance TwentySixInstanceA
 			SizeMib:     instance.Rootfs.SizeMib,
 		},
 		AllowAmend:     instance.AllowAmend,
-		Metadata:       instance.Metadata,
+		Metadata:       client.stampMetadata(mergeTags(instance.Metadata, instance.Tags)),
 		AuthorizedKeys: instance.AuthorizedKeys,
 		Variables:      instance.Variables,
 		Environment: FunctionEnvironment{
@@ -430,7 +727,7 @@ func (client *TwentySixClient) instanceArgsToMessage(instance TwentySixInstanceA
 		// 	Cpu:  instance.Requirements.Cpu,
 		// 	Node: instance.Requirements.Node,
 		// },
-		Volumes:  instance.Volumes,
+		Volumes:  canonicalVolumes(instance.Volumes),
 		Replaces: instance.Replaces,
 	}
 
@@ -439,8 +736,12 @@ func (client *TwentySixClient) instanceArgsToMessage(instance TwentySixInstanceA
 
 func (client *TwentySixClient) functionArgsToMessage(function TwentySixFunctionArgs) ProgramMessageContent {
 	functionMessage := ProgramMessageContent{
+		On: FunctionTriggers{
+			Http:       true,
+			Persistent: function.effectiveRestartPolicy() == AlwaysRestartPolicy,
+		},
 		AllowAmend:     function.AllowAmend,
-		Metadata:       function.Metadata,
+		Metadata:       client.stampMetadata(mergeTags(function.Metadata, function.Tags)),
 		AuthorizedKeys: function.AuthorizedKeys,
 		Variables:      function.Variables,
 		Environment: FunctionEnvironment{
@@ -463,7 +764,7 @@ func (client *TwentySixClient) functionArgsToMessage(function TwentySixFunctionA
 		// 	Cpu:  instance.Requirements.Cpu,
 		// 	Node: instance.Requirements.Node,
 		// },
-		Volumes:  function.Volumes,
+		Volumes:  canonicalVolumes(function.Volumes),
 		Replaces: function.Replaces,
 	}
 
@@ -472,7 +773,7 @@ func (client *TwentySixClient) functionArgsToMessage(function TwentySixFunctionA
 
 func (client *TwentySixClient) GetInstanceState(hash string) (SchedulerAllocation, error) {
 	body := &bytes.Buffer{}
-	endpoint := "https://scheduler.api.aleph.sh/api/v0/allocation/" + hash
+	endpoint := SchedulerApiUrl + "/api/v0/allocation/" + hash
 
 	var res SchedulerAllocation
 
@@ -504,33 +805,122 @@ func (client *TwentySixClient) GetInstanceState(hash string) (SchedulerAllocatio
 	return res, nil
 }
 
-func (client *TwentySixClient) GetMessages(size uint64, page uint64, hashes []string, addresses []string, channels []string, msgTypes []MessageType) ([]Message, uint64, error) {
-	var messages []Message
-	body := &bytes.Buffer{}
-
-	messageEndpoint := AlephApiUrl + "/api/v0/messages.json?"
+// MessageFilter selects the messages listed by GetMessagesFiltered and GetAllMessages.
+// Empty fields don't filter.
+type MessageFilter struct {
+	Hashes       []string
+	Addresses    []string
+	Channels     []string
+	MessageTypes []MessageType
+	Refs         []string
+	Tags         []string
+	StartTime    time.Time
+	EndTime      time.Time
+}
 
+func (filter MessageFilter) params() url.Values {
 	params := url.Values{}
 
-	params.Add("page", fmt.Sprint(page))
-	params.Add("size", fmt.Sprint(size))
-
-	for i := 0; i < len(hashes); i++ {
-		params.Add("hashes", hashes[i])
+	for i := 0; i < len(filter.Hashes); i++ {
+		params.Add("hashes", filter.Hashes[i])
+	}
+	for i := 0; i < len(filter.Addresses); i++ {
+		params.Add("addresses", filter.Addresses[i])
+	}
+	for i := 0; i < len(filter.Channels); i++ {
+		params.Add("channels", filter.Channels[i])
+	}
+	for i := 0; i < len(filter.MessageTypes); i++ {
+		params.Add("msgTypes", string(filter.MessageTypes[i]))
+	}
+	for i := 0; i < len(filter.Refs); i++ {
+		params.Add("refs", filter.Refs[i])
+	}
+	for i := 0; i < len(filter.Tags); i++ {
+		params.Add("tags", filter.Tags[i])
+	}
+	if !filter.StartTime.IsZero() {
+		params.Add("startDate", fmt.Sprint(filter.StartTime.Unix()))
+	}
+	if !filter.EndTime.IsZero() {
+		params.Add("endDate", fmt.Sprint(filter.EndTime.Unix()))
 	}
-	for i := 0; i < len(addresses); i++ {
-		params.Add("addresses", addresses[i])
+
+	return params
+}
+
+const messagesPageSize uint64 = 200
+
+func (client *TwentySixClient) GetMessages(size uint64, page uint64, hashes []string, addresses []string, channels []string, msgTypes []MessageType) ([]Message, uint64, error) {
+	filter := MessageFilter{
+		Hashes:       hashes,
+		Addresses:    addresses,
+		Channels:     channels,
+		MessageTypes: msgTypes,
 	}
-	for i := 0; i < len(channels); i++ {
-		params.Add("channels", channels[i])
+
+	return client.GetMessagesFiltered(client.ctx, filter, page, size)
+}
+
+// requestContext returns ctx, or the operation context of the client when the caller
+// has none.
+func (client *TwentySixClient) requestContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		return client.ctx
 	}
-	for i := 0; i < len(msgTypes); i++ {
-		params.Add("msgTypes", string(msgTypes[i]))
+
+	return ctx
+}
+
+// GetMessagesFiltered returns a page of the messages matching the filter and the
+// number of messages after it.
+func (client *TwentySixClient) GetMessagesFiltered(ctx context.Context, filter MessageFilter, page uint64, size uint64) ([]Message, uint64, error) {
+	return client.getMessages(client.requestContext(ctx), AlephApiUrl, filter, page, size)
+}
+
+// GetAllMessages pages through all the messages matching the filter. The context is
+// checked between pages.
+func (client *TwentySixClient) GetAllMessages(ctx context.Context, filter MessageFilter) ([]Message, error) {
+	return client.getAllMessages(client.requestContext(ctx), AlephApiUrl, filter)
+}
+
+func (client *TwentySixClient) getAllMessages(ctx context.Context, apiUrl string, filter MessageFilter) ([]Message, error) {
+	messages := []Message{}
+
+	var page uint64 = 1
+	for {
+		if err := operationError(ctx); err != nil {
+			return nil, err
+		}
+
+		found, remaining, err := client.getMessages(ctx, apiUrl, filter, page, messagesPageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, found...)
+
+		// an empty page ends the listing even if the total is off
+		if remaining == 0 || len(found) == 0 {
+			return messages, nil
+		}
+		page++
 	}
+}
+
+func (client *TwentySixClient) getMessages(ctx context.Context, apiUrl string, filter MessageFilter, page uint64, size uint64) ([]Message, uint64, error) {
+	var messages []Message
+	body := &bytes.Buffer{}
+
+	messageEndpoint := apiUrl + client.apiPath("/messages.json?")
+
+	params := filter.params()
+	params.Add("page", fmt.Sprint(page))
+	params.Add("size", fmt.Sprint(size))
 
 	filteredEndpoint := messageEndpoint + params.Encode()
 
-	request, err := http.NewRequest("GET", filteredEndpoint, body)
+	request, err := http.NewRequestWithContext(ctx, "GET", filteredEndpoint, body)
 	if err != nil {
 		return messages, 0, err
 	}
@@ -556,43 +946,65 @@ func (client *TwentySixClient) GetMessages(size uint64, page uint64, hashes []st
 		messages = append(messages, getMessageResponse.Messages[i])
 	}
 
-	var remainingItems uint64
-	if getMessageResponse.PaginationPage*getMessageResponse.PaginationPerPage > getMessageResponse.PaginationTotal {
-		remainingItems = 0
-	} else {
-		remainingItems = getMessageResponse.PaginationTotal - (getMessageResponse.PaginationPage * getMessageResponse.PaginationPerPage)
+	return messages, remainingMessages(getMessageResponse), nil
+}
+
+// remainingMessages counts the messages after the page from the messages it actually
+// holds, so a full last page or an empty listing doesn't announce another page.
+func remainingMessages(response GetMessageResponse) uint64 {
+	var seen uint64
+	if response.PaginationPage > 1 {
+		seen = (response.PaginationPage - 1) * response.PaginationPerPage
+	}
+	seen += uint64(len(response.Messages))
+
+	if seen >= response.PaginationTotal {
+		return 0
 	}
 
-	return messages, remainingItems, nil
+	return response.PaginationTotal - seen
 }
 
 func (client *TwentySixClient) GetVolumes(size uint64, page uint64) ([]Message, uint64, error) {
 	return client.GetMessages(size, page, []string{}, []string{client.account.Address}, []string{client.channel}, []MessageType{StoreMessageType})
 }
 
+// GetVolumeByItemHash finds the STORE message of the client address and channel
+// storing the content hash.
 func (client *TwentySixClient) GetVolumeByItemHash(hash string) (Message, error) {
-	var page uint64 = 1
-	var parsingEnded = false
-
-	for !parsingEnded {
-		volumes, remainingItems, err := client.GetVolumes(50, page)
-		if err != nil {
-			return Message{}, err
+	for _, apiUrl := range client.readUrls() {
+		volume, err := client.getVolumeByItemHash(apiUrl, hash)
+		if err == nil || err.Error() != "volume not found" {
+			return volume, err
 		}
+	}
 
-		for i := 0; i < len(volumes); i++ {
-			var itemContent StoreMessageContent
-			json.Unmarshal([]byte(volumes[i].ItemContent), &itemContent)
+	return Message{}, errors.New("volume not found")
+}
 
-			if itemContent.ItemHash == hash {
-				return volumes[i], nil
-			}
+func (client *TwentySixClient) getVolumeByItemHash(apiUrl string, hash string) (Message, error) {
+	filter := MessageFilter{
+		Addresses:    []string{client.account.Address},
+		Channels:     []string{client.channel},
+		MessageTypes: []MessageType{StoreMessageType},
+	}
+
+	volumes, err := client.getAllMessages(client.ctx, apiUrl, filter)
+	if err != nil {
+		return Message{}, err
+	}
+
+	for i := 0; i < len(volumes); i++ {
+		// the same content stored on another channel is another volume
+		if client.channel != "" && volumes[i].Channel != client.channel {
+			continue
 		}
 
-		if remainingItems > 0 {
-			page += 1
-		} else {
-			parsingEnded = true
+		var itemContent StoreMessageContent
+		json.Unmarshal([]byte(volumes[i].ItemContent), &itemContent)
+
+		if itemContent.ItemHash == hash {
+			return volumes[i], nil
 		}
 	}
 
@@ -600,34 +1012,41 @@ func (client *TwentySixClient) GetVolumeByItemHash(hash string) (Message, error)
 }
 
 func (client *TwentySixClient) ForgetMessage(hash string) (MessageResponse, error) {
-	now := float64(time.Now().UnixMilli()) / 1000
+	return client.ForgetMessages(client.ctx, []string{hash})
+}
+
+// ForgetMessages forgets all the messages with a single FORGET message.
+func (client *TwentySixClient) ForgetMessages(ctx context.Context, hashes []string) (MessageResponse, error) {
+	if len(hashes) == 0 {
+		return MessageResponse{}, errors.New("no message to forget")
+	}
+
+	now := client.now()
 
 	itemContent := ForgetMessageContent{
 		Address: client.account.Address,
 		Time:    now,
-		Hashes:  []string{hash},
+		Hashes:  hashes,
 	}
 
-	msgContent, err := json.Marshal(itemContent)
+	msgContent, err := marshalItemContent(itemContent)
 	if err != nil {
 		return MessageResponse{}, err
 	}
 
-	contentHash := sha256.Sum256(msgContent)
-
 	message := Message{
 		Type:    ForgetMessageType,
-		Chain:   EthereumChain,
+		Chain:   client.account.chain(),
 		Sender:  client.account.Address,
 		Time:    now,
 		Channel: client.channel,
 
-		ItemHash:    hex.EncodeToString(contentHash[:]),
+		ItemHash:    itemHash(msgContent),
 		ItemType:    InlineMessageItem,
 		ItemContent: string(msgContent),
 	}
 
-	message.SignMessage(client.account.PrivateKey)
+	message.SignMessage(client.account.signingKey())
 
 	req := BroadcastRequest{
 		Message: message,
@@ -639,8 +1058,8 @@ func (client *TwentySixClient) ForgetMessage(hash string) (MessageResponse, erro
 		return MessageResponse{}, err
 	}
 
-	storeEndpoint := AlephApiUrl + "/api/v0/messages"
-	request, err := http.NewRequest("POST", storeEndpoint, bytes.NewBuffer(buff))
+	storeEndpoint := AlephApiUrl + client.apiPath("/messages")
+	request, err := http.NewRequestWithContext(client.requestContext(ctx), "POST", storeEndpoint, bytes.NewBuffer(buff))
 	if err != nil {
 		return MessageResponse{}, err
 	}
@@ -658,21 +1077,49 @@ func (client *TwentySixClient) ForgetMessage(hash string) (MessageResponse, erro
 		return MessageResponse{}, err
 	}
 
-	// response, err := client.SendMessage(ForgetMessageType, itemContent)
-	// if err != nil {
-	// 	return MessageResponse{}, err
-	// }
-
 	var parsedRes MessageResponse
-	json.Unmarshal(resultBody, &parsedRes)
+	if err := json.Unmarshal(resultBody, &parsedRes); err != nil {
+		return MessageResponse{}, err
+	}
+
+	if err := parsedRes.publicationError("forget"); err != nil {
+		return MessageResponse{}, err
+	}
 
 	return parsedRes, nil
 }
 
+// DefaultHttpTimeout bounds every request but uploads, which are only bounded by the
+// operation context since large images take longer to send.
+const DefaultHttpTimeout = 60 * time.Second
+
 func NewTwentySixClient(acc TwentySixAccountState, channel string) TwentySixClient {
+	return NewTwentySixClientWithTimeout(acc, channel, DefaultHttpTimeout)
+}
+
+// NewTwentySixClientWithTimeout builds a client whose requests time out after timeout,
+// zero disabling it.
+func NewTwentySixClientWithTimeout(acc TwentySixAccountState, channel string, timeout time.Duration) TwentySixClient {
 	return TwentySixClient{
 		account: acc,
 		channel: channel,
-		http:    http.Client{},
+		ctx:     context.Background(),
+		http:    http.Client{Transport: newUserAgentTransport(defaultUserAgent()), Timeout: timeout},
+
+		confirmationPolling: LightConfirmationPolling,
+		readNodes:           DefaultReadNodes,
+		ipfsGateways:        DefaultIpfsGateways,
+
+		forgetTimeout:  DefaultForgetTimeout,
+		forgetInterval: DefaultForgetInterval,
+
+		apiVersion: DefaultApiVersion,
+
+		schedulerPollInterval: DefaultSchedulerPollInterval,
+		schedulerPollJitter:   DefaultSchedulerPollJitter,
+
+		propagationTimeout: DefaultPropagationTimeout,
+
+		detectContentType: true,
 	}
 }
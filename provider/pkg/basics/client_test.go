@@ -0,0 +1,290 @@
+package basics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGetMessageByHash(t *testing.T) {
+	responses := map[string]string{
+		"single":    `{"messages":[{"item_hash":"single"}],"pagination_total":1}`,
+		"missing":   `{"messages":[],"pagination_total":0}`,
+		"collision": `{"messages":[{"item_hash":"collision"},{"item_hash":"collision"}],"pagination_total":2}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(responses[r.URL.Query().Get("hashes")]))
+	}))
+	defer server.Close()
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+
+	message, err := client.getMessageByHash(server.URL, "single")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.ItemHash != "single" {
+		t.Fatalf("expected the single message, got %q", message.ItemHash)
+	}
+
+	if _, err := client.getMessageByHash(server.URL, "missing"); err == nil || err.Error() != "message not found" {
+		t.Fatalf("expected message not found, got %v", err)
+	}
+
+	if _, err := client.getMessageByHash(server.URL, "collision"); err == nil || !strings.HasPrefix(err.Error(), "2 messages found for hash collision") {
+		t.Fatalf("expected an error on several matches, got %v", err)
+	}
+}
+
+func TestRemainingMessages(t *testing.T) {
+	page := func(n int) []Message {
+		return make([]Message, n)
+	}
+
+	cases := []struct {
+		name      string
+		response  GetMessageResponse
+		remaining uint64
+	}{
+		{"empty", GetMessageResponse{Messages: page(0), PaginationPage: 1, PaginationPerPage: 20, PaginationTotal: 0}, 0},
+		{"exactly one page", GetMessageResponse{Messages: page(20), PaginationPage: 1, PaginationPerPage: 20, PaginationTotal: 20}, 0},
+		{"one more", GetMessageResponse{Messages: page(20), PaginationPage: 1, PaginationPerPage: 20, PaginationTotal: 21}, 1},
+		{"full last page", GetMessageResponse{Messages: page(20), PaginationPage: 2, PaginationPerPage: 20, PaginationTotal: 40}, 0},
+		{"partial last page", GetMessageResponse{Messages: page(5), PaginationPage: 3, PaginationPerPage: 20, PaginationTotal: 45}, 0},
+		{"no page size", GetMessageResponse{Messages: page(3), PaginationTotal: 3}, 0},
+	}
+	for i := 0; i < len(cases); i++ {
+		if remaining := remainingMessages(cases[i].response); remaining != cases[i].remaining {
+			t.Fatalf("%s: expected %d remaining, got %d", cases[i].name, cases[i].remaining, remaining)
+		}
+	}
+}
+
+func TestGetAllMessages(t *testing.T) {
+	for _, total := range []int{0, int(messagesPageSize), int(messagesPageSize) + 1, 2 * int(messagesPageSize)} {
+		requests := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+
+			var page int
+			fmt.Sscan(r.URL.Query().Get("page"), &page)
+			if r.URL.Query().Get("addresses") != "0xabc" {
+				t.Errorf("expected the address filter, got %q", r.URL.RawQuery)
+			}
+
+			messages := []string{}
+			for i := (page - 1) * int(messagesPageSize); i < page*int(messagesPageSize) && i < total; i++ {
+				messages = append(messages, fmt.Sprintf(`{"item_hash":"%d"}`, i))
+			}
+			fmt.Fprintf(w, `{"messages":[%s],"pagination_page":%d,"pagination_per_page":%d,"pagination_total":%d}`, strings.Join(messages, ","), page, messagesPageSize, total)
+		}))
+
+		transport, err := newFailoverTransport([]string{server.URL}, NoFailover, 0, http.DefaultTransport)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		client := NewTwentySixClient(TwentySixAccountState{}, "")
+		client.http.Transport = transport
+		client.readNodes = nil
+
+		messages, err := client.GetAllMessages(context.Background(), MessageFilter{Addresses: []string{"0xabc"}})
+		server.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(messages) != total {
+			t.Fatalf("expected %d messages, got %d", total, len(messages))
+		}
+		expectedRequests := max(1, (total+int(messagesPageSize)-1)/int(messagesPageSize))
+		if requests != expectedRequests {
+			t.Fatalf("%d messages: expected %d requests, got %d", total, expectedRequests, requests)
+		}
+	}
+}
+
+func TestGetAllMessagesCancelled(t *testing.T) {
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.GetAllMessages(ctx, MessageFilter{}); err == nil {
+		t.Fatal("expected the cancelled context to stop the listing")
+	}
+
+	// without a context the operation context of the client applies
+	client.ctx = ctx
+	if _, err := client.GetAllMessages(nil, MessageFilter{}); err == nil {
+		t.Fatal("expected the cancelled operation context to stop the listing")
+	}
+}
+
+func TestMessageFilterParams(t *testing.T) {
+	if params := (MessageFilter{}).params(); len(params) != 0 {
+		t.Fatalf("expected an empty filter to add no params, got %q", params.Encode())
+	}
+
+	filter := MessageFilter{
+		Addresses: []string{"0xabc"},
+		Refs:      []string{"ref1", "ref2"},
+		Tags:      []string{"web"},
+		StartTime: time.Unix(1700000000, 0),
+		EndTime:   time.Unix(1700003600, 0),
+	}
+	expected := "addresses=0xabc&endDate=1700003600&refs=ref1&refs=ref2&startDate=1700000000&tags=web"
+	if encoded := filter.params().Encode(); encoded != expected {
+		t.Fatalf("expected %q, got %q", expected, encoded)
+	}
+}
+
+func TestForgetMessages(t *testing.T) {
+	var forgotten [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			return
+		}
+
+		var request BroadcastRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Errorf("unable to decode the broadcast: %s", err)
+		}
+
+		var content ForgetMessageContent
+		json.Unmarshal([]byte(request.Message.ItemContent), &content)
+		forgotten = append(forgotten, content.Hashes)
+
+		w.Write([]byte(`{"publication_status":{"status":"success"},"message_status":"pending"}`))
+	}))
+	defer server.Close()
+
+	transport, err := newFailoverTransport([]string{server.URL}, NoFailover, 0, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := TwentySixAccountState{Address: "0xabc"}
+	account.PrivateKey = "0x" + strings.Repeat("01", 32)
+	client := NewTwentySixClient(account, "TEST")
+	client.http.Transport = transport
+
+	if _, err := client.ForgetMessages(context.Background(), []string{"first", "second"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(forgotten) != 1 || strings.Join(forgotten[0], ",") != "first,second" {
+		t.Fatalf("expected a single FORGET of both hashes, got %v", forgotten)
+	}
+
+	if _, err := client.ForgetMessages(context.Background(), []string{}); err == nil {
+		t.Fatal("expected an error when there is nothing to forget")
+	}
+}
+
+func TestBroadcastFailedPublication(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","hash":"stored","publication_status":{"status":"error","failed":["p2p","ipfs"]},"message_status":"pending"}`))
+	}))
+	defer server.Close()
+
+	transport, err := newFailoverTransport([]string{server.URL}, NoFailover, 0, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := TwentySixAccountState{Address: "0x1a642f0E3c3aF545E7AcBD38b07251B3990914F1"}
+	account.PrivateKey = "0x" + strings.Repeat("01", 32)
+	client := NewTwentySixClient(account, "TEST")
+	client.http.Transport = transport
+
+	file := filepath.Join(t.TempDir(), "image.squashfs")
+	if err := os.WriteFile(file, []byte("image"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	broadcasts := map[string]func() error{
+		"post": func() error {
+			_, err := client.SendMessage(PostMessageType, map[string]string{"content": "test"})
+			return err
+		},
+		"store": func() error {
+			_, _, err := client.StoreFile(file, nil)
+			return err
+		},
+		"instance": func() error {
+			_, _, err := client.CreateInstance(testInstanceArgs())
+			return err
+		},
+		"function": func() error {
+			_, _, err := client.CreateFunction(testFunctionArgs())
+			return err
+		},
+		"aggregate test": func() error {
+			_, _, err := client.CreateAggregate("test", map[string]interface{}{"value": 1})
+			return err
+		},
+		"forget": func() error {
+			_, err := client.ForgetMessages(context.Background(), []string{"first"})
+			return err
+		},
+	}
+
+	for kind, broadcast := range broadcasts {
+		err := broadcast()
+		if err == nil {
+			t.Fatalf("%s: expected a 200 with a failed publication to fail", kind)
+		}
+		if !strings.Contains(err.Error(), kind+" message") || !strings.Contains(err.Error(), "p2p, ipfs") {
+			t.Fatalf("%s: expected the failed nodes in the error, got %s", kind, err)
+		}
+	}
+}
+
+func TestStoreFilePublicationError(t *testing.T) {
+	if err := storeFilePublicationError([]byte(`{"status":"success","hash":"stored"}`), StoreIPFSFileResponse{Status: SucceedMessageStatus}); err != nil {
+		t.Fatalf("expected a stored file without publication status to succeed, got %s", err)
+	}
+	if err := storeFilePublicationError([]byte(`{"status":"error"}`), StoreIPFSFileResponse{Status: "error"}); err == nil {
+		t.Fatal("expected a failed upload to fail")
+	}
+}
+
+func TestWaitMessagesForgottenSharesTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/forgotten/status") {
+			w.Write([]byte(`{"status":"forgotten"}`))
+			return
+		}
+		w.Write([]byte(`{"status":"processed"}`))
+	}))
+	defer server.Close()
+
+	transport, err := newFailoverTransport([]string{server.URL}, NoFailover, 0, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+	client.http.Transport = transport
+
+	timeout := 200 * time.Millisecond
+	start := time.Now()
+	pending, err := client.WaitMessagesForgotten([]string{"first", "forgotten", "second", "third"}, WaitOptions{Timeout: timeout, Interval: 10 * time.Millisecond})
+	if !errors.Is(err, ErrForgetTimeout) {
+		t.Fatalf("expected a forget timeout, got %v", err)
+	}
+	if strings.Join(pending, ",") != "first,second,third" {
+		t.Fatalf("expected the messages still not forgotten, got %v", pending)
+	}
+	if elapsed := time.Since(start); elapsed > 2*timeout {
+		t.Fatalf("the messages must share one timeout, waited %s", elapsed)
+	}
+}
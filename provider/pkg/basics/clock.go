@@ -0,0 +1,66 @@
+package basics
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Offsets below the resolution of the Date header are not worth correcting.
+const minClockOffset = time.Second
+
+// SyncTime measures the offset between the local clock and the Aleph node clock
+// from the Date header of a lightweight request. The offset is then applied to
+// every message timestamp built by the client.
+func (client *TwentySixClient) SyncTime() error {
+	client.timeSynced = true
+
+	request, err := http.NewRequest("GET", AlephApiUrl+client.apiPath("/info/public.json"), nil)
+	if err != nil {
+		return err
+	}
+
+	sentAt := time.Now()
+	response, err := client.http.Do(request)
+	if err != nil {
+		return err
+	}
+	receivedAt := time.Now()
+
+	response.Body.Close()
+
+	date := response.Header.Get("Date")
+	if date == "" {
+		return errors.New("aleph node did not return a Date header")
+	}
+
+	serverTime, err := http.ParseTime(date)
+	if err != nil {
+		return err
+	}
+
+	localTime := sentAt.Add(receivedAt.Sub(sentAt) / 2)
+	offset := serverTime.Sub(localTime)
+	if offset > -minClockOffset && offset < minClockOffset {
+		offset = 0
+	}
+
+	client.timeOffset = offset
+	if offset != 0 {
+		log.Printf("local clock is %s off the aleph node clock, adjusting message timestamps", offset)
+	}
+
+	return nil
+}
+
+// now returns the current time as an Aleph timestamp, synchronizing the clock lazily once per client.
+func (client *TwentySixClient) now() float64 {
+	if !client.timeSynced {
+		if err := client.SyncTime(); err != nil {
+			log.Println("unable to synchronize time with aleph node: ", err.Error())
+		}
+	}
+
+	return float64(time.Now().Add(client.timeOffset).UnixMilli()) / 1000
+}
@@ -0,0 +1,312 @@
+package basics
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi-go-provider/infer"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+)
+
+type ConfirmationPolling string
+
+const (
+	// Poll the lightweight status endpoint until the message is processed, then the
+	// full message until its on-chain confirmation.
+	LightConfirmationPolling ConfirmationPolling = "light"
+	// Fetch the full message on every poll and wait for its on-chain confirmation.
+	HeavyConfirmationPolling ConfirmationPolling = "heavy"
+)
+
+// TwentySixConfig holds the provider wide configuration.
+type TwentySixConfig struct {
+	ConfirmationPolling ConfirmationPolling `pulumi:"confirmationPolling,optional"`
+	// End the confirmation waits once aleph processed the message, without waiting for
+	// its on-chain confirmation.
+	ConfirmOnProcessed bool `pulumi:"confirmOnProcessed,optional"`
+
+	// Aleph API endpoints requests are sent to, in order of priority, and when requests
+	// move on to the next one: "none", "connection" on connection failures, or
+	// "serverErrors" (the default) after failoverRetries server errors in a row as well.
+	ApiUrls         []string       `pulumi:"apiUrls,optional"`
+	Failover        FailoverPolicy `pulumi:"failover,optional"`
+	FailoverRetries int            `pulumi:"failoverRetries,optional"`
+
+	// Times a request failing with a server or network error is sent, with an
+	// exponential backoff, before giving up. Defaults to 5, 1 disables the retries.
+	RetryAttempts int `pulumi:"retryAttempts,optional"`
+
+	// Aleph nodes reads fall back to, in order, when a message is not found yet
+	// on the load balancer right after it was broadcast.
+	ReadNodes []string `pulumi:"readNodes,optional"`
+
+	// IPFS gateways tried in order to download content or check its availability.
+	IpfsGateways []string `pulumi:"ipfsGateways,optional"`
+
+	// Seconds Delete waits for a message to be forgotten, and between two status polls.
+	// Resources can override both with their own forgetTimeout and forgetInterval.
+	ForgetTimeout  int64 `pulumi:"forgetTimeout,optional"`
+	ForgetInterval int64 `pulumi:"forgetInterval,optional"`
+	// Log a warning instead of failing the destroy when the forget wait times out.
+	ForgetTimeoutWarning bool `pulumi:"forgetTimeoutWarning,optional"`
+
+	// Aleph API version used to build endpoint paths, v0 by default.
+	ApiVersion string `pulumi:"apiVersion,optional"`
+
+	// Stamp the provider name and version in the metadata of created messages, under
+	// the pulumi_provider and pulumi_provider_version keys. Disabled by default.
+	StampProviderMetadata bool `pulumi:"stampProviderMetadata,optional"`
+
+	// Remove the volume images left in /tmp by crashed runs when the provider starts.
+	// Enabled by default, images older than tempFilesMaxAge seconds (a day) are removed.
+	CleanupTempFiles *bool `pulumi:"cleanupTempFiles,optional"`
+	TempFilesMaxAge  int64 `pulumi:"tempFilesMaxAge,optional"`
+
+	// Message contents larger than this many bytes are uploaded to the storage engine and
+	// broadcast with the "storage" item type instead of inline. Zero keeps them inline.
+	StorageContentThreshold int64 `pulumi:"storageContentThreshold,optional"`
+
+	// User-Agent sent with every request, pulumi-twentysix/<version> by default.
+	UserAgent string `pulumi:"userAgent,optional"`
+
+	// Seconds between two scheduler allocation polls, plus a random jitter of up to
+	// schedulerPollJitter seconds. Defaults to 10 and 5, a negative jitter disables it.
+	SchedulerPollInterval int64 `pulumi:"schedulerPollInterval,optional"`
+	SchedulerPollJitter   int64 `pulumi:"schedulerPollJitter,optional"`
+
+	// Replace volumes, functions and instances on any input change instead of
+	// amending them in place. Resources can override it with their own strictReplace.
+	StrictReplace bool `pulumi:"strictReplace,optional"`
+
+	// Seconds to wait for an uploaded file's STORE message to be readable from the API.
+	// Defaults to 60.
+	PropagationTimeout int64 `pulumi:"propagationTimeout,optional"`
+
+	// Number of nodes, among the API and the read nodes, which must serve a new message
+	// before Create returns. Zero disables the verification.
+	VerifyQuorum int `pulumi:"verifyQuorum,optional"`
+
+	// Directory keeping built volume images by content, so that packing an identical
+	// folder with the same options again reuses the image. Images are never evicted.
+	BuildCacheDir string `pulumi:"buildCacheDir,optional"`
+	// Maximum number of volume images built at the same time, the number of CPUs by default.
+	MaxConcurrentBuilds int `pulumi:"maxConcurrentBuilds,optional"`
+
+	// Send uploaded files with the content type of their extension or content, so that
+	// gateways serve them with the right MIME type. Enabled by default, volume images
+	// are always sent as application/octet-stream.
+	DetectContentType *bool `pulumi:"detectContentType,optional"`
+
+	// ALEPH the account signing a new volume, function or instance must have available,
+	// checked once per account before its first creation. Zero disables the check.
+	RequireMinBalance float64 `pulumi:"requireMinBalance,optional"`
+
+	// Seconds a request to Aleph may take, 60 by default. Uploads are only bounded by
+	// operationTimeout.
+	HttpTimeout int64 `pulumi:"httpTimeout,optional"`
+
+	// Seconds a single create, read, update, diff or delete of a resource may take,
+	// requests and waits included. Zero only bounds operations by the Pulumi deadline.
+	OperationTimeout int64 `pulumi:"operationTimeout,optional"`
+}
+
+func (config TwentySixConfig) Configure(ctx p.Context) error {
+	switch config.ConfirmationPolling {
+	case "", LightConfirmationPolling, HeavyConfirmationPolling:
+	default:
+		return fmt.Errorf("invalid confirmationPolling %q: expected %q or %q", config.ConfirmationPolling, LightConfirmationPolling, HeavyConfirmationPolling)
+	}
+
+	for _, node := range config.ReadNodes {
+		if _, err := url.ParseRequestURI(node); err != nil {
+			return fmt.Errorf("invalid read node %q: %w", node, err)
+		}
+	}
+
+	for _, apiUrl := range config.ApiUrls {
+		if _, err := url.ParseRequestURI(apiUrl); err != nil {
+			return fmt.Errorf("invalid api url %q: %w", apiUrl, err)
+		}
+	}
+
+	switch config.Failover {
+	case "", NoFailover, ConnectionFailover, ServerErrorFailover:
+	default:
+		return fmt.Errorf("invalid failover %q: expected %q, %q or %q", config.Failover, NoFailover, ConnectionFailover, ServerErrorFailover)
+	}
+
+	if config.FailoverRetries < 0 {
+		return errors.New("failoverRetries can't be negative")
+	}
+
+	if config.RetryAttempts < 0 {
+		return errors.New("retryAttempts can't be negative")
+	}
+
+	for _, gateway := range config.IpfsGateways {
+		if _, err := url.ParseRequestURI(gateway); err != nil {
+			return fmt.Errorf("invalid ipfs gateway %q: %w", gateway, err)
+		}
+	}
+
+	if config.ApiVersion != "" && !slices.Contains(SupportedApiVersions, config.ApiVersion) {
+		return fmt.Errorf("invalid apiVersion %q: expected one of %v", config.ApiVersion, SupportedApiVersions)
+	}
+
+	if config.ForgetTimeout < 0 || config.ForgetInterval < 0 {
+		return errors.New("forgetTimeout and forgetInterval can't be negative")
+	}
+
+	if config.StorageContentThreshold < 0 {
+		return errors.New("storageContentThreshold can't be negative")
+	}
+
+	if config.VerifyQuorum < 0 || config.VerifyQuorum > len(config.ReadNodes)+1 {
+		return fmt.Errorf("verifyQuorum must be between 0 and %d, the API and the read nodes", len(config.ReadNodes)+1)
+	}
+
+	if config.MaxConcurrentBuilds < 0 {
+		return errors.New("maxConcurrentBuilds can't be negative")
+	}
+
+	if config.MaxConcurrentBuilds > 0 {
+		setMaxConcurrentBuilds(config.MaxConcurrentBuilds)
+	}
+
+	if config.PropagationTimeout < 0 {
+		return errors.New("propagationTimeout can't be negative")
+	}
+
+	if config.RequireMinBalance < 0 {
+		return errors.New("requireMinBalance can't be negative")
+	}
+
+	if config.HttpTimeout < 0 {
+		return errors.New("httpTimeout can't be negative")
+	}
+
+	if config.OperationTimeout < 0 {
+		return errors.New("operationTimeout can't be negative")
+	}
+
+	if config.SchedulerPollInterval < 0 {
+		return errors.New("schedulerPollInterval can't be negative")
+	}
+
+	if config.TempFilesMaxAge < 0 {
+		return errors.New("tempFilesMaxAge can't be negative")
+	}
+
+	if config.CleanupTempFiles == nil || *config.CleanupTempFiles {
+		maxAge := config.TempFilesMaxAge
+		if maxAge == 0 {
+			maxAge = DefaultTempFilesMaxAge
+		}
+
+		removed, err := cleanupSquashfsTempFiles(maxAge)
+		if err != nil {
+			ctx.Logf(diag.Warning, "unable to remove orphaned volume images: %s", err)
+		}
+		for _, path := range removed {
+			ctx.Logf(diag.Debug, "removed orphaned volume image %s", path)
+		}
+	}
+
+	return nil
+}
+
+// newApiTransport wraps base with the failover transport routing the Aleph API requests,
+// and the retry transport. Retries are only made by the latter: every attempt goes to
+// the current failover endpoint, so a failing request is sent retryAttempts times.
+func newApiTransport(config TwentySixConfig, base http.RoundTripper) (*failoverTransport, http.RoundTripper) {
+	apiUrls := DefaultApiUrls
+	if len(config.ApiUrls) > 0 {
+		apiUrls = config.ApiUrls
+	}
+
+	// the urls were validated by Configure
+	failover, err := newFailoverTransport(apiUrls, config.Failover, config.FailoverRetries, base)
+	if err != nil {
+		return nil, newRetryTransport(config.RetryAttempts, base)
+	}
+
+	return failover, newRetryTransport(config.RetryAttempts, failover)
+}
+
+// NewConfiguredClient builds a client honoring the provider configuration.
+func NewConfiguredClient(ctx p.Context, acc TwentySixAccountState, channel string) TwentySixClient {
+	config := infer.GetConfig[TwentySixConfig](ctx)
+
+	timeout := DefaultHttpTimeout
+	if config.HttpTimeout > 0 {
+		timeout = time.Duration(config.HttpTimeout) * time.Second
+	}
+
+	client := NewTwentySixClientWithTimeout(acc, channel, timeout)
+	if config.ConfirmationPolling != "" {
+		client.confirmationPolling = config.ConfirmationPolling
+	}
+	client.confirmOnProcessed = config.ConfirmOnProcessed
+
+	if len(config.ReadNodes) > 0 {
+		client.readNodes = config.ReadNodes
+	}
+
+	if len(config.IpfsGateways) > 0 {
+		client.ipfsGateways = config.IpfsGateways
+	}
+
+	if config.ForgetTimeout > 0 {
+		client.forgetTimeout = config.ForgetTimeout
+	}
+
+	if config.ForgetInterval > 0 {
+		client.forgetInterval = config.ForgetInterval
+	}
+
+	client.forgetTimeoutWarning = config.ForgetTimeoutWarning
+
+	if config.ApiVersion != "" {
+		client.apiVersion = config.ApiVersion
+	}
+
+	client.stampProviderMetadata = config.StampProviderMetadata
+	client.storageContentThreshold = config.StorageContentThreshold
+
+	if config.PropagationTimeout > 0 {
+		client.propagationTimeout = config.PropagationTimeout
+	}
+
+	if config.DetectContentType != nil {
+		client.detectContentType = *config.DetectContentType
+	}
+
+	client.verifyQuorum = config.VerifyQuorum
+	client.buildCacheDir = config.BuildCacheDir
+
+	if config.SchedulerPollInterval > 0 {
+		client.schedulerPollInterval = config.SchedulerPollInterval
+	}
+
+	if config.SchedulerPollJitter > 0 {
+		client.schedulerPollJitter = config.SchedulerPollJitter
+	} else if config.SchedulerPollJitter < 0 {
+		client.schedulerPollJitter = 0
+	}
+
+	if config.UserAgent != "" {
+		client.http.Transport = newUserAgentTransport(config.UserAgent)
+	}
+
+	client.failover, client.http.Transport = newApiTransport(config, client.http.Transport)
+
+	client.ctx = ctx
+	client.http.Transport = &contextTransport{ctx: ctx, base: client.http.Transport}
+
+	return client
+}
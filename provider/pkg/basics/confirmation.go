@@ -0,0 +1,29 @@
+package basics
+
+const (
+	DefaultConfirmationTimeout  int64 = 120
+	DefaultConfirmationInterval int64 = 5
+)
+
+// ignoreMessageNotFound returns nil for the error of a message a node doesn't know yet.
+func ignoreMessageNotFound(err error) error {
+	if err != nil && err.Error() == "message not found" {
+		return nil
+	}
+
+	return err
+}
+
+// waitConfirmation waits for the message to be processed, the timeout and interval are in
+// seconds and default to DefaultConfirmationTimeout and DefaultConfirmationInterval.
+func waitConfirmation(client *TwentySixClient, hash string, timeout int64, interval int64) error {
+	if timeout == 0 {
+		timeout = DefaultConfirmationTimeout
+	}
+
+	if interval == 0 {
+		interval = DefaultConfirmationInterval
+	}
+
+	return client.WaitMessageConfirmation(hash, secondsWaitOptions(timeout, interval))
+}
@@ -0,0 +1,77 @@
+package basics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitMessageConfirmationNotFoundYet(t *testing.T) {
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/status") {
+			w.Write([]byte(`{"messages":[{"item_hash":"late","confirmed":true}],"pagination_total":1}`))
+			return
+		}
+
+		polls++
+		if polls <= 2 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"status":"processed","item_hash":"late"}`))
+	}))
+	defer server.Close()
+
+	transport, err := newFailoverTransport([]string{server.URL}, NoFailover, 0, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+	client.http.Transport = transport
+	client.readNodes = nil
+
+	if err := client.WaitMessageConfirmation("late", WaitOptions{Timeout: 5 * time.Second, Interval: time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	if polls != 3 {
+		t.Fatalf("expected 3 status polls, got %d", polls)
+	}
+}
+
+func TestWaitMessageConfirmationOnProcessed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/status") {
+			w.Write([]byte(`{"messages":[{"item_hash":"processed"}],"pagination_total":1}`))
+			return
+		}
+		w.Write([]byte(`{"status":"processed","item_hash":"processed"}`))
+	}))
+	defer server.Close()
+
+	transport, err := newFailoverTransport([]string{server.URL}, NoFailover, 0, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, polling := range []ConfirmationPolling{LightConfirmationPolling, HeavyConfirmationPolling} {
+		client := NewTwentySixClient(TwentySixAccountState{}, "")
+		client.http.Transport = transport
+		client.readNodes = nil
+		client.confirmationPolling = polling
+
+		// a processed message still waits for its on-chain confirmation by default
+		options := WaitOptions{Timeout: 50 * time.Millisecond, Interval: time.Millisecond}
+		if err := client.WaitMessageConfirmation("processed", options); err == nil {
+			t.Fatalf("%s: expected the unconfirmed message to time out", polling)
+		}
+
+		client.confirmOnProcessed = true
+		if err := client.WaitMessageConfirmation("processed", options); err != nil {
+			t.Fatalf("%s: expected the processed message to be enough, got %s", polling, err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package basics
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+)
+
+// GetVolumeConsumers lists the INSTANCE and PROGRAM messages of the account, on any
+// channel, mounting the volume either as a rootfs parent, a code, runtime or data
+// volume, or an immutable volume.
+func (client *TwentySixClient) GetVolumeConsumers(volumeHash string) ([]Message, error) {
+	var consumers []Message
+
+	messages, err := client.GetAllMessages(client.ctx, MessageFilter{
+		Addresses:    []string{client.account.Address},
+		MessageTypes: []MessageType{InstanceMessageType, ProgramMessageType},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(messages); i++ {
+		if referencesHash(messageContent(messages[i]), volumeHash) {
+			consumers = append(consumers, messages[i])
+		}
+	}
+
+	return consumers, nil
+}
+
+// referencesHash walks the message content looking for a "ref" equal to the hash.
+func referencesHash(itemContent []byte, hash string) bool {
+	var content interface{}
+	if err := json.Unmarshal(itemContent, &content); err != nil {
+		return false
+	}
+
+	return containsRef(content, hash)
+}
+
+func containsRef(value interface{}, hash string) bool {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if ref, ok := field.(string); ok && key == "ref" && ref == hash {
+				return true
+			}
+			if containsRef(field, hash) {
+				return true
+			}
+		}
+	case []interface{}:
+		for i := 0; i < len(value); i++ {
+			if containsRef(value[i], hash) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// checkVolumeConsumers warns when VMs still mount the volume about to be forgotten,
+// or refuses to forget it when protectReferenced is set.
+func checkVolumeConsumers(ctx p.Context, client *TwentySixClient, volumeHash string, protectReferenced bool) error {
+	consumers, err := client.GetVolumeConsumers(volumeHash)
+	if err != nil {
+		if protectReferenced {
+			return fmt.Errorf("can't list the consumers of volume %s: %w", volumeHash, err)
+		}
+		ctx.Logf(diag.Warning, "can't list the consumers of volume %s: %s", volumeHash, err.Error())
+		return nil
+	}
+
+	if len(consumers) == 0 {
+		return nil
+	}
+
+	hashes := make([]string, len(consumers))
+	for i := 0; i < len(consumers); i++ {
+		hashes[i] = consumers[i].ItemHash
+	}
+
+	if protectReferenced {
+		return fmt.Errorf("volume %s is still used by %s", volumeHash, strings.Join(hashes, ", "))
+	}
+
+	ctx.Logf(diag.Warning, "volume %s is still used by %s", volumeHash, strings.Join(hashes, ", "))
+	return nil
+}
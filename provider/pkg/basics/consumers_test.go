@@ -0,0 +1,22 @@
+package basics
+
+import "testing"
+
+func TestReferencesHash(t *testing.T) {
+	const hash = "6e30de68c6cedfa6b45240c2b51e52495ac6fb1bd4b36457b3d5ca307594d595"
+
+	contents := map[string]bool{
+		`{"rootfs":{"parent":{"ref":"` + hash + `"}},"volumes":[]}`:                true,
+		`{"volumes":[{"mount":"/data","ref":"` + hash + `","use_latest":true}]}`:   true,
+		`{"code":{"ref":"` + hash + `","encoding":"squashfs"},"volumes":[]}`:       true,
+		`{"volumes":[{"mount":"/data","ref":"other"}],"metadata":{"ref":"other"}}`: false,
+		`{"metadata":{"name":"` + hash + `"}}`:                                     false,
+		``:                                                                         false,
+	}
+
+	for content, expected := range contents {
+		if referencesHash([]byte(content), hash) != expected {
+			t.Errorf("expected %v for %s", expected, content)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package basics
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// marshalItemContent serializes a message content the way the Aleph JS SDK does with
+// JSON.stringify, so that identical contents get identical item hashes from both SDKs.
+// encoding/json escapes <, > and & as well as U+2028 and U+2029, JSON.stringify
+// writes them as is. Fields are written in struct declaration order.
+func marshalItemContent(content interface{}) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+
+	encoder := json.NewEncoder(buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(content); err != nil {
+		return nil, err
+	}
+
+	encoded := bytes.TrimSuffix(buffer.Bytes(), []byte("\n"))
+	return unescapeLineSeparators(encoded), nil
+}
+
+// unescapeLineSeparators turns the \u2028 and \u2029 escapes encoding/json always
+// writes back into the raw characters, leaving escaped backslashes alone.
+func unescapeLineSeparators(encoded []byte) []byte {
+	if !bytes.Contains(encoded, []byte(`\u202`)) {
+		return encoded
+	}
+
+	result := make([]byte, 0, len(encoded))
+	for i := 0; i < len(encoded); i++ {
+		if encoded[i] == '\\' && i+1 < len(encoded) {
+			if i+5 < len(encoded) && string(encoded[i+1:i+5]) == "u202" && (encoded[i+5] == '8' || encoded[i+5] == '9') {
+				if encoded[i+5] == '8' {
+					result = append(result, "\u2028"...)
+				} else {
+					result = append(result, "\u2029"...)
+				}
+				i += 5
+				continue
+			}
+
+			// keep any other escape sequence, including an escaped backslash, whole
+			result = append(result, encoded[i], encoded[i+1])
+			i++
+			continue
+		}
+
+		result = append(result, encoded[i])
+	}
+
+	return result
+}
+
+// itemHash is the hash of an inline or storage item content, its hex sha256.
+func itemHash(itemContent []byte) string {
+	hash := sha256.Sum256(itemContent)
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,59 @@
+package basics
+
+import "testing"
+
+// The expected contents and hashes come from JSON.stringify and a sha256 of the same
+// objects in node, as the Aleph JS SDK computes them.
+func TestItemContentMatchesJsSdk(t *testing.T) {
+	cases := []struct {
+		content  interface{}
+		expected string
+		hash     string
+	}{
+		{
+			content: StoreMessageContent{
+				Address:  "0x5B3Fd3c1f2B4A1f6A2c7f1bDf3E1E4b0A7c9d2E1",
+				Time:     1700000000.5,
+				ItemType: StorageMessageItem,
+				ItemHash: "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+				Metadata: map[string]string{"name": "<web> & assets\u2028v2"},
+			},
+			expected: `{"address":"0x5B3Fd3c1f2B4A1f6A2c7f1bDf3E1E4b0A7c9d2E1","time":1700000000.5,"item_type":"storage","item_hash":"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855","metadata":{"name":"<web> & assets` + "\u2028" + `v2"}}`,
+			hash:     "b45669067fcd4484a0b9e8bab1c1c3420021e92a814683add7756c30b5baa8c8",
+		},
+		{
+			content: ForgetMessageContent{
+				Address: "0x5B3Fd3c1f2B4A1f6A2c7f1bDf3E1E4b0A7c9d2E1",
+				Time:    1700000000.25,
+				Hashes:  []string{"d51f34748974a1e652becd28c28249c2eb5a0cfaf8b718dde7121034d5733981"},
+			},
+			expected: `{"address":"0x5B3Fd3c1f2B4A1f6A2c7f1bDf3E1E4b0A7c9d2E1","time":1700000000.25,"hashes":["d51f34748974a1e652becd28c28249c2eb5a0cfaf8b718dde7121034d5733981"]}`,
+			hash:     "aed594f916cbd106a6a8661f42d93fd79e455f04494f067f9ea11952d798c1ce",
+		},
+	}
+
+	for _, c := range cases {
+		content, err := marshalItemContent(c.content)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(content) != c.expected {
+			t.Errorf("expected content\n%s\ngot\n%s", c.expected, content)
+		}
+		if hash := itemHash(content); hash != c.hash {
+			t.Errorf("expected item hash %s, got %s", c.hash, hash)
+		}
+	}
+}
+
+func TestUnescapeLineSeparatorsKeepsEscapedBackslashes(t *testing.T) {
+	content, err := marshalItemContent(map[string]string{"text": `\u2028` + "\u2028"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := `{"text":"\\u2028` + "\u2028" + `"}`; string(content) != expected {
+		t.Fatalf("expected %s, got %s", expected, content)
+	}
+}
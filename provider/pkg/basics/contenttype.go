@@ -0,0 +1,61 @@
+package basics
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const defaultContentType = "application/octet-stream"
+
+// fileContentType is the MIME type of an uploaded file, from its extension or else
+// from its first bytes. Volume images are always sent as binary content.
+func fileContentType(path string) (string, error) {
+	extension := strings.ToLower(filepath.Ext(path))
+	if extension == squashfsTempSuffix {
+		return defaultContentType, nil
+	}
+
+	if contentType := mime.TypeByExtension(extension); contentType != "" {
+		return contentType, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	read, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+
+	return http.DetectContentType(head[:read]), nil
+}
+
+// createFilePart adds the file part of an upload, with the detected content type when
+// the client detects them and as binary content otherwise.
+func (client *TwentySixClient) createFilePart(writer *multipart.Writer, path string) (io.Writer, error) {
+	contentType := defaultContentType
+	if client.detectContentType {
+		detected, err := fileContentType(path)
+		if err != nil {
+			return nil, err
+		}
+		contentType = detected
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, filepath.Base(path)))
+	header.Set("Content-Type", contentType)
+
+	return writer.CreatePart(header)
+}
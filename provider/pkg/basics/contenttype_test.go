@@ -0,0 +1,37 @@
+package basics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileContentType(t *testing.T) {
+	folder := t.TempDir()
+
+	files := map[string]string{
+		"image.squashfs": "<html></html>",
+		"index.html":     "plain",
+		"data":           "%PDF-1.7",
+	}
+	expected := map[string]string{
+		"image.squashfs": "application/octet-stream",
+		"index.html":     "text/html; charset=utf-8",
+		"data":           "application/pdf",
+	}
+
+	for name, content := range files {
+		path := filepath.Join(folder, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		contentType, err := fileContentType(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if contentType != expected[name] {
+			t.Fatalf("expected %s for %s, got %s", expected[name], name, contentType)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package basics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+)
+
+type MessagePriceResponse struct {
+	RequiredTokens float64     `json:"required_tokens"`
+	PaymentType    PaymentType `json:"payment_type"`
+}
+
+// TwentySixResourceCost is the ALEPH cost aleph computed for an accepted message.
+type TwentySixResourceCost struct {
+	PaymentType PaymentType `pulumi:"paymentType"`
+	// ALEPH held on the sender balance, for hold payments.
+	HoldAmount float64 `pulumi:"holdAmount"`
+	// ALEPH streamed per second, for superfluid payments.
+	StreamRate float64 `pulumi:"streamRate"`
+}
+
+// GetMessagePrice fetches the cost of a processed message.
+func (client *TwentySixClient) GetMessagePrice(hash string) (MessagePriceResponse, error) {
+	priceEndpoint := AlephApiUrl + client.apiPath("/price/") + hash
+	request, err := http.NewRequest("GET", priceEndpoint, nil)
+	if err != nil {
+		return MessagePriceResponse{}, err
+	}
+
+	request.Header.Add("Accept", "application/json")
+
+	response, err := client.http.Do(request)
+	if err != nil {
+		return MessagePriceResponse{}, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return MessagePriceResponse{}, fmt.Errorf("unable to get the price of message %s: %s", hash, response.Status)
+	}
+
+	resultBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return MessagePriceResponse{}, err
+	}
+
+	var result MessagePriceResponse
+	if err := json.Unmarshal(resultBody, &result); err != nil {
+		return MessagePriceResponse{}, err
+	}
+
+	return result, nil
+}
+
+// resourceCost reads the committed cost of a created message. The resource exists at this
+// point, so a price that can't be read is only reported as a warning.
+func resourceCost(ctx p.Context, client *TwentySixClient, hash string) *TwentySixResourceCost {
+	price, err := client.GetMessagePrice(hash)
+	if err != nil {
+		ctx.Logf(diag.Warning, "unable to read the cost of message %s: %s", hash, err)
+		return nil
+	}
+
+	cost := &TwentySixResourceCost{PaymentType: price.PaymentType}
+	if price.PaymentType == SuperfluidPaymentType {
+		cost.StreamRate = price.RequiredTokens
+	} else {
+		cost.HoldAmount = price.RequiredTokens
+	}
+
+	return cost
+}
@@ -0,0 +1,265 @@
+package basics
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	p "github.com/pulumi/pulumi-go-provider"
+)
+
+// CRNAuthToken authenticates operations against the CRN hosting a VM.
+//
+// The account signs an ephemeral P-256 public key once, and every operation
+// is then signed with the ephemeral key, as the aleph-vm supervisor expects.
+type CRNAuthToken struct {
+	NodeUrl string
+	Domain  string
+
+	signedPubKey string
+	ephemeralKey *ecdsa.PrivateKey
+}
+
+type crnPubKeyPayload struct {
+	PubKey  map[string]string `json:"pubkey"`
+	Alg     string            `json:"alg"`
+	Domain  string            `json:"domain"`
+	Address string            `json:"address"`
+	Expires string            `json:"expires"`
+}
+
+type crnSignedPubKey struct {
+	Sender    string            `json:"sender"`
+	Payload   string            `json:"payload"`
+	Signature string            `json:"signature"`
+	Content   map[string]string `json:"content"`
+}
+
+type crnOperationPayload struct {
+	Time   string `json:"time"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Domain string `json:"domain"`
+}
+
+type crnSignedOperation struct {
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+func (client *TwentySixClient) BuildCRNAuthToken(vmHash string) (CRNAuthToken, error) {
+	allocation, err := client.GetInstanceState(vmHash)
+	if err != nil {
+		return CRNAuthToken{}, err
+	}
+
+	if allocation.Node.Url == "" {
+		return CRNAuthToken{}, errors.New("vm " + vmHash + " is not allocated on any node")
+	}
+
+	nodeUrl, err := url.Parse(strings.TrimSuffix(allocation.Node.Url, "/"))
+	if err != nil {
+		return CRNAuthToken{}, err
+	}
+
+	ephemeralKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return CRNAuthToken{}, err
+	}
+
+	pubKeyPayload, err := json.Marshal(crnPubKeyPayload{
+		PubKey: map[string]string{
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(ephemeralKey.PublicKey.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(ephemeralKey.PublicKey.Y.FillBytes(make([]byte, 32))),
+		},
+		Alg:     "ECDSA",
+		Domain:  nodeUrl.Hostname(),
+		Address: client.account.Address,
+		Expires: time.Now().UTC().Add(24*time.Hour).Format("2006-01-02T15:04:05.000000") + "Z",
+	})
+	if err != nil {
+		return CRNAuthToken{}, err
+	}
+
+	hexPayload := hex.EncodeToString(pubKeyPayload)
+	signature, err := signPayload(client.account.signingKey(), []byte(hexPayload))
+	if err != nil {
+		return CRNAuthToken{}, err
+	}
+
+	signedPubKey, err := json.Marshal(crnSignedPubKey{
+		Sender:    client.account.Address,
+		Payload:   hexPayload,
+		Signature: signature,
+		Content:   map[string]string{"domain": nodeUrl.Hostname()},
+	})
+	if err != nil {
+		return CRNAuthToken{}, err
+	}
+
+	return CRNAuthToken{
+		NodeUrl:      nodeUrl.String(),
+		Domain:       nodeUrl.Hostname(),
+		signedPubKey: string(signedPubKey),
+		ephemeralKey: ephemeralKey,
+	}, nil
+}
+
+func (token CRNAuthToken) signOperation(method string, path string) (string, error) {
+	payload, err := json.Marshal(crnOperationPayload{
+		Time:   time.Now().UTC().Format("2006-01-02T15:04:05.000000") + "Z",
+		Method: method,
+		Path:   path,
+		Domain: token.Domain,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.Sum256(payload)
+	r, s, err := ecdsa.Sign(rand.Reader, token.ephemeralKey, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	signature := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+	signedOperation, err := json.Marshal(crnSignedOperation{
+		Payload:   hex.EncodeToString(payload),
+		Signature: hex.EncodeToString(signature),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(signedOperation), nil
+}
+
+func (client *TwentySixClient) controlInstance(vmHash string, operation string) error {
+	token, err := client.BuildCRNAuthToken(vmHash)
+	if err != nil {
+		return err
+	}
+
+	path := "/control/machine/" + vmHash + "/" + operation
+	signedOperation, err := token.signOperation("POST", path)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest("POST", token.NodeUrl+path, nil)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Add("X-SignedPubKey", token.signedPubKey)
+	request.Header.Add("X-SignedOperation", signedOperation)
+
+	response, err := client.http.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		resultBody, _ := io.ReadAll(response.Body)
+		return fmt.Errorf("%s of vm %s failed with status %d: %s", operation, vmHash, response.StatusCode, string(resultBody))
+	}
+
+	return nil
+}
+
+func (client *TwentySixClient) RebootInstance(vmHash string) error {
+	return client.controlInstance(vmHash, "reboot")
+}
+
+func (client *TwentySixClient) StopInstance(vmHash string) error {
+	return client.controlInstance(vmHash, "stop")
+}
+
+// StartInstance asks the allocated CRN to (re)start the VM. The notify endpoint
+// does not require authentication since the node checks the message itself.
+func (client *TwentySixClient) StartInstance(vmHash string) error {
+	allocation, err := client.GetInstanceState(vmHash)
+	if err != nil {
+		return err
+	}
+
+	if allocation.Node.Url == "" {
+		return errors.New("vm " + vmHash + " is not allocated on any node")
+	}
+
+	body, err := json.Marshal(map[string]string{"instance": vmHash})
+	if err != nil {
+		return err
+	}
+
+	endpoint := strings.TrimSuffix(allocation.Node.Url, "/") + "/control/allocation/notify"
+	request, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+
+	request.Header.Add("Content-Type", "application/json")
+	request.Header.Add("Accept", "application/json")
+
+	response, err := client.http.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		resultBody, _ := io.ReadAll(response.Body)
+		return fmt.Errorf("start of vm %s failed with status %d: %s", vmHash, response.StatusCode, string(resultBody))
+	}
+
+	return nil
+}
+
+// RebootInstance is a provider function cycling a VM without recreating its resource.
+type RebootInstance struct{}
+
+type RebootInstanceArgs struct {
+	Account TwentySixAccountState `pulumi:"account"`
+	VmHash  string                `pulumi:"vmHash"`
+}
+
+type RebootInstanceResult struct {
+	VmHash  string `pulumi:"vmHash"`
+	NodeUrl string `pulumi:"nodeUrl"`
+}
+
+func (RebootInstance) Call(ctx p.Context, args RebootInstanceArgs) (RebootInstanceResult, error) {
+	client := NewConfiguredClient(ctx, args.Account, "")
+
+	allocation, err := client.GetInstanceState(args.VmHash)
+	if err != nil {
+		return RebootInstanceResult{}, err
+	}
+
+	if err := client.RebootInstance(args.VmHash); err != nil {
+		return RebootInstanceResult{}, err
+	}
+
+	return RebootInstanceResult{
+		VmHash:  args.VmHash,
+		NodeUrl: allocation.Node.Url,
+	}, nil
+}
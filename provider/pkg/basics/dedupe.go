@@ -0,0 +1,172 @@
+package basics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+type volumeUploadResult struct {
+	Message    Message
+	FileHash   string
+	LocalHash  string
+	Size       int64
+	StoredSize int64
+	Manifest   []TwentySixVolumeManifestEntry
+	Stats      UploadStats
+}
+
+type volumeUpload struct {
+	done    chan struct{}
+	holders []string
+	result  volumeUploadResult
+	err     error
+}
+
+// Uploads in progress, keyed by volumeUploadKey. Identical volumes created at the
+// same time wait on the first upload and share its STORE message instead of
+// uploading again.
+var (
+	volumeUploadsMutex sync.Mutex
+	volumeUploads      = map[string]*volumeUpload{}
+)
+
+// volumeUploadKey identifies the content a volume would store: the same folder
+// packed with the same options, stored by the same sender on the same channel with
+// the same metadata. The same content on two channels is stored by two messages.
+func volumeUploadKey(sender string, folderHash string, args TwentySixVolumeArgs) (string, error) {
+	key, err := json.Marshal(struct {
+		Sender      string
+		Channel     string
+		FolderHash  string
+		Squashfs    squashfsOptions
+		Engine      MessageItemType
+		IpfsOptions *TwentySixVolumeIpfsOptions
+		Tags        map[string]string
+	}{sender, args.Channel, folderHash, args.squashfsOptions(), args.storageEngine(), args.IpfsOptions, args.Tags})
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(key)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// dedupeVolumeUpload runs upload once for the concurrent calls with the same key, and
+// returns the names of the volumes sharing its result, the caller included. Once the
+// upload is over the key is dropped, so that the holders of a message are all known
+// when it is returned.
+func dedupeVolumeUpload(key string, name string, upload func() (volumeUploadResult, error)) (volumeUploadResult, []string, error) {
+	volumeUploadsMutex.Lock()
+	if inflight, ok := volumeUploads[key]; ok {
+		inflight.holders = append(inflight.holders, name)
+		volumeUploadsMutex.Unlock()
+		<-inflight.done
+		return inflight.result, slices.Clone(inflight.holders), inflight.err
+	}
+
+	inflight := &volumeUpload{done: make(chan struct{}), holders: []string{name}}
+	volumeUploads[key] = inflight
+	volumeUploadsMutex.Unlock()
+
+	inflight.result, inflight.err = upload()
+
+	volumeUploadsMutex.Lock()
+	delete(volumeUploads, key)
+	volumeUploadsMutex.Unlock()
+	close(inflight.done)
+
+	return inflight.result, slices.Clone(inflight.holders), inflight.err
+}
+
+// Volumes sharing a STORE message released by a Delete, recorded on disk by message
+// hash. The holders of a message are kept in the state of each volume, the message is
+// forgotten once all of them are released.
+var releasedVolumesMutex sync.Mutex
+
+func releasedVolumesDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(cacheDir, "pulumi-twentysix", "released-volumes"), nil
+}
+
+func readReleasedVolumes(path string) ([]string, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var released []string
+	if err := json.Unmarshal(content, &released); err != nil {
+		return nil, err
+	}
+
+	return released, nil
+}
+
+// otherVolumeHolders returns the holders of a shared STORE message other than the volume name.
+func otherVolumeHolders(holders []string, name string) []string {
+	return slices.DeleteFunc(slices.Clone(holders), func(holder string) bool {
+		return holder == name
+	})
+}
+
+// releaseVolumeMessage records that the volume name no longer holds the STORE message
+// hash it shares with the volumes sharedWith, and returns the ones which may still
+// hold it. Releases made on another machine aren't known, the message is then kept.
+func releaseVolumeMessage(hash string, name string, sharedWith []string) ([]string, error) {
+	if len(sharedWith) == 0 {
+		return nil, nil
+	}
+
+	releasedVolumesMutex.Lock()
+	defer releasedVolumesMutex.Unlock()
+
+	dir, err := releasedVolumesDir()
+	if err != nil {
+		return sharedWith, err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return sharedWith, err
+	}
+
+	path := filepath.Join(dir, hash+".json")
+	released, err := readReleasedVolumes(path)
+	if err != nil {
+		return sharedWith, err
+	}
+
+	if !slices.Contains(released, name) {
+		released = append(released, name)
+	}
+
+	holders := slices.DeleteFunc(slices.Clone(sharedWith), func(holder string) bool {
+		return slices.Contains(released, holder)
+	})
+	if len(holders) == 0 {
+		err := os.Remove(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	content, err := json.Marshal(released)
+	if err != nil {
+		return holders, err
+	}
+
+	return holders, os.WriteFile(path, content, 0o600)
+}
@@ -0,0 +1,135 @@
+package basics
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDedupeVolumeUploadCoalesces(t *testing.T) {
+	var uploads int32
+	var wg sync.WaitGroup
+	hashes := make([]string, 8)
+	holders := make([][]string, len(hashes))
+
+	// the upload lasts until every volume joined it
+	joined := func() bool {
+		volumeUploadsMutex.Lock()
+		defer volumeUploadsMutex.Unlock()
+
+		return len(volumeUploads["coalesce"].holders) == len(hashes)
+	}
+
+	for i := 0; i < len(hashes); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			result, shared, err := dedupeVolumeUpload("coalesce", fmt.Sprint("volume", i), func() (volumeUploadResult, error) {
+				atomic.AddInt32(&uploads, 1)
+				for !joined() {
+					time.Sleep(time.Millisecond)
+				}
+				return volumeUploadResult{Message: Message{ItemHash: "shared"}}, nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+			hashes[i] = result.Message.ItemHash
+			holders[i] = shared
+		}(i)
+	}
+	wg.Wait()
+
+	if uploads != 1 {
+		t.Fatalf("expected a single upload, got %d", uploads)
+	}
+	for i := 0; i < len(hashes); i++ {
+		if hashes[i] != "shared" {
+			t.Fatalf("expected message hash shared, got %q", hashes[i])
+		}
+		if len(holders[i]) != len(hashes) || !slices.Contains(holders[i], fmt.Sprint("volume", i)) {
+			t.Fatalf("expected every volume to know all the holders, got %v", holders[i])
+		}
+	}
+
+	// a later volume doesn't share a message whose holders were already returned
+	result, shared, err := dedupeVolumeUpload("coalesce", "later", func() (volumeUploadResult, error) {
+		return volumeUploadResult{Message: Message{ItemHash: "later"}}, nil
+	})
+	if err != nil || result.Message.ItemHash != "later" || len(shared) != 1 {
+		t.Fatalf("expected a new upload held by the later volume only, got %v %v %v", result.Message.ItemHash, shared, err)
+	}
+}
+
+func TestDedupeVolumeUploadRetriesFailures(t *testing.T) {
+	_, _, err := dedupeVolumeUpload("failure", "data", func() (volumeUploadResult, error) {
+		return volumeUploadResult{}, errors.New("upload failed")
+	})
+	if err == nil {
+		t.Fatal("expected the first upload to fail")
+	}
+
+	result, _, err := dedupeVolumeUpload("failure", "data", func() (volumeUploadResult, error) {
+		return volumeUploadResult{Message: Message{ItemHash: "retried"}}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Message.ItemHash != "retried" {
+		t.Fatalf("expected message hash retried, got %q", result.Message.ItemHash)
+	}
+}
+
+func TestVolumeUploadKeyChannel(t *testing.T) {
+	args := TwentySixVolumeArgs{Channel: "TEST"}
+	first, err := volumeUploadKey("0xsender", "folder", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	args.Channel = "OTHER"
+	second, err := volumeUploadKey("0xsender", "folder", args)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first == second {
+		t.Fatal("expected volumes on different channels to have different upload keys")
+	}
+}
+
+func TestReleaseVolumeMessage(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	holders, err := releaseVolumeMessage("hash", "data", []string{"copy", "backup"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(holders) != 2 {
+		t.Fatalf("expected copy and backup to still hold the message, got %v", holders)
+	}
+
+	if holders, err = releaseVolumeMessage("hash", "copy", []string{"data", "backup"}); err != nil || len(holders) != 1 || holders[0] != "backup" {
+		t.Fatalf("expected backup to still hold the message, got %v %v", holders, err)
+	}
+
+	if holders, err = releaseVolumeMessage("hash", "backup", []string{"data", "copy"}); err != nil || len(holders) != 0 {
+		t.Fatalf("expected the last holder to forget the message, got %v %v", holders, err)
+	}
+
+	// a volume not sharing its message forgets it
+	if holders, err = releaseVolumeMessage("unshared", "data", nil); err != nil || len(holders) != 0 {
+		t.Fatalf("expected no holder, got %v %v", holders, err)
+	}
+
+	// releases made on another machine aren't recorded here, the message is kept
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	if holders, err = releaseVolumeMessage("hash", "backup", []string{"data", "copy"}); err != nil || len(holders) != 2 {
+		t.Fatalf("expected the other holders to be unknown, got %v %v", holders, err)
+	}
+}
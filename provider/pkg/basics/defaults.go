@@ -0,0 +1,30 @@
+package basics
+
+import "github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+
+// DefaultChannel is the channel of the messages of a resource which doesn't set one.
+const DefaultChannel = "ALEPH-CLOUDSOLUTIONS"
+
+// propertyUnset reports whether the inputs leave out the property at the path of
+// object keys, so that Check applies its default as the SDKs would. An unknown value
+// on the path counts as set.
+func propertyUnset(inputs resource.PropertyMap, path ...string) bool {
+	value := resource.NewObjectProperty(inputs)
+	for i := 0; i < len(path); i++ {
+		for value.IsSecret() {
+			value = value.SecretValue().Element
+		}
+
+		if !value.IsObject() {
+			return false
+		}
+
+		next, ok := value.ObjectValue()[resource.PropertyKey(path[i])]
+		if !ok || next.IsNull() {
+			return true
+		}
+		value = next
+	}
+
+	return false
+}
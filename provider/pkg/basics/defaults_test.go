@@ -0,0 +1,34 @@
+package basics
+
+import (
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+func TestPropertyUnset(t *testing.T) {
+	environment := resource.NewObjectProperty(resource.PropertyMap{
+		"internet": resource.NewBoolProperty(false),
+	})
+
+	cases := []struct {
+		inputs resource.PropertyMap
+		path   []string
+		unset  bool
+	}{
+		{resource.PropertyMap{}, []string{"channel"}, true},
+		{resource.PropertyMap{"channel": resource.NewNullProperty()}, []string{"channel"}, true},
+		{resource.PropertyMap{"channel": resource.NewStringProperty("")}, []string{"channel"}, false},
+		{resource.PropertyMap{}, []string{"environment", "internet"}, true},
+		{resource.PropertyMap{"environment": environment}, []string{"environment", "internet"}, false},
+		{resource.PropertyMap{"environment": environment}, []string{"environment", "alephApi"}, true},
+		{resource.PropertyMap{"environment": resource.MakeSecret(environment)}, []string{"environment", "internet"}, false},
+		{resource.PropertyMap{"environment": resource.MakeComputed(resource.NewObjectProperty(resource.PropertyMap{}))}, []string{"environment", "alephApi"}, false},
+	}
+
+	for i, c := range cases {
+		if unset := propertyUnset(c.inputs, c.path...); unset != c.unset {
+			t.Errorf("case %d: expected %v unset %v, got %v", i, c.path, c.unset, unset)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package basics
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+)
+
+// Properties whose values are never printed when describing a diff.
+var sensitiveProperties = []string{"account", "variables"}
+
+// propertyChange is a changed input, keyed by its pulumi property path (e.g. "resources.memory").
+type propertyChange struct {
+	Path string
+	Old  reflect.Value
+	New  reflect.Value
+}
+
+// String describes the change for the diff logs, e.g. "resources.memory: 2048 -> 4096".
+func (change propertyChange) String() string {
+	root := strings.Split(change.Path, ".")[0]
+	if slices.Contains(sensitiveProperties, root) {
+		return change.Path + " changed"
+	}
+
+	if root == "channel" && (isSecretChannel(change.Old.String()) || isSecretChannel(change.New.String())) {
+		return change.Path + " changed"
+	}
+
+	switch change.Old.Kind() {
+	case reflect.String, reflect.Bool, reflect.Int, reflect.Int64, reflect.Uint64, reflect.Float64:
+		return fmt.Sprintf("%s: %v -> %v", change.Path, change.Old.Interface(), change.New.Interface())
+	}
+
+	return change.Path + " changed"
+}
+
+// changedProperties compares two input structs field by field, walking into nested
+// structs so that changes are reported on the innermost property.
+func changedProperties(olds any, news any) []propertyChange {
+	return collectChanges("", reflect.ValueOf(olds), reflect.ValueOf(news), nil)
+}
+
+func collectChanges(prefix string, oldValue reflect.Value, newValue reflect.Value, changes []propertyChange) []propertyChange {
+	for i := 0; i < oldValue.NumField(); i++ {
+		name := strings.Split(oldValue.Type().Field(i).Tag.Get("pulumi"), ",")[0]
+		if name == "" {
+			continue
+		}
+
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		oldField := oldValue.Field(i)
+		newField := newValue.Field(i)
+
+		if equalValues(oldField, newField) {
+			continue
+		}
+
+		if oldField.Kind() == reflect.Struct {
+			changes = collectChanges(path, oldField, newField, changes)
+			continue
+		}
+
+		changes = append(changes, propertyChange{Path: path, Old: oldField, New: newField})
+	}
+
+	return changes
+}
+
+// diffArgs compares two input structs field by field, keyed by their pulumi property path.
+// Changes to the updatable top level properties are reported as in place updates, any
+// other change requires a replacement.
+func diffArgs(olds any, news any, updatable ...string) map[string]p.PropertyDiff {
+	diff := map[string]p.PropertyDiff{}
+	for _, change := range changedProperties(olds, news) {
+		kind := p.UpdateReplace
+		if slices.Contains(updatable, strings.Split(change.Path, ".")[0]) {
+			kind = p.Update
+		}
+
+		diff[change.Path] = p.PropertyDiff{
+			Kind:      kind,
+			InputDiff: true,
+		}
+	}
+
+	return diff
+}
+
+// logChanges explains in the preview output why a resource is updated or replaced.
+func logChanges(ctx p.Context, name string, reasons []string) {
+	for _, reason := range reasons {
+		ctx.Logf(diag.Info, "%s: %s", name, reason)
+	}
+}
+
+// changeReasons lists the human readable descriptions of the changed inputs.
+func changeReasons(olds any, news any) []string {
+	reasons := []string{}
+	for _, change := range changedProperties(olds, news) {
+		reasons = append(reasons, change.String())
+	}
+
+	return reasons
+}
+
+// equalValues is reflect.DeepEqual, except nil and empty maps or slices are equal
+// since they round trip identically through the Pulumi state.
+func equalValues(old reflect.Value, new reflect.Value) bool {
+	switch old.Kind() {
+	case reflect.Map, reflect.Slice:
+		if old.Len() == 0 && new.Len() == 0 {
+			return true
+		}
+	}
+
+	return reflect.DeepEqual(old.Interface(), new.Interface())
+}
+
+func diffResponse(diff map[string]p.PropertyDiff) p.DiffResponse {
+	response := p.DiffResponse{
+		HasChanges:   len(diff) > 0,
+		DetailedDiff: diff,
+	}
+
+	for _, change := range diff {
+		if change.Kind == p.UpdateReplace {
+			response.DeleteBeforeReplace = true
+		}
+	}
+
+	return response
+}
@@ -0,0 +1,161 @@
+package basics
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// FailoverPolicy tells when requests to the Aleph API move on to the next endpoint.
+type FailoverPolicy string
+
+const (
+	// Always use the first endpoint.
+	NoFailover FailoverPolicy = "none"
+	// Move on when the endpoint can't be reached.
+	ConnectionFailover FailoverPolicy = "connection"
+	// Move on as well when the endpoint keeps answering with server errors.
+	ServerErrorFailover FailoverPolicy = "serverErrors"
+)
+
+// DefaultApiUrls are the Aleph API endpoints requests are sent to, in order of priority.
+var DefaultApiUrls = []string{AlephApiUrl, "https://api2.aleph.im"}
+
+// DefaultFailoverRetries is the number of server errors in a row after which the
+// serverErrors policy moves on to the next endpoint.
+const DefaultFailoverRetries = 2
+
+// failoverTransport sends the requests addressed to the Aleph API to the current
+// endpoint of the list, and moves on to the next one for the following requests once
+// the endpoint fails. It sends every request once: the retry transport wrapping it
+// sends failing requests again, and every attempt goes to the current endpoint.
+type failoverTransport struct {
+	// Node tried before the endpoints, whatever the policy, see pin.
+	pinned *url.URL
+
+	endpoints []*url.URL
+	policy    FailoverPolicy
+	retries   int
+	base      http.RoundTripper
+
+	mutex sync.Mutex
+	// Index of the endpoint requests are sent to, -1 for the pinned node, and the
+	// server errors it answered in a row.
+	current  int
+	failures int
+}
+
+func newFailoverTransport(apiUrls []string, policy FailoverPolicy, retries int, base http.RoundTripper) (*failoverTransport, error) {
+	endpoints := []*url.URL{}
+	for i := 0; i < len(apiUrls); i++ {
+		endpoint, err := url.ParseRequestURI(apiUrls[i])
+		if err != nil {
+			return nil, err
+		}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	if policy == "" {
+		policy = ServerErrorFailover
+	}
+
+	if retries <= 0 {
+		retries = DefaultFailoverRetries
+	}
+
+	return &failoverTransport{endpoints: endpoints, policy: policy, retries: retries, base: base}, nil
+}
+
+// pin sends the following requests to the node until it fails.
+func (transport *failoverTransport) pin(node *url.URL) {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	transport.pinned = node
+	transport.current = -1
+	transport.failures = 0
+}
+
+// endpoint returns the current endpoint and its index.
+func (transport *failoverTransport) endpoint() (int, *url.URL) {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	if transport.current < 0 {
+		return transport.current, transport.pinned
+	}
+
+	return transport.current, transport.endpoints[transport.current]
+}
+
+// failed records a failure of the endpoint at the index, and moves on to the next
+// endpoint when the policy tells so. The pinned node is left on its first failure.
+func (transport *failoverTransport) failed(index int, serverError bool) {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	// another request already moved on
+	if index != transport.current {
+		return
+	}
+
+	if serverError {
+		if transport.policy != ServerErrorFailover {
+			return
+		}
+
+		transport.failures++
+		if index >= 0 && transport.failures < transport.retries {
+			return
+		}
+	} else if index >= 0 && transport.policy == NoFailover {
+		return
+	}
+
+	transport.current = (index + 1) % len(transport.endpoints)
+	transport.failures = 0
+}
+
+// succeeded resets the server errors in a row of the endpoint at the index.
+func (transport *failoverTransport) succeeded(index int) {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	if index == transport.current {
+		transport.failures = 0
+	}
+}
+
+func (transport *failoverTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	primary, _ := url.Parse(AlephApiUrl)
+	if len(transport.endpoints) == 0 || request.URL.Host != primary.Host {
+		return transport.base.RoundTrip(request)
+	}
+
+	index, endpoint := transport.endpoint()
+	response, err := transport.base.RoundTrip(rewriteEndpoint(request, endpoint))
+	if err != nil {
+		if request.Context().Err() == nil {
+			transport.failed(index, false)
+		}
+		return nil, err
+	}
+
+	if response.StatusCode >= 500 {
+		transport.failed(index, true)
+	} else {
+		transport.succeeded(index)
+	}
+
+	return response, nil
+}
+
+// rewriteEndpoint returns the request sent to the endpoint.
+func rewriteEndpoint(request *http.Request, endpoint *url.URL) *http.Request {
+	attempt := request.Clone(request.Context())
+	attempt.URL.Scheme = endpoint.Scheme
+	attempt.URL.Host = endpoint.Host
+	attempt.Host = ""
+
+	return attempt
+}
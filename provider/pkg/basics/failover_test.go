@@ -0,0 +1,161 @@
+package basics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFailoverTransportServerErrors(t *testing.T) {
+	withRetryDelay(t, time.Millisecond)
+
+	failing := 0
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failing++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer primary.Close()
+
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(r.URL.Path + " " + string(body)))
+	}))
+	defer secondary.Close()
+
+	transport, err := newFailoverTransport([]string{primary.URL, secondary.URL}, ServerErrorFailover, 2, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := http.Client{Transport: newRetryTransport(0, transport)}
+	response, err := client.Post(AlephApiUrl+"/api/v0/messages", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(response.Body)
+	if string(body) != "/api/v0/messages {}" {
+		t.Fatalf("expected the secondary endpoint to get the request, got %q", body)
+	}
+	if failing != 2 {
+		t.Fatalf("expected 2 attempts on the primary endpoint, got %d", failing)
+	}
+
+	// the following requests go straight to the secondary endpoint
+	response, err = client.Get(AlephApiUrl + "/api/v0/info/public.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if failing != 2 {
+		t.Fatalf("expected the primary endpoint to be left, got %d attempts", failing)
+	}
+}
+
+func TestApiTransportSendsFailingRequestsOnce(t *testing.T) {
+	withRetryDelay(t, time.Millisecond)
+
+	var requests atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+
+	primary := httptest.NewServer(handler)
+	defer primary.Close()
+	secondary := httptest.NewServer(handler)
+	defer secondary.Close()
+
+	config := TwentySixConfig{ApiUrls: []string{primary.URL, secondary.URL}, FailoverRetries: 2, RetryAttempts: 4}
+	_, transport := newApiTransport(config, http.DefaultTransport)
+
+	client := http.Client{Transport: transport}
+	response, err := client.Get(AlephApiUrl + "/api/v0/info/public.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected the last server error, got %d", response.StatusCode)
+	}
+	if n := requests.Load(); n != 4 {
+		t.Fatalf("expected the request to be sent retryAttempts times, got %d", n)
+	}
+}
+
+func TestFailoverTransportConnectionOnly(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer primary.Close()
+
+	transport, err := newFailoverTransport([]string{primary.URL, "http://127.0.0.1:1"}, ConnectionFailover, 0, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := http.Client{Transport: transport}
+	response, err := client.Get(AlephApiUrl + "/api/v0/info/public.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected server errors to be returned as is, got %d", response.StatusCode)
+	}
+}
+
+func TestFailoverTransportPinnedNode(t *testing.T) {
+	withRetryDelay(t, time.Millisecond)
+
+	pinned := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pinned"))
+	}))
+	defer pinned.Close()
+
+	fallback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("fallback"))
+	}))
+	defer fallback.Close()
+
+	transport, err := newFailoverTransport([]string{fallback.URL}, NoFailover, 0, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+	client.failover = transport
+	client.http.Transport = newRetryTransport(0, transport)
+	client.pinNode(pinned.URL)
+
+	response, err := client.http.Get(AlephApiUrl + "/api/v0/info/public.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	body, _ := io.ReadAll(response.Body)
+	if string(body) != "pinned" {
+		t.Fatalf("expected the pinned node to get the request, got %q", body)
+	}
+
+	pinned.Close()
+	response, err = client.http.Get(AlephApiUrl + "/api/v0/info/public.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	body, _ = io.ReadAll(response.Body)
+	if string(body) != "fallback" {
+		t.Fatalf("expected the request to fall back to the api endpoint, got %q", body)
+	}
+}
@@ -0,0 +1,174 @@
+package basics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+type folderHashEntry struct {
+	path     string
+	isDir    bool
+	size     int64
+	modTime  time.Time
+	fileHash string
+}
+
+// folderHashCacheTTL bounds how long a folder hash is reused, it only has to span
+// the Diff and Create calls of a single deployment.
+const folderHashCacheTTL = 10 * time.Minute
+
+type folderHashCacheEntry struct {
+	fingerprint string
+	hash        string
+	expires     time.Time
+}
+
+var (
+	folderHashCacheMutex sync.Mutex
+	folderHashCache      = map[string]folderHashCacheEntry{}
+)
+
+// cachedHashFolder returns the folder hash, reusing the one computed earlier by this
+// process as long as no file was added, removed, resized or modified since.
+func cachedHashFolder(folder string) (string, error) {
+	entries, err := walkFolder(folder)
+	if err != nil {
+		return "", err
+	}
+
+	fingerprint := folderFingerprint(entries)
+
+	folderHashCacheMutex.Lock()
+	cached, ok := folderHashCache[folder]
+	folderHashCacheMutex.Unlock()
+
+	if ok && cached.fingerprint == fingerprint && time.Now().Before(cached.expires) {
+		return cached.hash, nil
+	}
+
+	hash, err := hashFolderEntries(entries)
+	if err != nil {
+		return "", err
+	}
+
+	folderHashCacheMutex.Lock()
+	folderHashCache[folder] = folderHashCacheEntry{
+		fingerprint: fingerprint,
+		hash:        hash,
+		expires:     time.Now().Add(folderHashCacheTTL),
+	}
+	folderHashCacheMutex.Unlock()
+
+	return hash, nil
+}
+
+// hashFolder computes the sha256 folder hash of hashdir.Make, hashing files concurrently.
+// Entries are folded in the lexical walk order so the result doesn't depend on scheduling.
+func hashFolder(folder string) (string, error) {
+	entries, err := walkFolder(folder)
+	if err != nil {
+		return "", err
+	}
+
+	return hashFolderEntries(entries)
+}
+
+func walkFolder(folder string) ([]*folderHashEntry, error) {
+	entries := []*folderHashEntry{}
+	err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		entries = append(entries, &folderHashEntry{
+			path:    path,
+			isDir:   info.IsDir(),
+			size:    info.Size(),
+			modTime: info.ModTime(),
+		})
+		return nil
+	})
+	return entries, err
+}
+
+func folderFingerprint(entries []*folderHashEntry) string {
+	h := sha256.New()
+	for i := 0; i < len(entries); i++ {
+		fmt.Fprintf(h, "%s\x00%t\x00%d\x00%d\n", entries[i].path, entries[i].isDir, entries[i].size, entries[i].modTime.UnixNano())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func hashFolderEntries(entries []*folderHashEntry) (string, error) {
+	jobs := make(chan *folderHashEntry)
+	errs := make(chan error, 1)
+	var wg sync.WaitGroup
+
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for entry := range jobs {
+				fileHash, err := hashFileSha256(entry.path)
+				if err != nil {
+					select {
+					case errs <- err:
+					default:
+					}
+					continue
+				}
+				entry.fileHash = fileHash
+			}
+		}()
+	}
+
+	for i := 0; i < len(entries); i++ {
+		if !entries[i].isDir {
+			jobs <- entries[i]
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return "", err
+	default:
+	}
+
+	var endHash string
+	for i := 0; i < len(entries); i++ {
+		endHash = hashStringSha256(endHash)
+		if !entries[i].isDir {
+			endHash = endHash + hashStringSha256(entries[i].path) + entries[i].fileHash
+		}
+	}
+
+	return hashStringSha256(endHash), nil
+}
+
+func hashStringSha256(data string) string {
+	hash := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
+
+func hashFileSha256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,94 @@
+package basics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gosimple/hashdir"
+)
+
+func makeHashFolder(tb testing.TB, files int) string {
+	folder := tb.TempDir()
+	for i := 0; i < files; i++ {
+		dir := filepath.Join(folder, fmt.Sprintf("dir-%02d", i%32))
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		content := []byte(fmt.Sprintf("file %d content", i))
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file-%05d", i)), content, 0o644); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	return folder
+}
+
+func TestHashFolderMatchesHashdir(t *testing.T) {
+	folder := makeHashFolder(t, 200)
+
+	expected, err := hashdir.Make(folder, "sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		actual, err := hashFolder(folder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != expected {
+			t.Fatalf("expected hash %s, got %s", expected, actual)
+		}
+	}
+}
+
+func BenchmarkHashFolder(b *testing.B) {
+	folder := makeHashFolder(b, 5000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := hashFolder(folder); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHashdirMake(b *testing.B) {
+	folder := makeHashFolder(b, 5000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := hashdir.Make(folder, "sha256"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCachedHashFolderInvalidation(t *testing.T) {
+	folder := makeHashFolder(t, 10)
+
+	first, err := cachedHashFolder(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := cachedHashFolder(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatalf("expected cached hash %s, got %s", first, second)
+	}
+
+	file := filepath.Join(folder, "dir-00", "file-00000")
+	if err := os.WriteFile(file, []byte("changed content"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	third, err := cachedHashFolder(folder)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if third == first {
+		t.Fatal("expected the hash to change after a file was modified")
+	}
+}
@@ -0,0 +1,103 @@
+package basics
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+)
+
+const (
+	DefaultForgetTimeout  int64 = 120
+	DefaultForgetInterval int64 = 5
+)
+
+var ErrForgetTimeout = errors.New("message forget timeout")
+
+// Properties only read by the provider, which are updated in place without touching the message.
+var providerOptionProperties = []string{
+	"forgetTimeout", "forgetInterval",
+	"strictReplace", "protectReferenced", "rollbackOnFailure",
+	"waitForSchedule", "useMessageHashId", "verifyUpload", "deleteProtection",
+	"verifyBoot", "nodeAffinity", "fallbackRootfsRefs",
+	"confirmationTimeout", "confirmationInterval",
+}
+
+// onlyProviderOptionsChanged reports whether the provider options are the only inputs that changed.
+func onlyProviderOptionsChanged(olds any, news any) bool {
+	for _, change := range changedProperties(olds, news) {
+		if !slices.Contains(providerOptionProperties, change.Path) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WaitMessagesForgotten polls the status of the messages until they are all forgotten,
+// within a single timeout. It returns the hashes still not forgotten.
+func (client *TwentySixClient) WaitMessagesForgotten(hashes []string, options WaitOptions) ([]string, error) {
+	pending := slices.Clone(hashes)
+	err := waitUntil(client.ctx, options, func() (bool, error) {
+		remaining := []string{}
+		for i := 0; i < len(pending); i++ {
+			status, err := client.GetMessageStatus(pending[i])
+			if err != nil {
+				return false, err
+			}
+
+			if status.Status == RejectedMessageStatus {
+				return false, fmt.Errorf("message %s is %s", pending[i], status.Status)
+			}
+
+			if status.Status != ForgottenMessageStatus {
+				remaining = append(remaining, pending[i])
+			}
+		}
+
+		pending = remaining
+		return len(pending) == 0, nil
+	})
+	if errors.Is(err, errWaitTimeout) {
+		return pending, ErrForgetTimeout
+	}
+
+	return pending, err
+}
+
+// checkDeleteProtection stops Delete before anything is forgotten, based on the
+// flag stored in the state.
+func checkDeleteProtection(name string, deleteProtection bool) error {
+	if deleteProtection {
+		return fmt.Errorf("%s has deleteProtection enabled, set it to false and update the stack before deleting it", name)
+	}
+
+	return nil
+}
+
+// forgetAndWait forgets the messages in a single FORGET message and waits for them to
+// be forgotten. Non zero timeout and interval override the provider configuration.
+func forgetAndWait(ctx p.Context, client *TwentySixClient, hashes []string, timeout int64, interval int64) error {
+	if _, err := client.ForgetMessages(client.ctx, hashes); err != nil {
+		return err
+	}
+
+	if timeout == 0 {
+		timeout = client.forgetTimeout
+	}
+
+	if interval == 0 {
+		interval = client.forgetInterval
+	}
+
+	pending, err := client.WaitMessagesForgotten(hashes, secondsWaitOptions(timeout, interval))
+	if errors.Is(err, ErrForgetTimeout) && client.forgetTimeoutWarning {
+		ctx.Logf(diag.Warning, "messages %s were not forgotten after %ds", strings.Join(pending, ", "), timeout)
+		return nil
+	}
+
+	return err
+}
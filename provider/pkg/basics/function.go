@@ -1,12 +1,13 @@
 package basics
 
 import (
-	"errors"
-	"log"
-	"reflect"
-	"time"
+	"fmt"
+	"slices"
 
 	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi-go-provider/infer"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
 )
 
 // Each resource has a controlling struct.
@@ -21,13 +22,47 @@ import (
 // - WireDependencies: Control how outputs and secrets flows through values.
 type TwentySixFunction struct{}
 
+func (function *TwentySixFunction) Annotate(a infer.Annotator) {
+	a.Describe(&function, "A function VM deployed on aleph by a PROGRAM message, scheduled on a CRN.")
+}
+
 // Each resource has an input struct, defining what arguments it accepts.
 
+type RestartPolicy string
+
+const (
+	// The function VM is kept running by its node and restarted when it stops.
+	AlwaysRestartPolicy RestartPolicy = "always"
+	// The function VM is started on incoming HTTP requests and stopped once idle.
+	OnDemandRestartPolicy RestartPolicy = "on-demand"
+)
+
+var restartPolicies = []RestartPolicy{AlwaysRestartPolicy, OnDemandRestartPolicy}
+
+// effectiveRestartPolicy is the restart policy of the function, on-demand when unset.
+func (function TwentySixFunctionArgs) effectiveRestartPolicy() RestartPolicy {
+	if function.RestartPolicy == "" {
+		return OnDemandRestartPolicy
+	}
+
+	return function.RestartPolicy
+}
+
+// Internet and alephApi default to true, the other flags to false.
 type TwentySixFunctionFunctionEnvironment struct {
-	Reproducible bool `pulumi:"reproducible"`
-	Internet     bool `pulumi:"internet"`
-	AlephApi     bool `pulumi:"alephApi"`
-	SharedCache  bool `pulumi:"sharedCache"`
+	Reproducible bool `pulumi:"reproducible,optional"`
+	Internet     bool `pulumi:"internet,optional"`
+	AlephApi     bool `pulumi:"alephApi,optional"`
+	SharedCache  bool `pulumi:"sharedCache,optional"`
+}
+
+func (environment *TwentySixFunctionFunctionEnvironment) Annotate(a infer.Annotator) {
+	a.Describe(&environment.Reproducible, "Run the function in a reproducible environment.")
+	a.Describe(&environment.Internet, "Give the function internet access.")
+	a.SetDefault(&environment.Internet, true)
+	a.Describe(&environment.AlephApi, "Give the function access to the aleph API.")
+	a.SetDefault(&environment.AlephApi, true)
+	a.Describe(&environment.SharedCache, "Give the function access to the cache shared by the VMs of the node.")
 }
 
 type TwentySixFunctionMachineResources struct {
@@ -36,6 +71,12 @@ type TwentySixFunctionMachineResources struct {
 	Seconds uint64 `pulumi:"seconds"`
 }
 
+func (resources *TwentySixFunctionMachineResources) Annotate(a infer.Annotator) {
+	a.Describe(&resources.Vcpus, "Virtual CPUs of the VM, at least 1.")
+	a.Describe(&resources.Memory, "Memory of the VM in MiB, more than 0.")
+	a.Describe(&resources.Seconds, "Seconds a call may run.")
+}
+
 type TwentySixFunctionNodeRequirements struct {
 	Owner        string `pulumi:"owner"`
 	AddressRegex string `pulumi:"addressRegex"`
@@ -74,10 +115,18 @@ type TwentySixFunctionPersistentVolume struct {
 	SizeMib     uint64            `pulumi:"sizeMib"` //Limit to 1 GiB
 }
 
+// Receiver is the CRN address superfluid payments stream to, on AVAX or BASE.
 type TwentySixFunctionPayment struct {
 	Chain    MessageChain `pulumi:"chain"`
 	Receiver string       `pulumi:"receiver,optional"`
-	Type     PaymentType  `pulumi:"type"`
+	Type     PaymentType  `pulumi:"type,optional"`
+}
+
+func (payment *TwentySixFunctionPayment) Annotate(a infer.Annotator) {
+	a.Describe(&payment.Chain, "Chain of the payment: ETH, SOL, AVAX or BASE.")
+	a.Describe(&payment.Receiver, "Address of the CRN a superfluid payment streams to.")
+	a.Describe(&payment.Type, "Payment type, hold or superfluid.")
+	a.SetDefault(&payment.Type, string(HoldPaymentType))
 }
 
 type TwentySixFunctionParentVolume struct {
@@ -91,18 +140,81 @@ type TwentySixFunctionArgs struct {
 	// good idea.
 
 	Account TwentySixAccountState `pulumi:"account"`
-	Channel string                `pulumi:"channel"`
+	Channel string                `pulumi:"channel,optional"`
 
 	AllowAmend     bool                                 `pulumi:"allowAmend"`
 	Metadata       map[string]string                    `pulumi:"metadata,optional"`
 	AuthorizedKeys []string                             `pulumi:"authorizedKeys"`
 	Variables      map[string]string                    `pulumi:"variables,optional"`
-	Environment    TwentySixFunctionFunctionEnvironment `pulumi:"environment"`
+	Environment    TwentySixFunctionFunctionEnvironment `pulumi:"environment,optional"`
 	Resources      TwentySixFunctionMachineResources    `pulumi:"resources"`
 	Payment        TwentySixFunctionPayment             `pulumi:"payment"`
 	Requirements   TwentySixFunctionHostRequirements    `pulumi:"requirements,optional"`
 	Volumes        []interface{}                        `pulumi:"volumes"`
 	Replaces       string                               `pulumi:"replaces,optional"`
+
+	// Tags are merged into the message metadata, explicit metadata wins on conflicts.
+	Tags map[string]string `pulumi:"tags,optional"`
+
+	RestartPolicy RestartPolicy `pulumi:"restartPolicy,optional"`
+
+	// Seconds Delete waits for the message to be forgotten, and between two status polls.
+	// Zero uses the provider configuration.
+	ForgetTimeout  int64 `pulumi:"forgetTimeout,optional"`
+	ForgetInterval int64 `pulumi:"forgetInterval,optional"`
+
+	// Seconds Create waits for aleph to process the message, and between two status polls.
+	// Zero waits up to 120 seconds, polling every 5 seconds.
+	ConfirmationTimeout  int64 `pulumi:"confirmationTimeout,optional"`
+	ConfirmationInterval int64 `pulumi:"confirmationInterval,optional"`
+
+	// Replace the resource on any input change, defaults to the provider strictReplace.
+	StrictReplace *bool `pulumi:"strictReplace,optional"`
+
+	// Wait for the scheduler to allocate the VM in Create, true by default. When false
+	// Create returns once the message is broadcast and a refresh reads the allocation.
+	WaitForSchedule *bool `pulumi:"waitForSchedule,optional"`
+
+	// Use the message item hash as the resource ID instead of the resource name, so
+	// that a resource can be imported from its hash. The ID is set at creation: a
+	// renamed resource keeps it, and a replaced one gets the hash of its new message.
+	UseMessageHashId bool `pulumi:"useMessageHashId,optional"`
+
+	// Refuse to forget the message on delete. Disable it and update the resource before
+	// destroying it.
+	DeleteProtection bool `pulumi:"deleteProtection,optional"`
+
+	// Pin the operations of the resource to a read node chosen at creation, recorded
+	// in pinnedNode, to read its message back from the node which received it. The
+	// API endpoints are used when the node can't be reached.
+	NodeAffinity bool `pulumi:"nodeAffinity,optional"`
+}
+
+func (args *TwentySixFunctionArgs) Annotate(a infer.Annotator) {
+	a.Describe(&args.Account, "Account signing the PROGRAM message.")
+	a.Describe(&args.Channel, "Channel of the PROGRAM message.")
+	a.SetDefault(&args.Channel, DefaultChannel)
+	a.Describe(&args.AllowAmend, "Amend the message in place on metadata, tags, variables, authorized keys, environment or resources changes.")
+	a.Describe(&args.Metadata, "Metadata of the PROGRAM message.")
+	a.Describe(&args.AuthorizedKeys, "SSH public keys authorized on the VM.")
+	a.Describe(&args.Variables, "Environment variables of the VM.")
+	a.Describe(&args.Environment, "Capabilities of the VM.")
+	a.Describe(&args.Resources, "Machine resources of the VM.")
+	a.Describe(&args.Payment, "Payment of the VM.")
+	a.Describe(&args.Requirements, "Requirements on the CPU and the node hosting the VM.")
+	a.Describe(&args.Volumes, "Volumes mounted in the VM, in mount order.")
+	a.Describe(&args.Replaces, "Hash of a message the PROGRAM message replaces.")
+	a.Describe(&args.Tags, "Tags merged into the message metadata, explicit metadata wins on conflicts.")
+	a.Describe(&args.RestartPolicy, "When the VM restarts, on-demand by default.")
+	a.Describe(&args.ForgetTimeout, "Seconds Delete waits for the message to be forgotten, 0 uses the provider configuration.")
+	a.Describe(&args.ForgetInterval, "Seconds between two status polls while waiting for the message to be forgotten.")
+	a.Describe(&args.ConfirmationTimeout, "Seconds Create waits for aleph to process the message, 120 when 0.")
+	a.Describe(&args.ConfirmationInterval, "Seconds between two status polls while waiting for the message to be processed, 5 when 0.")
+	a.Describe(&args.StrictReplace, "Replace the function on any input change, defaults to the provider strictReplace.")
+	a.Describe(&args.WaitForSchedule, "Wait for the scheduler to allocate the VM in Create, true by default.")
+	a.Describe(&args.UseMessageHashId, "Use the message hash as the resource ID, so that functions can be imported from their hash.")
+	a.Describe(&args.DeleteProtection, "Refuse to forget the message on delete.")
+	a.Describe(&args.NodeAffinity, "Pin the operations of the function to the read node which received its message.")
 }
 
 // Each resource has a state, describing the fields that exist on the created resource.
@@ -111,92 +223,244 @@ type TwentySixFunctionState struct {
 	TwentySixFunctionArgs
 
 	SchedulerAllocation SchedulerAllocation `pulumi:"schedulerAllocation"`
+
+	EffectiveRestartPolicy RestartPolicy `pulumi:"effectiveRestartPolicy"`
 	// Here we define a required output called result.
 	MessageHash string `pulumi:"messageHash"`
+
+	// Timestamp and channel the message was stamped with.
+	MessageTime    float64 `pulumi:"messageTime"`
+	MessageChannel string  `pulumi:"messageChannel"`
+
+	// Why aleph rejected the message, set by a refresh finding it rejected.
+	RejectionReason string `pulumi:"rejectionReason,optional"`
+	// Nodes which served the message right after it was broadcast, see verifyQuorum.
+	ConfirmedNodes []string `pulumi:"confirmedNodes,optional"`
+
+	// Hash of the last message amending the function in place.
+	AmendHash string `pulumi:"amendHash,optional"`
+
+	// Read node the resource operations are pinned to, see nodeAffinity.
+	PinnedNode string `pulumi:"pinnedNode,optional"`
+
+	// Address of the VM, the node running it and its hash, from the scheduler allocation.
+	TwentySixVmAddress
+}
+
+// Inputs amended in place when both the deployed and the new function allow amends,
+// the VM picks them up on its next start.
+var amendableFunctionProperties = []string{"metadata", "tags", "variables", "authorizedKeys", "environment", "resources"}
+
+// updatableProperties lists the inputs changed without replacing the function.
+func (function TwentySixFunctionArgs) updatableProperties(news TwentySixFunctionArgs) []string {
+	if !function.AllowAmend || !news.AllowAmend {
+		return providerOptionProperties
+	}
+
+	return append(slices.Clone(amendableFunctionProperties), providerOptionProperties...)
 }
 
 // All resources must implement Create at a minimum.
 func (volume TwentySixFunction) Create(ctx p.Context, name string, input TwentySixFunctionArgs, preview bool) (string, TwentySixFunctionState, error) {
 	state := TwentySixFunctionState{TwentySixFunctionArgs: input}
+	state.EffectiveRestartPolicy = input.effectiveRestartPolicy()
+
+	// the message is only broadcast by the update
+	if preview {
+		return name, state, nil
+	}
+
+	ctx, cancel := startOperation(ctx, "creating function "+name)
+	defer cancel()
 
 	//create instance on aleph
-	client := NewTwentySixClient(input.Account, state.Channel)
-	message, response, err := client.CreateFunction(input)
+	client := NewConfiguredClient(ctx, input.Account, state.Channel)
+	state.PinnedNode = pinAffinityNode(ctx, &client, name, input.NodeAffinity)
+
+	if err := checkMinBalance(ctx, &client); err != nil {
+		return "", TwentySixFunctionState{}, err
+	}
+
+	message, _, err := client.CreateFunction(input)
 	if err != nil {
 		return "", TwentySixFunctionState{}, err
 	}
 
-	if response.Status == RejectedMessageStatus {
-		return "", TwentySixFunctionState{}, errors.New("an error occured on function message")
+	state.MessageHash = message.ItemHash
+	state.MessageTime = message.Time
+	state.MessageChannel = message.Channel
+
+	state.ConfirmedNodes, err = verifyBroadcast(ctx, &client, message.ItemHash)
+	if err != nil {
+		return "", TwentySixFunctionState{}, err
 	}
 
-	if response.PublicationStatus.Status != SucceedMessageStatus {
-		return "", TwentySixFunctionState{}, errors.New("an error occured on function message")
+	if !waitForSchedule(input.WaitForSchedule) {
+		return resourceId(ctx, name, state.MessageHash, input.UseMessageHashId), state, nil
 	}
 
-	state.MessageHash = message.ItemHash
+	// the scheduler only allocates processed messages
+	if err := waitConfirmation(&client, message.ItemHash, input.ConfirmationTimeout, input.ConfirmationInterval); err != nil {
+		return "", TwentySixFunctionState{}, err
+	}
 
 	//wait for instance ready buy checking on scheduler
-	instanceAvailable := false
-
-	timeout := int64(1800)
-	startAt := time.Now().Unix()
-	for !instanceAvailable {
-		time.Sleep(10 * time.Second)
-
-		instanceState, err := client.GetInstanceState(message.ItemHash)
-		if err != nil {
-			log.Println("error on retrieve instance state: ", err.Error())
-			now := time.Now().Unix()
-			if now > startAt+timeout {
-				return "", TwentySixFunctionState{}, errors.New("timeout waiting for instance")
-			}
-			continue
-		}
-
-		state.SchedulerAllocation = instanceState
-		instanceAvailable = true
+	allocation, err := client.WaitAllocation(message.ItemHash, client.allocationWaitOptions())
+	if err != nil {
+		return "", TwentySixFunctionState{}, err
 	}
 
-	return name, state, nil
+	state.SchedulerAllocation = allocation
+	state.TwentySixVmAddress = allocationAddress(allocation)
+
+	return resourceId(ctx, name, state.MessageHash, input.UseMessageHashId), state, nil
 }
 
-func (volume TwentySixFunction) Diff(ctx p.Context, name string, olds TwentySixFunctionState, news TwentySixFunctionArgs) (p.DiffResponse, error) {
+func (volume TwentySixFunction) Check(ctx p.Context, name string, oldInputs resource.PropertyMap, newInputs resource.PropertyMap) (TwentySixFunctionArgs, []p.CheckFailure, error) {
+	args, failures, err := infer.DefaultCheck[TwentySixFunctionArgs](newInputs)
+	if err != nil {
+		return args, failures, err
+	}
 
-	client := NewTwentySixClient(news.Account, news.Channel)
+	if propertyUnset(newInputs, "channel") {
+		args.Channel = DefaultChannel
+	}
+	if propertyUnset(newInputs, "environment", "internet") {
+		args.Environment.Internet = true
+	}
+	if propertyUnset(newInputs, "environment", "alephApi") {
+		args.Environment.AlephApi = true
+	}
+	if propertyUnset(newInputs, "payment", "type") {
+		args.Payment.Type = HoldPaymentType
+	}
 
-	previous := TwentySixFunctionArgs{
-		AllowAmend:     olds.AllowAmend,
-		Metadata:       olds.Metadata,
-		AuthorizedKeys: olds.AuthorizedKeys,
-		Variables:      olds.Variables,
-		Environment:    olds.Environment,
-		Resources:      olds.Resources,
-		Payment:        olds.Payment,
-		Requirements:   olds.Requirements,
-		Volumes:        olds.Volumes,
-		Replaces:       olds.Replaces,
+	failures = append(failures, checkTags(args.Tags)...)
+	failures = append(failures, checkSecretChannel(newInputs)...)
+	failures = append(failures, checkAuthorizedKeys(args.AuthorizedKeys)...)
+	failures = append(failures, checkMachineResources(args.Resources.Vcpus, args.Resources.Memory)...)
+	failures = append(failures, checkPayment(args.Payment.Chain, args.Payment.Receiver, args.Payment.Type)...)
+
+	if args.RestartPolicy != "" && !slices.Contains(restartPolicies, args.RestartPolicy) {
+		failures = append(failures, p.CheckFailure{
+			Property: "restartPolicy",
+			Reason:   fmt.Sprintf("invalid restart policy %q: expected one of %v", args.RestartPolicy, restartPolicies),
+		})
 	}
 
-	_, err := client.GetInstanceState(olds.SchedulerAllocation.VmHash)
-	instanceStillExists := (err != nil)
+	return args, failures, nil
+}
 
-	if reflect.DeepEqual(previous, news) && instanceStillExists {
-		return p.DiffResponse{
-			DeleteBeforeReplace: false,
-			HasChanges:          false,
-		}, nil
-	} else {
+func (volume TwentySixFunction) WireDependencies(f infer.FieldSelector, args *TwentySixFunctionArgs, state *TwentySixFunctionState) {
+	wireChannel(f, args, state, &args.Channel, &state.MessageChannel)
+}
+
+func (volume TwentySixFunction) Diff(ctx p.Context, name string, olds TwentySixFunctionState, news TwentySixFunctionArgs) (p.DiffResponse, error) {
+	ctx, cancel := startOperation(ctx, "diffing function "+name)
+	defer cancel()
+
+	client := NewConfiguredClient(ctx, news.Account, news.Channel)
+	client.pinNode(olds.PinnedNode)
+
+	_, err := client.GetMessageByHash(olds.MessageHash)
+	if err != nil {
+		logChanges(ctx, name, []string{"message " + olds.MessageHash + " not found"})
 		return p.DiffResponse{
 			DeleteBeforeReplace: true,
 			HasChanges:          true,
+			DetailedDiff: map[string]p.PropertyDiff{
+				"messageHash": {Kind: p.UpdateReplace},
+			},
 		}, nil
 	}
+
+	// volumes are serialized in mount order, reordering them changes nothing
+	olds.Volumes = canonicalVolumes(olds.Volumes)
+	news.Volumes = canonicalVolumes(news.Volumes)
+
+	logChanges(ctx, name, changeReasons(olds.TwentySixFunctionArgs, news))
+
+	diff := diffArgs(olds.TwentySixFunctionArgs, news, olds.updatableProperties(news)...)
+	if strictReplaceEnabled(ctx, news.StrictReplace) {
+		return strictDiffResponse(diff), nil
+	}
+
+	return diffResponse(diff), nil
+}
+
+// Update only applies the provider options, any other change replaces the function.
+func (volume TwentySixFunction) Update(ctx p.Context, name string, olds TwentySixFunctionState, news TwentySixFunctionArgs, preview bool) (TwentySixFunctionState, error) {
+	ctx, cancel := startOperation(ctx, "updating function "+name)
+	defer cancel()
+
+	state := olds
+	state.TwentySixFunctionArgs = news
+
+	if preview || !olds.AllowAmend || onlyProviderOptionsChanged(olds.TwentySixFunctionArgs, news) {
+		return state, nil
+	}
+
+	amend := news
+	amend.Replaces = olds.MessageHash
+
+	client := NewConfiguredClient(ctx, news.Account, news.Channel)
+	client.pinNode(olds.PinnedNode)
+	message, _, err := client.CreateFunction(amend)
+	if err != nil {
+		return TwentySixFunctionState{}, err
+	}
+
+	state.AmendHash = message.ItemHash
+
+	return state, nil
+}
+
+func (volume TwentySixFunction) Read(ctx p.Context, id string, inputs TwentySixFunctionArgs, state TwentySixFunctionState) (string, TwentySixFunctionArgs, TwentySixFunctionState, error) {
+	ctx, cancel := startOperation(ctx, "reading function "+id)
+	defer cancel()
+
+	// an imported resource only has its ID, the message item hash
+	if state.MessageHash == "" {
+		state.MessageHash = id
+	}
+
+	client := NewConfiguredClient(ctx, state.Account, state.Channel)
+	client.pinNode(state.PinnedNode)
+
+	gone, reason, err := readMessageState(&client, state.MessageHash)
+	if err != nil {
+		return "", TwentySixFunctionArgs{}, TwentySixFunctionState{}, err
+	}
+
+	// an empty ID tells Pulumi the function no longer exists, the next update creates it again
+	if gone {
+		ctx.Logf(diag.Warning, "message %s of %s was forgotten", state.MessageHash, id)
+		return "", TwentySixFunctionArgs{}, TwentySixFunctionState{}, nil
+	}
+
+	if reason != "" {
+		ctx.Logf(diag.Warning, "message %s was rejected: %s", state.MessageHash, reason)
+	}
+	state.RejectionReason = reason
+
+	if reason == "" {
+		state.SchedulerAllocation = readAllocation(ctx, &client, state.MessageHash, state.SchedulerAllocation)
+		state.TwentySixVmAddress = allocationAddress(state.SchedulerAllocation)
+	}
+
+	return id, inputs, state, nil
 }
 
 func (volume TwentySixFunction) Delete(ctx p.Context, name string, olds TwentySixFunctionState) error {
+	ctx, cancel := startOperation(ctx, "deleting function "+name)
+	defer cancel()
+
+	if err := checkDeleteProtection(name, olds.DeleteProtection); err != nil {
+		return err
+	}
 
-	client := NewTwentySixClient(olds.Account, olds.Channel)
+	client := NewConfiguredClient(ctx, olds.Account, olds.Channel)
+	client.pinNode(olds.PinnedNode)
 	message, err := client.GetMessageByHash(olds.MessageHash)
 	if err != nil {
 		if err.Error() == "message not found" {
@@ -206,12 +470,12 @@ func (volume TwentySixFunction) Delete(ctx p.Context, name string, olds TwentySi
 		}
 	}
 
-	_, err = client.ForgetMessage(message.ItemHash)
-	if err != nil {
-		return err
+	hashes := []string{message.ItemHash}
+	if olds.AmendHash != "" {
+		hashes = append(hashes, olds.AmendHash)
 	}
 
-	return nil
+	return forgetAndWait(ctx, &client, hashes, olds.ForgetTimeout, olds.ForgetInterval)
 }
 
 //update-alternatives --set iptables /usr/sbin/iptables-legacy
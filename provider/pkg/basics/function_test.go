@@ -0,0 +1,125 @@
+package basics
+
+import (
+	"testing"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+func testFunctionArgs() TwentySixFunctionArgs {
+	return TwentySixFunctionArgs{
+		Channel:        "TEST",
+		AllowAmend:     true,
+		Metadata:       map[string]string{"name": "before"},
+		AuthorizedKeys: []string{},
+		Variables:      map[string]string{"MODE": "before"},
+		Resources:      TwentySixFunctionMachineResources{Vcpus: 1, Memory: 2048, Seconds: 30},
+		Payment:        TwentySixFunctionPayment{Chain: EthereumChain, Type: HoldPaymentType},
+		Volumes:        []interface{}{},
+	}
+}
+
+func TestFunctionDiffAmendable(t *testing.T) {
+	olds := testFunctionArgs()
+	news := testFunctionArgs()
+	news.Variables = map[string]string{"MODE": "after"}
+	news.Resources.Memory = 4096
+
+	response := diffResponse(diffArgs(olds, news, olds.updatableProperties(news)...))
+
+	if !response.HasChanges {
+		t.Fatal("expected changes")
+	}
+	if response.DeleteBeforeReplace {
+		t.Fatalf("amendable changes must not replace the function, got %v", response.DetailedDiff)
+	}
+	if kind := response.DetailedDiff["resources.memory"].Kind; kind != p.Update {
+		t.Fatalf("expected an update of resources.memory, got %q", kind)
+	}
+}
+
+func TestFunctionDiffWithoutAmend(t *testing.T) {
+	olds := testFunctionArgs()
+	olds.AllowAmend = false
+	news := testFunctionArgs()
+	news.Metadata = map[string]string{"name": "after"}
+
+	response := diffResponse(diffArgs(olds, news, olds.updatableProperties(news)...))
+
+	if !response.DeleteBeforeReplace {
+		t.Fatal("a function which doesn't allow amends must be replaced")
+	}
+	if kind := response.DetailedDiff["metadata"].Kind; kind != p.UpdateReplace {
+		t.Fatalf("expected a replacement of metadata, got %q", kind)
+	}
+}
+
+func TestFunctionDiffPayment(t *testing.T) {
+	olds := testFunctionArgs()
+	news := testFunctionArgs()
+	news.Payment.Type = SuperfluidPaymentType
+
+	response := diffResponse(diffArgs(olds, news, olds.updatableProperties(news)...))
+
+	if !response.DeleteBeforeReplace {
+		t.Fatal("a payment change must replace the function")
+	}
+}
+
+func TestFunctionCheckDefaults(t *testing.T) {
+	inputs := resource.NewPropertyMap(testFunctionArgs())
+	delete(inputs, "channel")
+	delete(inputs, "environment")
+	delete(inputs["payment"].ObjectValue(), "type")
+
+	args, failures, err := TwentySixFunction{}.Check(nil, "function", nil, inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 0 {
+		t.Fatalf("unexpected failures %v", failures)
+	}
+	if args.Channel != DefaultChannel {
+		t.Errorf("expected channel %q, got %q", DefaultChannel, args.Channel)
+	}
+	if !args.Environment.Internet || !args.Environment.AlephApi {
+		t.Errorf("expected internet and aleph api access, got %+v", args.Environment)
+	}
+	if args.Payment.Type != HoldPaymentType {
+		t.Errorf("expected payment type %q, got %q", HoldPaymentType, args.Payment.Type)
+	}
+}
+
+func TestFunctionCheckKeepsExplicitValues(t *testing.T) {
+	inputs := resource.NewPropertyMap(testFunctionArgs())
+	inputs["environment"] = resource.NewObjectProperty(resource.PropertyMap{
+		"internet": resource.NewBoolProperty(false),
+		"alephApi": resource.NewBoolProperty(false),
+	})
+
+	args, _, err := TwentySixFunction{}.Check(nil, "function", nil, inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args.Channel != "TEST" {
+		t.Errorf("expected channel TEST, got %q", args.Channel)
+	}
+	if args.Environment.Internet || args.Environment.AlephApi {
+		t.Errorf("explicit environment overridden, got %+v", args.Environment)
+	}
+}
+
+func TestFunctionPreviewDoesNotBroadcast(t *testing.T) {
+	// a broadcast would need the operation context and the provider configuration
+	id, state, err := TwentySixFunction{}.Create(nil, "function", testFunctionArgs(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "function" || state.MessageHash != "" {
+		t.Fatalf("expected a preview without message, got %s %q", id, state.MessageHash)
+	}
+	if state.EffectiveRestartPolicy != OnDemandRestartPolicy {
+		t.Fatalf("expected the effective restart policy in preview, got %q", state.EffectiveRestartPolicy)
+	}
+}
@@ -0,0 +1,107 @@
+package basics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// DefaultIpfsGateways are tried in order when downloading or checking content.
+var DefaultIpfsGateways = []string{
+	"https://ipfs.aleph.cloud/ipfs/",
+	"https://ipfs.io/ipfs/",
+	"https://dweb.link/ipfs/",
+}
+
+func gatewayUrl(gateway string, cid string) string {
+	return strings.TrimSuffix(gateway, "/") + "/" + cid
+}
+
+// DownloadFile fetches an IPFS content into destination from the first gateway
+// serving it, and returns the gateway used.
+func (client *TwentySixClient) DownloadFile(cid string, destination string) (string, error) {
+	var lastErr error = errors.New("no ipfs gateway configured")
+
+	for _, gateway := range client.ipfsGateways {
+		err := client.downloadFrom(gatewayUrl(gateway, cid), destination)
+		if err == nil {
+			return gateway, nil
+		}
+
+		log.Printf("unable to download %s from %s: %s", cid, gateway, err.Error())
+		lastErr = err
+	}
+
+	return "", lastErr
+}
+
+func (client *TwentySixClient) downloadFrom(endpoint string, destination string) error {
+	request, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := client.http.Do(request)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("gateway returned status %d", response.StatusCode)
+	}
+
+	file, err := os.Create(destination)
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	_, err = io.Copy(file, response.Body)
+	return err
+}
+
+// ContentAvailable returns the first gateway able to serve the content.
+func (client *TwentySixClient) ContentAvailable(cid string) (string, error) {
+	for _, gateway := range client.ipfsGateways {
+		request, err := http.NewRequest("HEAD", gatewayUrl(gateway, cid), nil)
+		if err != nil {
+			return "", err
+		}
+
+		response, err := client.http.Do(request)
+		if err != nil {
+			continue
+		}
+
+		response.Body.Close()
+
+		if response.StatusCode == http.StatusOK {
+			return gateway, nil
+		}
+	}
+
+	return "", errors.New("content " + cid + " not available on any ipfs gateway")
+}
+
+// WaitContentAvailable polls the gateways until one of them serves the content.
+func (client *TwentySixClient) WaitContentAvailable(cid string, options WaitOptions) (string, error) {
+	var gateway string
+
+	err := waitUntil(client.ctx, options, func() (bool, error) {
+		var err error
+		gateway, err = client.ContentAvailable(cid)
+		return err == nil, nil
+	})
+	if errors.Is(err, errWaitTimeout) {
+		return "", errors.New("content availability timeout")
+	}
+
+	return gateway, err
+}
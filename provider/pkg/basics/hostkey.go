@@ -0,0 +1,71 @@
+package basics
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// CRNs don't publish the SSH host keys of their VMs, the key is read from the SSH
+// handshake of the VM itself, as ssh-keyscan does. It is only available once the VM
+// booted and is reachable from the machine running the provider.
+const sshHostKeyTimeout = 10 * time.Second
+
+var errHostKeyScanned = errors.New("host key scanned")
+
+// ScanSSHHostKey connects to the SSH server at address and returns its host key,
+// without authenticating.
+func ScanSSHHostKey(address string, timeout time.Duration) (ssh.PublicKey, error) {
+	var hostKey ssh.PublicKey
+
+	config := &ssh.ClientConfig{
+		User: "root",
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			hostKey = key
+			return errHostKeyScanned
+		},
+		Timeout: timeout,
+	}
+
+	client, err := ssh.Dial("tcp", address, config)
+	if client != nil {
+		client.Close()
+	}
+	if hostKey != nil {
+		return hostKey, nil
+	}
+	if err == nil {
+		err = errors.New("no host key received")
+	}
+
+	return nil, err
+}
+
+// vmAddress is the address of the VM, without the prefix length aleph may return.
+func vmAddress(allocation SchedulerAllocation) string {
+	address, _, _ := strings.Cut(allocation.VmIPV6, "/")
+	return address
+}
+
+// readSSHHostKey returns the host key of the allocated VM in authorized_keys format,
+// and its known_hosts line, or empty strings while the VM can't be reached.
+func readSSHHostKey(ctx p.Context, allocation SchedulerAllocation) (string, string) {
+	address := vmAddress(allocation)
+	if address == "" {
+		return "", ""
+	}
+
+	hostKey, err := ScanSSHHostKey(net.JoinHostPort(address, "22"), sshHostKeyTimeout)
+	if err != nil {
+		ctx.Logf(diag.Info, "ssh host key of vm %s is not available yet: %s", allocation.VmHash, err)
+		return "", ""
+	}
+
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(hostKey))), knownhosts.Line([]string{address}, hostKey)
+}
@@ -0,0 +1,55 @@
+package basics
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestScanSSHHostKey(t *testing.T) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		config := &ssh.ServerConfig{NoClientAuth: true}
+		config.AddHostKey(signer)
+		ssh.NewServerConn(conn, config)
+	}()
+
+	hostKey, err := ScanSSHHostKey(listener.Addr().String(), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(hostKey.Marshal()) != string(signer.PublicKey().Marshal()) {
+		t.Fatal("expected the host key of the server")
+	}
+}
+
+func TestVmAddress(t *testing.T) {
+	if address := vmAddress(SchedulerAllocation{VmIPV6: "2a01:240:ad00:2100:3:89cd::1/124"}); address != "2a01:240:ad00:2100:3:89cd::1" {
+		t.Fatalf("expected the prefix length to be dropped, got %s", address)
+	}
+}
@@ -0,0 +1,138 @@
+package basics
+
+import (
+	"encoding/json"
+	"sort"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+)
+
+// Type tokens of the resources an existing message is imported as. PROGRAM messages
+// aren't listed, TwentySixFunction isn't a registered resource.
+var importResourceTypes = map[MessageType]string{
+	StoreMessageType:    "twentysix:basics:TwentySixVolume",
+	InstanceMessageType: "twentysix:basics:TwentySixInstance",
+}
+
+var importNamePrefixes = map[MessageType]string{
+	StoreMessageType:    "volume",
+	InstanceMessageType: "instance",
+}
+
+// TwentySixImportSpec is an entry of a `pulumi import --file` file. The ID is the message
+// item hash, the resources Read it back from aleph.
+type TwentySixImportSpec struct {
+	Type        string `pulumi:"type" json:"type"`
+	Name        string `pulumi:"name" json:"name"`
+	Id          string `pulumi:"id" json:"id"`
+	MessageType string `pulumi:"messageType" json:"-"`
+	Channel     string `pulumi:"channel" json:"-"`
+}
+
+// ListImportSpecs pages through the STORE and INSTANCE messages of the address, on a
+// channel unless it is empty, and returns an import spec for each of them.
+func (client *TwentySixClient) ListImportSpecs(address string, channel string) ([]TwentySixImportSpec, error) {
+	channels := []string{}
+	if channel != "" {
+		channels = append(channels, channel)
+	}
+	msgTypes := []MessageType{StoreMessageType, InstanceMessageType}
+
+	specs := []TwentySixImportSpec{}
+
+	messages, err := client.GetAllMessages(client.ctx, MessageFilter{
+		Addresses:    []string{address},
+		Channels:     channels,
+		MessageTypes: msgTypes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(messages); i++ {
+		if spec, ok := importSpec(messages[i]); ok {
+			specs = append(specs, spec)
+		}
+	}
+
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].Name < specs[j].Name
+	})
+
+	return specs, nil
+}
+
+func importSpec(message Message) (TwentySixImportSpec, bool) {
+	resourceType, ok := importResourceTypes[message.Type]
+	if !ok || message.ItemHash == "" {
+		return TwentySixImportSpec{}, false
+	}
+
+	hash := string(message.ItemHash)
+	shortHash := hash
+	if len(shortHash) > 12 {
+		shortHash = shortHash[:12]
+	}
+
+	return TwentySixImportSpec{
+		Type:        resourceType,
+		Name:        importNamePrefixes[message.Type] + "-" + shortHash,
+		Id:          hash,
+		MessageType: string(message.Type),
+		Channel:     message.Channel,
+	}, true
+}
+
+// warnPartialImport tells an imported resource, which only has its message hash, needs
+// its account and inputs from the program before it can be updated or deleted.
+func warnPartialImport(ctx p.Context, kind string, id string) {
+	ctx.Logf(diag.Warning, "imported %s %s only has its message hash, set its account and inputs in the program as they were created before updating it, a different input replaces it", kind, id)
+}
+
+// importFile renders the specs as the content of a `pulumi import --file` file.
+func importFile(specs []TwentySixImportSpec) (string, error) {
+	file := struct {
+		Resources []TwentySixImportSpec `json:"resources"`
+	}{Resources: specs}
+
+	content, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	return string(content), nil
+}
+
+// GetImportSpecs is a provider function listing the existing volumes and instances of
+// an account, to bring them under management with `pulumi import --file`. An import
+// only reads the message back: the account, with its private key, and the inputs of
+// the resource must then be set in the program, see warnPartialImport.
+type GetImportSpecs struct{}
+
+type GetImportSpecsArgs struct {
+	Address string `pulumi:"address"`
+	Channel string `pulumi:"channel,optional"`
+}
+
+type GetImportSpecsResult struct {
+	Resources []TwentySixImportSpec `pulumi:"resources"`
+	// JSON content of the import file.
+	File string `pulumi:"file"`
+}
+
+func (GetImportSpecs) Call(ctx p.Context, args GetImportSpecsArgs) (GetImportSpecsResult, error) {
+	client := NewConfiguredClient(ctx, TwentySixAccountState{}, args.Channel)
+
+	specs, err := client.ListImportSpecs(args.Address, args.Channel)
+	if err != nil {
+		return GetImportSpecsResult{}, err
+	}
+
+	file, err := importFile(specs)
+	if err != nil {
+		return GetImportSpecsResult{}, err
+	}
+
+	return GetImportSpecsResult{Resources: specs, File: file}, nil
+}
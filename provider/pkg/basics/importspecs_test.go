@@ -0,0 +1,42 @@
+package basics
+
+import "testing"
+
+func TestImportSpec(t *testing.T) {
+	spec, ok := importSpec(Message{Type: StoreMessageType, ItemHash: "b45669067fcd4484a0b9e8bab1c1c342", Channel: "TEST"})
+	if !ok {
+		t.Fatal("expected a spec for a STORE message")
+	}
+	if spec.Type != "twentysix:basics:TwentySixVolume" || spec.Name != "volume-b45669067fcd" || spec.Id != "b45669067fcd4484a0b9e8bab1c1c342" {
+		t.Fatalf("unexpected spec %+v", spec)
+	}
+
+	if _, ok := importSpec(Message{Type: PostMessageType, ItemHash: "aed594f916cb"}); ok {
+		t.Fatal("expected no spec for a POST message")
+	}
+
+	// TwentySixFunction isn't a registered resource
+	if _, ok := importSpec(Message{Type: ProgramMessageType, ItemHash: "aed594f916cb"}); ok {
+		t.Fatal("expected no spec for a PROGRAM message")
+	}
+}
+
+func TestImportFile(t *testing.T) {
+	file, err := importFile([]TwentySixImportSpec{{Type: "twentysix:basics:TwentySixInstance", Name: "instance-aed594f916cb", Id: "aed594f916cbd106", MessageType: "INSTANCE", Channel: "TEST"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{
+  "resources": [
+    {
+      "type": "twentysix:basics:TwentySixInstance",
+      "name": "instance-aed594f916cb",
+      "id": "aed594f916cbd106"
+    }
+  ]
+}`
+	if file != expected {
+		t.Fatalf("expected %s, got %s", expected, file)
+	}
+}
@@ -1,12 +1,12 @@
 package basics
 
 import (
-	"errors"
-	"log"
-	"reflect"
-	"time"
+	"fmt"
 
 	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi-go-provider/infer"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
 )
 
 // Each resource has a controlling struct.
@@ -23,11 +23,12 @@ type TwentySixInstance struct{}
 
 // Each resource has an input struct, defining what arguments it accepts.
 
+// Internet and alephApi default to true, the other flags to false.
 type TwentySixInstanceFunctionEnvironment struct {
-	Reproducible bool `pulumi:"reproducible"`
-	Internet     bool `pulumi:"internet"`
-	AlephApi     bool `pulumi:"alephApi"`
-	SharedCache  bool `pulumi:"sharedCache"`
+	Reproducible bool `pulumi:"reproducible,optional"`
+	Internet     bool `pulumi:"internet,optional"`
+	AlephApi     bool `pulumi:"alephApi,optional"`
+	SharedCache  bool `pulumi:"sharedCache,optional"`
 }
 
 type TwentySixInstanceMachineResources struct {
@@ -74,6 +75,7 @@ type TwentySixInstancePersistentVolume struct {
 	SizeMib     uint64            `pulumi:"sizeMib"` //Limit to 1 GiB
 }
 
+// Receiver is the CRN address superfluid payments stream to, on AVAX or BASE.
 type TwentySixInstancePayment struct {
 	Chain    MessageChain `pulumi:"chain"`
 	Receiver string       `pulumi:"receiver,optional"`
@@ -104,12 +106,59 @@ type TwentySixInstanceArgs struct {
 	Metadata       map[string]string                    `pulumi:"metadata,optional"`
 	AuthorizedKeys []string                             `pulumi:"authorizedKeys"`
 	Variables      map[string]string                    `pulumi:"variables,optional"`
-	Environment    TwentySixInstanceFunctionEnvironment `pulumi:"environment"`
+	Environment    TwentySixInstanceFunctionEnvironment `pulumi:"environment,optional"`
 	Resources      TwentySixInstanceMachineResources    `pulumi:"resources"`
 	Payment        TwentySixInstancePayment             `pulumi:"payment"`
 	Requirements   TwentySixInstanceHostRequirements    `pulumi:"requirements,optional"`
 	Volumes        []interface{}                        `pulumi:"volumes"`
 	Replaces       string                               `pulumi:"replaces,optional"`
+
+	// Parent images tried in order when the rootfs parent ref isn't usable. The ref the
+	// instance was created from is recorded in rootfsRef, and a fallback stays in use
+	// until the instance is replaced, even once the parent ref is usable again.
+	FallbackRootfsRefs []string `pulumi:"fallbackRootfsRefs,optional"`
+
+	// Tags are merged into the message metadata, explicit metadata wins on conflicts.
+	Tags map[string]string `pulumi:"tags,optional"`
+
+	// Seconds Delete waits for the message to be forgotten, and between two status polls.
+	// Zero uses the provider configuration.
+	ForgetTimeout  int64 `pulumi:"forgetTimeout,optional"`
+	ForgetInterval int64 `pulumi:"forgetInterval,optional"`
+
+	// Seconds Create waits for aleph to process the message, and between two status polls.
+	// Zero waits up to 120 seconds, polling every 5 seconds.
+	ConfirmationTimeout  int64 `pulumi:"confirmationTimeout,optional"`
+	ConfirmationInterval int64 `pulumi:"confirmationInterval,optional"`
+
+	// Replace the resource on any input change, defaults to the provider strictReplace.
+	StrictReplace *bool `pulumi:"strictReplace,optional"`
+
+	// Forget the instance message and the volumes it mounts which were stored earlier
+	// in the same deployment when the creation fails.
+	RollbackOnFailure bool `pulumi:"rollbackOnFailure,optional"`
+
+	// Wait for the scheduler to allocate the VM in Create, true by default. When false
+	// Create returns once the message is broadcast and a refresh reads the allocation.
+	WaitForSchedule *bool `pulumi:"waitForSchedule,optional"`
+
+	// Wait for the VM to be reachable over SSH before Create returns, it requires
+	// waitForSchedule.
+	VerifyBoot *TwentySixInstanceVerifyBoot `pulumi:"verifyBoot,optional"`
+
+	// Use the message item hash as the resource ID instead of the resource name, so
+	// that a resource can be imported from its hash. The ID is set at creation: a
+	// renamed resource keeps it, and a replaced one gets the hash of its new message.
+	UseMessageHashId bool `pulumi:"useMessageHashId,optional"`
+
+	// Refuse to forget the message on delete. Disable it and update the resource before
+	// destroying it.
+	DeleteProtection bool `pulumi:"deleteProtection,optional"`
+
+	// Pin the operations of the resource to a read node chosen at creation, recorded
+	// in pinnedNode, to read its message back from the node which received it. The
+	// API endpoints are used when the node can't be reached.
+	NodeAffinity bool `pulumi:"nodeAffinity,optional"`
 }
 
 // Each resource has a state, describing the fields that exist on the created resource.
@@ -120,91 +169,370 @@ type TwentySixInstanceState struct {
 	SchedulerAllocation SchedulerAllocation `pulumi:"schedulerAllocation"`
 	// Here we define a required output called result.
 	MessageHash string `pulumi:"messageHash"`
+
+	// Timestamp and channel the message was stamped with.
+	MessageTime    float64 `pulumi:"messageTime"`
+	MessageChannel string  `pulumi:"messageChannel"`
+
+	// Why aleph rejected the message, set by a refresh finding it rejected.
+	RejectionReason string `pulumi:"rejectionReason,optional"`
+
+	// ALEPH cost of the resource, read from aleph once the message is accepted.
+	Cost *TwentySixResourceCost `pulumi:"cost,optional"`
+
+	// Nodes which served the message right after it was broadcast, see verifyQuorum.
+	ConfirmedNodes []string `pulumi:"confirmedNodes,optional"`
+
+	// Hash of the last message amending the instance in place.
+	AmendHash string `pulumi:"amendHash,optional"`
+
+	// Hash of the rootfs parent image the instance was created from, the latest
+	// version of the image when useLatest is set.
+	RootfsImageHash string `pulumi:"rootfsImageHash,optional"`
+
+	// Parent image ref the instance was created from, the rootfs parent ref or one of
+	// the fallbacks.
+	RootfsRef string `pulumi:"rootfsRef,optional"`
+
+	// SSH host key of the VM and its known_hosts line, read from the VM once it booted.
+	// They stay empty while the VM can't be reached, a refresh fills them later.
+	SshHostKey      string `pulumi:"sshHostKey,optional"`
+	KnownHostsEntry string `pulumi:"knownHostsEntry,optional"`
+
+	// Read node the resource operations are pinned to, see nodeAffinity.
+	PinnedNode string `pulumi:"pinnedNode,optional"`
+
+	// Address of the VM, the node running it and its hash, from the scheduler allocation.
+	TwentySixVmAddress
 }
 
 // All resources must implement Create at a minimum.
 func (volume TwentySixInstance) Create(ctx p.Context, name string, input TwentySixInstanceArgs, preview bool) (string, TwentySixInstanceState, error) {
+	ctx, cancel := startOperation(ctx, "creating instance "+name)
+	defer cancel()
+
+	client := NewConfiguredClient(ctx, input.Account, input.Channel)
+
+	id, state, err := volume.create(ctx, &client, name, input, preview)
+	if err != nil && !preview && input.RollbackOnFailure {
+		rollbackInstance(ctx, &client, input, state.MessageHash)
+
+		// the rolled back message must not be resumed by the next Create
+		if pendingKey, keyErr := pendingInstanceKey(name, input); keyErr == nil {
+			if err := removePendingInstance(pendingKey); err != nil {
+				ctx.Logf(diag.Warning, "unable to clear the pending instance record: %s", err)
+			}
+		}
+		return "", TwentySixInstanceState{}, err
+	}
+
+	return id, state, err
+}
+
+// create returns the state holding the broadcasted message hash along with the error,
+// so that a failed creation can be rolled back.
+func (volume TwentySixInstance) create(ctx p.Context, client *TwentySixClient, name string, input TwentySixInstanceArgs, preview bool) (string, TwentySixInstanceState, error) {
 	state := TwentySixInstanceState{TwentySixInstanceArgs: input}
 
-	//create instance on aleph
-	client := NewTwentySixClient(input.Account, state.Channel)
-	message, response, err := client.CreateInstance(input)
+	// the rootfs parent may be a volume created by the same deployment, unknown in preview
+	if preview {
+		return name, state, nil
+	}
+
+	// fail before broadcasting rather than after a long scheduling wait
+	ref, image, err := client.ResolveRootfsCandidates(input.rootfsCandidates(), input.Rootfs.Parent.UseLatest)
+	if err != nil {
+		return "", TwentySixInstanceState{}, err
+	}
+
+	if ref != input.Rootfs.Parent.Ref {
+		ctx.Logf(diag.Warning, "rootfs parent image %s isn't usable, %s uses the fallback %s", input.Rootfs.Parent.Ref, name, ref)
+	}
+
+	state.RootfsImageHash = image.ItemHash
+	state.RootfsRef = ref
+
+	state.PinnedNode = pinAffinityNode(ctx, client, name, input.NodeAffinity)
+
+	if err := checkMinBalance(ctx, client); err != nil {
+		return "", TwentySixInstanceState{}, err
+	}
+
+	pendingKey, err := pendingInstanceKey(name, input)
 	if err != nil {
 		return "", TwentySixInstanceState{}, err
 	}
 
-	if response.Status == RejectedMessageStatus {
-		return "", TwentySixInstanceState{}, errors.New("an error occured on instance message")
+	pending, resumed := loadPendingInstance(pendingKey)
+	if resumed {
+		// a previous Create was interrupted after broadcasting, resume waiting for its
+		// allocation unless the message didn't make it
+		resumed, err = resumePendingInstance(ctx, client, pendingKey, pending)
+		if err != nil {
+			return "", TwentySixInstanceState{}, err
+		}
 	}
 
-	if response.PublicationStatus.Status != SucceedMessageStatus {
-		return "", TwentySixInstanceState{}, errors.New("an error occured on instance message")
+	if resumed {
+		ctx.Logf(diag.Info, "resuming the scheduling wait of instance message %s", pending.MessageHash)
+	} else {
+		//create instance on aleph
+		message, _, err := client.CreateInstance(state.withRootfsRef())
+		if err != nil {
+			return "", TwentySixInstanceState{}, err
+		}
+
+		pending = pendingInstance{
+			MessageHash: message.ItemHash,
+			MessageTime: message.Time,
+		}
+
+		if err := savePendingInstance(pendingKey, pending); err != nil {
+			ctx.Logf(diag.Warning, "unable to record instance message %s, an interrupted deployment won't resume: %s", pending.MessageHash, err)
+		}
 	}
 
-	state.MessageHash = message.ItemHash
+	state.MessageHash = pending.MessageHash
+	state.MessageTime = pending.MessageTime
+	// the pending record keys the channel by hash only, it may be a secret
+	state.MessageChannel = client.channel
 
-	//wait for instance ready buy checking on scheduler
-	instanceAvailable := false
+	if !resumed {
+		state.ConfirmedNodes, err = verifyBroadcast(ctx, client, state.MessageHash)
+		if err != nil {
+			return "", state, err
+		}
+	}
 
-	timeout := int64(1800)
-	startAt := time.Now().Unix()
-	for !instanceAvailable {
-		time.Sleep(10 * time.Second)
+	if waitForSchedule(input.WaitForSchedule) {
+		// the scheduler only allocates processed messages
+		if err := waitConfirmation(client, state.MessageHash, input.ConfirmationTimeout, input.ConfirmationInterval); err != nil {
+			return "", state, err
+		}
 
-		instanceState, err := client.GetInstanceState(message.ItemHash)
+		//wait for instance ready buy checking on scheduler
+		allocation, err := client.WaitAllocation(state.MessageHash, client.allocationWaitOptions())
 		if err != nil {
-			log.Println("error on retrieve instance state: ", err.Error())
-			now := time.Now().Unix()
-			if now > startAt+timeout {
-				return "", TwentySixInstanceState{}, errors.New("timeout waiting for instance")
+			return "", state, err
+		}
+
+		state.SchedulerAllocation = allocation
+		state.TwentySixVmAddress = allocationAddress(allocation)
+
+		if input.VerifyBoot != nil {
+			if err := client.VerifyBoot(allocation, *input.VerifyBoot); err != nil {
+				return "", state, err
 			}
-			continue
 		}
 
-		state.SchedulerAllocation = instanceState
-		instanceAvailable = true
+		state.SshHostKey, state.KnownHostsEntry = readSSHHostKey(ctx, allocation)
 	}
 
-	return name, state, nil
+	if err := removePendingInstance(pendingKey); err != nil {
+		ctx.Logf(diag.Warning, "unable to clear the pending instance record: %s", err)
+	}
+
+	state.Cost = resourceCost(ctx, client, state.MessageHash)
+
+	return resourceId(ctx, name, state.MessageHash, input.UseMessageHashId), state, nil
 }
 
-func (volume TwentySixInstance) Diff(ctx p.Context, name string, olds TwentySixInstanceState, news TwentySixInstanceArgs) (p.DiffResponse, error) {
+func (volume TwentySixInstance) Check(ctx p.Context, name string, oldInputs resource.PropertyMap, newInputs resource.PropertyMap) (TwentySixInstanceArgs, []p.CheckFailure, error) {
+	args, failures, err := infer.DefaultCheck[TwentySixInstanceArgs](newInputs)
+	if err != nil {
+		return args, failures, err
+	}
 
-	client := NewTwentySixClient(news.Account, news.Channel)
+	failures = append(failures, checkTags(args.Tags)...)
+	failures = append(failures, checkSecretChannel(newInputs)...)
+	failures = append(failures, checkAuthorizedKeys(args.AuthorizedKeys)...)
+	failures = append(failures, checkMachineResources(args.Resources.Vcpus, args.Resources.Memory)...)
+	failures = append(failures, checkPayment(args.Payment.Chain, args.Payment.Receiver, args.Payment.Type)...)
 
-	previous := TwentySixInstanceArgs{
-		Rootfs:         olds.Rootfs,
-		AllowAmend:     olds.AllowAmend,
-		Metadata:       olds.Metadata,
-		AuthorizedKeys: olds.AuthorizedKeys,
-		Variables:      olds.Variables,
-		Environment:    olds.Environment,
-		Resources:      olds.Resources,
-		Payment:        olds.Payment,
-		Requirements:   olds.Requirements,
-		Volumes:        olds.Volumes,
-		Replaces:       olds.Replaces,
+	if propertyUnset(newInputs, "environment", "internet") {
+		args.Environment.Internet = true
+	}
+	if propertyUnset(newInputs, "environment", "alephApi") {
+		args.Environment.AlephApi = true
 	}
 
-	_, err := client.GetInstanceState(olds.SchedulerAllocation.VmHash)
-	instanceStillExists := (err != nil)
+	if args.VerifyBoot != nil {
+		failures = append(failures, args.VerifyBoot.check()...)
 
-	if reflect.DeepEqual(previous, news) && instanceStillExists {
-		return p.DiffResponse{
-			DeleteBeforeReplace: false,
-			HasChanges:          false,
-		}, nil
-	} else {
+		if !waitForSchedule(args.WaitForSchedule) {
+			failures = append(failures, p.CheckFailure{
+				Property: "verifyBoot",
+				Reason:   "verifyBoot requires waitForSchedule",
+			})
+		}
+	}
+
+	for i := 0; i < len(args.FallbackRootfsRefs); i++ {
+		if args.FallbackRootfsRefs[i] == "" || args.FallbackRootfsRefs[i] == args.Rootfs.Parent.Ref {
+			failures = append(failures, p.CheckFailure{
+				Property: fmt.Sprintf("fallbackRootfsRefs[%d]", i),
+				Reason:   "fallback rootfs refs must be set and differ from the rootfs parent ref",
+			})
+		}
+	}
+
+	return args, failures, nil
+}
+
+// rootfsCandidates lists the rootfs parent ref followed by its fallbacks.
+func (args TwentySixInstanceArgs) rootfsCandidates() []string {
+	return append([]string{args.Rootfs.Parent.Ref}, args.FallbackRootfsRefs...)
+}
+
+// withRootfsRef returns the args with the rootfs parent ref the instance was created from.
+func (state TwentySixInstanceState) withRootfsRef() TwentySixInstanceArgs {
+	args := state.TwentySixInstanceArgs
+	if state.RootfsRef != "" {
+		args.Rootfs.Parent.Ref = state.RootfsRef
+	}
+	return args
+}
+
+func (volume TwentySixInstance) WireDependencies(f infer.FieldSelector, args *TwentySixInstanceArgs, state *TwentySixInstanceState) {
+	wireChannel(f, args, state, &args.Channel, &state.MessageChannel)
+}
+
+func (volume TwentySixInstance) Diff(ctx p.Context, name string, olds TwentySixInstanceState, news TwentySixInstanceArgs) (p.DiffResponse, error) {
+	ctx, cancel := startOperation(ctx, "diffing instance "+name)
+	defer cancel()
+
+	client := NewConfiguredClient(ctx, news.Account, news.Channel)
+	client.pinNode(olds.PinnedNode)
+
+	// an instance created without waiting for its schedule may not be allocated yet
+	instanceStillExists := true
+	if olds.SchedulerAllocation.VmHash != "" {
+		_, err := client.GetInstanceState(olds.SchedulerAllocation.VmHash)
+		instanceStillExists = (err == nil)
+	}
+
+	if !instanceStillExists {
+		logChanges(ctx, name, []string{"vm " + olds.SchedulerAllocation.VmHash + " is no longer allocated"})
 		return p.DiffResponse{
 			DeleteBeforeReplace: true,
 			HasChanges:          true,
+			DetailedDiff: map[string]p.PropertyDiff{
+				"schedulerAllocation": {Kind: p.UpdateReplace},
+			},
 		}, nil
 	}
+
+	// volumes are serialized in mount order, reordering them changes nothing
+	olds.Volumes = canonicalVolumes(olds.Volumes)
+	news.Volumes = canonicalVolumes(news.Volumes)
+
+	reasons := changeReasons(olds.TwentySixInstanceArgs, news)
+
+	// metadata and tags are cosmetic and can be changed without recreating the VM
+	updatable := append([]string{"metadata", "tags"}, providerOptionProperties...)
+	diff := diffArgs(olds.TwentySixInstanceArgs, news, updatable...)
+
+	// the ref of a latest image stays the same when the image is amended, only the
+	// resolved hash tells whether the instance runs an outdated image
+	sameParent := olds.Rootfs.Parent == news.Rootfs.Parent
+	if sameParent && news.Rootfs.Parent.UseLatest && olds.RootfsImageHash != "" {
+		image, err := client.ResolveRootfsParent(olds.withRootfsRef().Rootfs.Parent.Ref, true)
+		if err != nil {
+			return p.DiffResponse{}, err
+		}
+
+		if image.ItemHash != olds.RootfsImageHash {
+			diff["rootfs.parent.ref"] = p.PropertyDiff{Kind: p.UpdateReplace, InputDiff: true}
+			reasons = append(reasons, "rootfs image: "+olds.RootfsImageHash+" -> "+image.ItemHash)
+		}
+	}
+
+	logChanges(ctx, name, reasons)
+
+	if strictReplaceEnabled(ctx, news.StrictReplace) {
+		return strictDiffResponse(diff), nil
+	}
+
+	return diffResponse(diff), nil
+}
+
+func (volume TwentySixInstance) Update(ctx p.Context, name string, olds TwentySixInstanceState, news TwentySixInstanceArgs, preview bool) (TwentySixInstanceState, error) {
+	ctx, cancel := startOperation(ctx, "updating instance "+name)
+	defer cancel()
+
+	state := olds
+	state.TwentySixInstanceArgs = news
+
+	if preview || !olds.AllowAmend || onlyProviderOptionsChanged(olds.TwentySixInstanceArgs, news) {
+		return state, nil
+	}
+
+	amend := state.withRootfsRef()
+	amend.Replaces = olds.MessageHash
+
+	client := NewConfiguredClient(ctx, news.Account, news.Channel)
+	client.pinNode(olds.PinnedNode)
+	message, _, err := client.CreateInstance(amend)
+	if err != nil {
+		return TwentySixInstanceState{}, err
+	}
+
+	state.AmendHash = message.ItemHash
+
+	return state, nil
+}
+
+func (volume TwentySixInstance) Read(ctx p.Context, id string, inputs TwentySixInstanceArgs, state TwentySixInstanceState) (string, TwentySixInstanceArgs, TwentySixInstanceState, error) {
+	ctx, cancel := startOperation(ctx, "reading instance "+id)
+	defer cancel()
+
+	// an imported resource only has its ID, the message item hash
+	if state.MessageHash == "" {
+		state.MessageHash = id
+		warnPartialImport(ctx, "instance", id)
+	}
+
+	client := NewConfiguredClient(ctx, state.Account, state.Channel)
+	client.pinNode(state.PinnedNode)
+
+	gone, reason, err := readMessageState(&client, state.MessageHash)
+	if err != nil {
+		return "", TwentySixInstanceArgs{}, TwentySixInstanceState{}, err
+	}
+
+	// an empty ID tells Pulumi the instance no longer exists, the next update creates it again
+	if gone {
+		ctx.Logf(diag.Warning, "message %s of %s was forgotten", state.MessageHash, id)
+		return "", TwentySixInstanceArgs{}, TwentySixInstanceState{}, nil
+	}
+
+	if reason != "" {
+		ctx.Logf(diag.Warning, "message %s was rejected: %s", state.MessageHash, reason)
+	}
+	state.RejectionReason = reason
+
+	if reason == "" {
+		state.SchedulerAllocation = readAllocation(ctx, &client, state.MessageHash, state.SchedulerAllocation)
+		state.TwentySixVmAddress = allocationAddress(state.SchedulerAllocation)
+
+		if state.SshHostKey == "" {
+			state.SshHostKey, state.KnownHostsEntry = readSSHHostKey(ctx, state.SchedulerAllocation)
+		}
+	}
+
+	return id, inputs, state, nil
 }
 
 func (volume TwentySixInstance) Delete(ctx p.Context, name string, olds TwentySixInstanceState) error {
+	ctx, cancel := startOperation(ctx, "deleting instance "+name)
+	defer cancel()
+
+	if err := checkDeleteProtection(name, olds.DeleteProtection); err != nil {
+		return err
+	}
 
-	client := NewTwentySixClient(olds.Account, olds.Channel)
+	client := NewConfiguredClient(ctx, olds.Account, olds.Channel)
+	client.pinNode(olds.PinnedNode)
 	message, err := client.GetMessageByHash(olds.MessageHash)
 	if err != nil {
 		if err.Error() == "message not found" {
@@ -214,12 +542,12 @@ func (volume TwentySixInstance) Delete(ctx p.Context, name string, olds TwentySi
 		}
 	}
 
-	_, err = client.ForgetMessage(message.ItemHash)
-	if err != nil {
-		return err
+	hashes := []string{message.ItemHash}
+	if olds.AmendHash != "" {
+		hashes = append(hashes, olds.AmendHash)
 	}
 
-	return nil
+	return forgetAndWait(ctx, &client, hashes, olds.ForgetTimeout, olds.ForgetInterval)
 }
 
 //update-alternatives --set iptables /usr/sbin/iptables-legacy
@@ -0,0 +1,238 @@
+package basics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	p "github.com/pulumi/pulumi-go-provider"
+)
+
+func testInstanceArgs() TwentySixInstanceArgs {
+	return TwentySixInstanceArgs{
+		Channel: "TEST",
+		Rootfs: TwentySixInstanceRootFsVolume{
+			Parent:      TwentySixInstanceParentVolume{Ref: "6e30de68c6cedfa6b45240c2b51e52495ac6fb1bd4b36457b3d5ca307594d595", UseLatest: true},
+			Persistence: HostVolumePersistence,
+			SizeMib:     20480,
+		},
+		Metadata:       map[string]string{"name": "before"},
+		AuthorizedKeys: []string{},
+		Resources:      TwentySixInstanceMachineResources{Vcpus: 1, Memory: 2048, Seconds: 30},
+		Payment:        TwentySixInstancePayment{Chain: EthereumChain, Type: HoldPaymentType},
+		Volumes:        []interface{}{},
+	}
+}
+
+func TestInstanceDiffMetadataOnly(t *testing.T) {
+	olds := testInstanceArgs()
+	news := testInstanceArgs()
+	news.Metadata = map[string]string{"name": "after"}
+
+	response := diffResponse(diffArgs(olds, news, "metadata"))
+
+	if !response.HasChanges {
+		t.Fatal("expected changes")
+	}
+	if response.DeleteBeforeReplace {
+		t.Fatal("a metadata change must not replace the instance")
+	}
+	if kind := response.DetailedDiff["metadata"].Kind; kind != p.Update {
+		t.Fatalf("expected an update of metadata, got %q", kind)
+	}
+}
+
+func TestInstanceDiffResourceSpec(t *testing.T) {
+	olds := testInstanceArgs()
+	news := testInstanceArgs()
+	news.Metadata = map[string]string{"name": "after"}
+	news.Resources.Memory = 4096
+
+	response := diffResponse(diffArgs(olds, news, "metadata"))
+
+	if !response.DeleteBeforeReplace {
+		t.Fatal("a resources change must replace the instance")
+	}
+	if kind := response.DetailedDiff["resources.memory"].Kind; kind != p.UpdateReplace {
+		t.Fatalf("expected a replacement of resources.memory, got %q", kind)
+	}
+	if kind := response.DetailedDiff["metadata"].Kind; kind != p.Update {
+		t.Fatalf("expected an update of metadata, got %q", kind)
+	}
+}
+
+func TestInstanceDiffNoChanges(t *testing.T) {
+	olds := testInstanceArgs()
+	news := testInstanceArgs()
+	news.Metadata = map[string]string{"name": "before"}
+
+	if response := diffResponse(diffArgs(olds, news, "metadata")); response.HasChanges {
+		t.Fatalf("expected no changes, got %v", response.DetailedDiff)
+	}
+}
+
+func TestInstanceFallbackRootfsRefsWithoutAmend(t *testing.T) {
+	olds := testInstanceArgs()
+	news := testInstanceArgs()
+	news.FallbackRootfsRefs = []string{"b6ff5c3a8205d1ca4c7c3369300eeafff498b558f71b851aa2114afd0a532717"}
+
+	if !onlyProviderOptionsChanged(olds, news) {
+		t.Fatal("fallback rootfs refs aren't part of the message, changing them must not amend it")
+	}
+	if kind := diffArgs(olds, news, providerOptionProperties...)["fallbackRootfsRefs"].Kind; kind != p.Update {
+		t.Fatalf("expected an update of fallbackRootfsRefs, got %q", kind)
+	}
+
+	news.Metadata = map[string]string{"name": "after"}
+	if onlyProviderOptionsChanged(olds, news) {
+		t.Fatal("a metadata change must amend the message")
+	}
+}
+
+func TestInstanceDiffStrictReplace(t *testing.T) {
+	olds := testInstanceArgs()
+	news := testInstanceArgs()
+	news.Metadata = map[string]string{"name": "after"}
+	news.ForgetTimeout = 60
+
+	response := strictDiffResponse(diffArgs(olds, news, providerOptionProperties...))
+
+	if !response.DeleteBeforeReplace {
+		t.Fatal("a metadata change must replace the instance in strict mode")
+	}
+	if kind := response.DetailedDiff["metadata"].Kind; kind != p.UpdateReplace {
+		t.Fatalf("expected a replacement of metadata, got %q", kind)
+	}
+	if kind := response.DetailedDiff["forgetTimeout"].Kind; kind != p.Update {
+		t.Fatalf("expected an update of forgetTimeout, got %q", kind)
+	}
+}
+
+func TestPendingInstanceRoundTrip(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	key, err := pendingInstanceKey("vm", testInstanceArgs())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := loadPendingInstance(key); ok {
+		t.Fatal("expected no pending instance")
+	}
+
+	pending := pendingInstance{MessageHash: "hash", MessageTime: 1}
+	if err := savePendingInstance(key, pending); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, ok := loadPendingInstance(key)
+	if !ok || loaded != pending {
+		t.Fatalf("expected %+v, got %+v", pending, loaded)
+	}
+
+	if err := removePendingInstance(key); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := loadPendingInstance(key); ok {
+		t.Fatal("expected the pending instance to be removed")
+	}
+}
+
+func TestResumePendingInstance(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	var status atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status.Load() == "" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(`{"status":"` + status.Load().(string) + `","item_hash":"hash"}`))
+	}))
+	defer server.Close()
+
+	transport, err := newFailoverTransport([]string{server.URL}, NoFailover, 0, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "TEST")
+	client.http.Transport = transport
+
+	pending := pendingInstance{MessageHash: "hash", MessageTime: 1}
+	if err := savePendingInstance("key", pending); err != nil {
+		t.Fatal(err)
+	}
+
+	// an unreachable aleph mustn't broadcast a second instance
+	status.Store("")
+	if _, err := resumePendingInstance(newTestContext(), &client, "key", pending); err == nil {
+		t.Fatal("expected the status failure to fail the creation")
+	}
+	if _, ok := loadPendingInstance("key"); !ok {
+		t.Fatal("expected the pending instance to be kept")
+	}
+
+	status.Store(string(ProcessedMessageStatus))
+	if resumed, err := resumePendingInstance(newTestContext(), &client, "key", pending); err != nil || !resumed {
+		t.Fatalf("expected the processed message to resume, got %v %v", resumed, err)
+	}
+
+	status.Store(string(RejectedMessageStatus))
+	if resumed, err := resumePendingInstance(newTestContext(), &client, "key", pending); err != nil || resumed {
+		t.Fatalf("expected the rejected message to be broadcast again, got %v %v", resumed, err)
+	}
+	if _, ok := loadPendingInstance("key"); ok {
+		t.Fatal("expected the rejected pending instance to be discarded")
+	}
+}
+
+func TestInstanceWithRootfsRef(t *testing.T) {
+	args := testInstanceArgs()
+	args.FallbackRootfsRefs = []string{"fallback"}
+
+	candidates := args.rootfsCandidates()
+	if len(candidates) != 2 || candidates[0] != args.Rootfs.Parent.Ref || candidates[1] != "fallback" {
+		t.Fatalf("expected the parent ref followed by its fallbacks, got %v", candidates)
+	}
+
+	state := TwentySixInstanceState{TwentySixInstanceArgs: args, RootfsRef: "fallback"}
+	if ref := state.withRootfsRef().Rootfs.Parent.Ref; ref != "fallback" {
+		t.Fatalf("expected the message to use the chosen fallback, got %s", ref)
+	}
+	if state.Rootfs.Parent.Ref == "fallback" {
+		t.Fatal("the inputs must keep the parent ref")
+	}
+}
+
+func TestInstancePreviewSkipsRootfsLookup(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	transport, err := newFailoverTransport([]string{server.URL}, NoFailover, 0, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+	client.http.Transport = transport
+	client.readNodes = nil
+
+	// a parent volume created by the same deployment is unknown in preview
+	args := testInstanceArgs()
+	args.Rootfs.Parent.Ref = ""
+
+	if _, _, err := (TwentySixInstance{}).create(nil, &client, "vm", args, true); err != nil {
+		t.Fatalf("preview must not resolve the rootfs parent, got %s", err)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Fatalf("expected no request during preview, got %d", n)
+	}
+}
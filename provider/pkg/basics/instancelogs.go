@@ -0,0 +1,202 @@
+package basics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	p "github.com/pulumi/pulumi-go-provider"
+)
+
+// instanceLogsPollInterval spaces the log reads of a followed VM.
+const instanceLogsPollInterval = 2 * time.Second
+
+// NodeUnreachableError is returned when the CRN allocated to a VM can't be reached,
+// which doesn't tell whether the VM still runs.
+type NodeUnreachableError struct {
+	NodeUrl string
+	Err     error
+}
+
+func (err *NodeUnreachableError) Error() string {
+	return fmt.Sprintf("node %s is unreachable: %s", err.NodeUrl, err.Err)
+}
+
+func (err *NodeUnreachableError) Unwrap() error {
+	return err.Err
+}
+
+// crnLogEntry is a line of the recent VM output the CRN keeps.
+type crnLogEntry struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+	Time    string `json:"time"`
+}
+
+// GetInstanceLogs reads the recent output of the VM from its CRN, one line per entry.
+// With follow the reader keeps polling the node for new lines until it is closed or
+// the context is done.
+func (client *TwentySixClient) GetInstanceLogs(ctx context.Context, vmHash string, follow bool) (io.ReadCloser, error) {
+	ctx = client.requestContext(ctx)
+	token, err := client.BuildCRNAuthToken(vmHash)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := client.readInstanceLogs(ctx, token, vmHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if !follow {
+		return io.NopCloser(strings.NewReader(formatLogEntries(entries))), nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	reader, writer := io.Pipe()
+	go client.followInstanceLogs(ctx, token, vmHash, entries, instanceLogsPollInterval, writer)
+
+	return &followedLogs{PipeReader: reader, cancel: cancel}, nil
+}
+
+// followedLogs stops polling the node once closed.
+type followedLogs struct {
+	*io.PipeReader
+	cancel context.CancelFunc
+}
+
+func (logs *followedLogs) Close() error {
+	logs.cancel()
+	return logs.PipeReader.Close()
+}
+
+func (client *TwentySixClient) followInstanceLogs(ctx context.Context, token CRNAuthToken, vmHash string, seen []crnLogEntry, interval time.Duration, writer *io.PipeWriter) {
+	if _, err := io.WriteString(writer, formatLogEntries(seen)); err != nil {
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			writer.CloseWithError(ctx.Err())
+			return
+		case <-time.After(interval):
+		}
+
+		entries, err := client.readInstanceLogs(ctx, token, vmHash)
+		if err != nil {
+			writer.CloseWithError(err)
+			return
+		}
+
+		if _, err := io.WriteString(writer, formatLogEntries(newLogEntries(seen, entries))); err != nil {
+			return
+		}
+		seen = entries
+	}
+}
+
+func (client *TwentySixClient) readInstanceLogs(ctx context.Context, token CRNAuthToken, vmHash string) ([]crnLogEntry, error) {
+	path := "/control/machine/" + vmHash + "/logs"
+	signedOperation, err := token.signOperation("GET", path)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "GET", token.NodeUrl+path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Add("Accept", "application/json")
+	request.Header.Add("X-SignedPubKey", token.signedPubKey)
+	request.Header.Add("X-SignedOperation", signedOperation)
+
+	response, err := client.http.Do(request)
+	if err != nil {
+		return nil, &NodeUnreachableError{NodeUrl: token.NodeUrl, Err: err}
+	}
+
+	defer response.Body.Close()
+
+	resultBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode >= 300 {
+		return nil, fmt.Errorf("logs of vm %s failed with status %d: %s", vmHash, response.StatusCode, string(resultBody))
+	}
+
+	var entries []crnLogEntry
+	if err := json.Unmarshal(resultBody, &entries); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// newLogEntries returns the entries after the last one already seen. The node only
+// keeps the recent output, when the last seen entry rolled out every entry is new.
+func newLogEntries(seen []crnLogEntry, entries []crnLogEntry) []crnLogEntry {
+	if len(seen) == 0 {
+		return entries
+	}
+
+	last := seen[len(seen)-1]
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i] == last {
+			return entries[i+1:]
+		}
+	}
+
+	return entries
+}
+
+func formatLogEntries(entries []crnLogEntry) string {
+	var builder strings.Builder
+	for i := 0; i < len(entries); i++ {
+		builder.WriteString(strings.TrimSuffix(entries[i].Message, "\n"))
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
+
+// GetInstanceLogs is a provider function reading the recent output of a VM.
+type GetInstanceLogs struct{}
+
+type GetInstanceLogsArgs struct {
+	Account TwentySixAccountState `pulumi:"account"`
+	VmHash  string                `pulumi:"vmHash"`
+}
+
+type GetInstanceLogsResult struct {
+	VmHash  string `pulumi:"vmHash"`
+	NodeUrl string `pulumi:"nodeUrl"`
+	Logs    string `pulumi:"logs"`
+}
+
+func (GetInstanceLogs) Call(ctx p.Context, args GetInstanceLogsArgs) (GetInstanceLogsResult, error) {
+	client := NewConfiguredClient(ctx, args.Account, "")
+
+	token, err := client.BuildCRNAuthToken(args.VmHash)
+	if err != nil {
+		return GetInstanceLogsResult{}, err
+	}
+
+	entries, err := client.readInstanceLogs(ctx, token, args.VmHash)
+	if err != nil {
+		return GetInstanceLogsResult{}, err
+	}
+
+	return GetInstanceLogsResult{
+		VmHash:  args.VmHash,
+		NodeUrl: token.NodeUrl,
+		Logs:    formatLogEntries(entries),
+	}, nil
+}
@@ -0,0 +1,103 @@
+package basics
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testCRNAuthToken(t *testing.T, nodeUrl string) CRNAuthToken {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return CRNAuthToken{NodeUrl: nodeUrl, Domain: "localhost", signedPubKey: "{}", ephemeralKey: key}
+}
+
+func TestNewLogEntries(t *testing.T) {
+	a := crnLogEntry{Type: "stdout", Message: "a", Time: "1"}
+	b := crnLogEntry{Type: "stdout", Message: "b", Time: "2"}
+	c := crnLogEntry{Type: "stderr", Message: "c", Time: "3"}
+
+	cases := []struct {
+		seen     []crnLogEntry
+		entries  []crnLogEntry
+		expected int
+	}{
+		{nil, []crnLogEntry{a, b}, 2},
+		{[]crnLogEntry{a, b}, []crnLogEntry{a, b}, 0},
+		{[]crnLogEntry{a, b}, []crnLogEntry{a, b, c}, 1},
+		{[]crnLogEntry{a, b}, []crnLogEntry{b, c}, 1},
+		{[]crnLogEntry{a}, []crnLogEntry{b, c}, 2},
+	}
+
+	for i, c := range cases {
+		if entries := newLogEntries(c.seen, c.entries); len(entries) != c.expected {
+			t.Errorf("case %d: expected %d new entries, got %v", i, c.expected, entries)
+		}
+	}
+}
+
+func TestFollowInstanceLogs(t *testing.T) {
+	var mutex sync.Mutex
+	entries := []crnLogEntry{{Type: "stdout", Message: "booting", Time: "1"}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/control/machine/vm/logs" || r.Header.Get("X-SignedOperation") == "" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		json.NewEncoder(w).Encode(entries)
+		entries = append(entries, crnLogEntry{Type: "stdout", Message: "tick", Time: time.Now().String()})
+	}))
+	defer server.Close()
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+	token := testCRNAuthToken(t, server.URL)
+
+	seen, err := client.readInstanceLogs(context.Background(), token, "vm")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reader, writer := io.Pipe()
+	go client.followInstanceLogs(ctx, token, "vm", seen, 10*time.Millisecond, writer)
+	logs := &followedLogs{PipeReader: reader, cancel: cancel}
+
+	buffer := make([]byte, len("booting\ntick\ntick\n"))
+	if _, err := io.ReadFull(logs, buffer); err != nil {
+		t.Fatal(err)
+	}
+	if string(buffer) != "booting\ntick\ntick\n" {
+		t.Fatalf("expected each line once, got %q", buffer)
+	}
+
+	if err := logs.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadInstanceLogsUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+	_, err := client.readInstanceLogs(context.Background(), testCRNAuthToken(t, server.URL), "vm")
+
+	var unreachable *NodeUnreachableError
+	if !errors.As(err, &unreachable) || unreachable.NodeUrl != server.URL {
+		t.Fatalf("expected the node to be unreachable, got %v", err)
+	}
+}
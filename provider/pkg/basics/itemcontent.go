@@ -0,0 +1,67 @@
+package basics
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+)
+
+// offloadItemContent moves the content of an inline message larger than the configured
+// threshold to the storage engine. The message then switches to the storage item type:
+// its item hash stays the sha256 of the content, which nodes fetch from storage.
+func (client *TwentySixClient) offloadItemContent(message *Message) error {
+	if client.storageContentThreshold <= 0 || int64(len(message.ItemContent)) <= client.storageContentThreshold {
+		return nil
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	filepart, err := writer.CreateFormFile("file", message.ItemHash+".json")
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(filepart, message.ItemContent); err != nil {
+		return err
+	}
+	writer.Close()
+
+	response, err := client.upload(AlephApiUrl+client.apiPath("/storage/add_file"), writer.FormDataContentType(), body, int64(body.Len()))
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	resultBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+
+	var storeFileResponse StoreIPFSFileResponse
+	if err := json.Unmarshal(resultBody, &storeFileResponse); err != nil {
+		return err
+	}
+
+	if storeFileResponse.Hash != message.ItemHash {
+		return fmt.Errorf("stored item content hash %s does not match the item hash %s", storeFileResponse.Hash, message.ItemHash)
+	}
+
+	message.ItemType = StorageMessageItem
+	message.ItemContent = ""
+
+	return nil
+}
+
+// messageContent returns the content of a message read from the API. Content offloaded
+// to storage isn't inline, nodes return it fetched from storage in content.
+func messageContent(message Message) []byte {
+	if len(message.ItemContent) > 0 {
+		return []byte(message.ItemContent)
+	}
+
+	return message.Content
+}
@@ -0,0 +1,106 @@
+package basics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestOffloadItemContent(t *testing.T) {
+	stored := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v0/storage/add_file" {
+			return
+		}
+
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("expected the content as a file: %s", err)
+			return
+		}
+		content, _ := io.ReadAll(file)
+
+		hash := sha256.Sum256(content)
+		stored[hex.EncodeToString(hash[:])] = string(content)
+		fmt.Fprintf(w, `{"hash":"%s","status":"success"}`, hex.EncodeToString(hash[:]))
+	}))
+	defer server.Close()
+
+	transport, err := newFailoverTransport([]string{server.URL}, NoFailover, 0, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+	client.http.Transport = transport
+	client.storageContentThreshold = 64
+
+	newMessage := func(content string) Message {
+		hash := sha256.Sum256([]byte(content))
+		return Message{ItemHash: hex.EncodeToString(hash[:]), ItemType: InlineMessageItem, ItemContent: content}
+	}
+
+	// content up to the threshold stays inline
+	small := newMessage(`{"key":"small"}`)
+	if err := client.offloadItemContent(&small); err != nil {
+		t.Fatal(err)
+	}
+	if small.ItemType != InlineMessageItem || small.ItemContent != `{"key":"small"}` || len(stored) != 0 {
+		t.Fatalf("expected an inline message, got %+v", small)
+	}
+
+	large := newMessage(`{"key":"` + strings.Repeat("x", 64) + `"}`)
+	content := large.ItemContent
+	if err := client.offloadItemContent(&large); err != nil {
+		t.Fatal(err)
+	}
+	if large.ItemType != StorageMessageItem || large.ItemContent != "" {
+		t.Fatalf("expected a storage message, got %+v", large)
+	}
+	if stored[large.ItemHash] != content {
+		t.Fatalf("expected the content stored under the item hash, got %v", stored)
+	}
+
+	// a disabled threshold keeps every content inline
+	client.storageContentThreshold = 0
+	disabled := newMessage(content)
+	if err := client.offloadItemContent(&disabled); err != nil || disabled.ItemType != InlineMessageItem {
+		t.Fatalf("expected an inline message, got %+v %v", disabled, err)
+	}
+
+	// the stored file must be the content the item hash commits to
+	client.storageContentThreshold = 64
+	mismatch := newMessage(content)
+	mismatch.ItemHash = strings.Repeat("0", 64)
+	if err := client.offloadItemContent(&mismatch); err == nil || !strings.HasPrefix(err.Error(), "stored item content hash") {
+		t.Fatalf("expected a hash mismatch, got %v", err)
+	}
+}
+
+func TestMessageContent(t *testing.T) {
+	const hash = "6e30de68c6cedfa6b45240c2b51e52495ac6fb1bd4b36457b3d5ca307594d595"
+	content := `{"volumes":[{"mount":"/data","ref":"` + hash + `"}]}`
+
+	inline := Message{ItemType: InlineMessageItem, ItemContent: content}
+	if string(messageContent(inline)) != content {
+		t.Fatalf("expected the inline content, got %s", messageContent(inline))
+	}
+
+	// nodes return offloaded content fetched from storage
+	var offloaded Message
+	if err := json.Unmarshal([]byte(`{"item_type":"storage","item_content":null,"content":`+content+`}`), &offloaded); err != nil {
+		t.Fatal(err)
+	}
+	if string(messageContent(offloaded)) != content {
+		t.Fatalf("expected the content read back from the node, got %s", messageContent(offloaded))
+	}
+	if !referencesHash(messageContent(offloaded), hash) {
+		t.Fatal("a VM whose content was offloaded must still be found as a consumer")
+	}
+}
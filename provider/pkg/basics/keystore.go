@@ -0,0 +1,72 @@
+package basics
+
+import (
+	"errors"
+
+	"github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/google/uuid"
+	p "github.com/pulumi/pulumi-go-provider"
+)
+
+// EncryptKeystore wraps an hex encoded private key in a Web3 Secret Storage (v3) keystore.
+// The keystore is returned along with the address of the key.
+func EncryptKeystore(privateKey string, passphrase string) ([]byte, string, error) {
+	privateKeyBytes, err := hexutil.Decode(privateKey)
+	if err != nil {
+		return nil, "", errors.New("error casting private key to bytes")
+	}
+
+	privateKeyECDSA, err := crypto.ToECDSA(privateKeyBytes)
+	if err != nil {
+		return nil, "", errors.New("error casting private key to ECDSA")
+	}
+
+	id, err := uuid.NewRandom()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &keystore.Key{
+		Id:         id,
+		Address:    crypto.PubkeyToAddress(privateKeyECDSA.PublicKey),
+		PrivateKey: privateKeyECDSA,
+	}
+
+	keyJson, err := keystore.EncryptKey(key, passphrase, keystore.StandardScryptN, keystore.StandardScryptP)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return keyJson, key.Address.Hex(), nil
+}
+
+// ExportKeystore is a provider function exporting a private key as an encrypted keystore JSON.
+type ExportKeystore struct{}
+
+type ExportKeystoreArgs struct {
+	PrivateKey string `pulumi:"privateKey" provider:"secret"`
+	Passphrase string `pulumi:"passphrase" provider:"secret"`
+}
+
+type ExportKeystoreResult struct {
+	Keystore string `pulumi:"keystore" provider:"secret"`
+	Address  string `pulumi:"address"`
+}
+
+func (ExportKeystore) Call(ctx p.Context, args ExportKeystoreArgs) (ExportKeystoreResult, error) {
+	if args.Passphrase == "" {
+		return ExportKeystoreResult{}, errors.New("keystore passphrase can't be empty")
+	}
+
+	keyJson, address, err := EncryptKeystore(args.PrivateKey, args.Passphrase)
+	if err != nil {
+		return ExportKeystoreResult{}, err
+	}
+
+	return ExportKeystoreResult{
+		Keystore: string(keyJson),
+		Address:  address,
+	}, nil
+}
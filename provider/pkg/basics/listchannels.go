@@ -0,0 +1,63 @@
+package basics
+
+import (
+	"sort"
+
+	p "github.com/pulumi/pulumi-go-provider"
+)
+
+type TwentySixChannelUsage struct {
+	Channel  string `pulumi:"channel"`
+	Messages int    `pulumi:"messages"`
+}
+
+// ListChannels pages through all the messages of the address and counts them per channel.
+func (client *TwentySixClient) ListChannels(address string) ([]TwentySixChannelUsage, error) {
+	counts := map[string]int{}
+
+	messages, err := client.GetAllMessages(client.ctx, MessageFilter{Addresses: []string{address}})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(messages); i++ {
+		counts[messages[i].Channel]++
+	}
+
+	return channelUsages(counts), nil
+}
+
+func channelUsages(counts map[string]int) []TwentySixChannelUsage {
+	channels := make([]TwentySixChannelUsage, 0, len(counts))
+	for channel, messages := range counts {
+		channels = append(channels, TwentySixChannelUsage{Channel: channel, Messages: messages})
+	}
+
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i].Channel < channels[j].Channel
+	})
+
+	return channels
+}
+
+// ListChannels is a provider function listing the channels an account published to.
+type ListChannels struct{}
+
+type ListChannelsArgs struct {
+	Address string `pulumi:"address"`
+}
+
+type ListChannelsResult struct {
+	Channels []TwentySixChannelUsage `pulumi:"channels"`
+}
+
+func (ListChannels) Call(ctx p.Context, args ListChannelsArgs) (ListChannelsResult, error) {
+	client := NewConfiguredClient(ctx, TwentySixAccountState{}, "")
+
+	channels, err := client.ListChannels(args.Address)
+	if err != nil {
+		return ListChannelsResult{}, err
+	}
+
+	return ListChannelsResult{Channels: channels}, nil
+}
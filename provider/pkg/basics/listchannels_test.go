@@ -0,0 +1,17 @@
+package basics
+
+import "testing"
+
+func TestChannelUsages(t *testing.T) {
+	channels := channelUsages(map[string]int{"TEST": 3, "ALEPH-CLOUDSOLUTIONS": 1, "": 2})
+
+	expected := []TwentySixChannelUsage{{"", 2}, {"ALEPH-CLOUDSOLUTIONS", 1}, {"TEST", 3}}
+	if len(channels) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, channels)
+	}
+	for i := range expected {
+		if channels[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, channels)
+		}
+	}
+}
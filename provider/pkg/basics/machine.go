@@ -0,0 +1,76 @@
+package basics
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/ethereum/go-ethereum/common"
+	p "github.com/pulumi/pulumi-go-provider"
+)
+
+var (
+	paymentTypes            = []PaymentType{HoldPaymentType, SuperfluidPaymentType}
+	paymentChains           = []MessageChain{EthereumChain, SolanaChain, AvalancheChain, BaseChain}
+	superfluidPaymentChains = []MessageChain{AvalancheChain, BaseChain}
+)
+
+// checkMachineResources requires at least a vcpu and some memory.
+func checkMachineResources(vcpus uint64, memory uint64) []p.CheckFailure {
+	failures := []p.CheckFailure{}
+
+	if vcpus == 0 {
+		failures = append(failures, p.CheckFailure{
+			Property: "resources.vcpus",
+			Reason:   "vcpus must be greater than 0",
+		})
+	}
+
+	if memory == 0 {
+		failures = append(failures, p.CheckFailure{
+			Property: "resources.memory",
+			Reason:   "memory must be greater than 0",
+		})
+	}
+
+	return failures
+}
+
+// checkPayment validates the payment type and chain. A superfluid payment streams on
+// AVAX or BASE to the receiver, which must be an address.
+func checkPayment(chain MessageChain, receiver string, paymentType PaymentType) []p.CheckFailure {
+	failures := []p.CheckFailure{}
+
+	if !slices.Contains(paymentTypes, paymentType) {
+		failures = append(failures, p.CheckFailure{
+			Property: "payment.type",
+			Reason:   fmt.Sprintf("invalid payment type %q: expected one of %v", paymentType, paymentTypes),
+		})
+	}
+
+	if !slices.Contains(paymentChains, chain) {
+		failures = append(failures, p.CheckFailure{
+			Property: "payment.chain",
+			Reason:   fmt.Sprintf("invalid payment chain %q: expected one of %v", chain, paymentChains),
+		})
+	}
+
+	if paymentType != SuperfluidPaymentType {
+		return failures
+	}
+
+	if slices.Contains(paymentChains, chain) && !slices.Contains(superfluidPaymentChains, chain) {
+		failures = append(failures, p.CheckFailure{
+			Property: "payment.chain",
+			Reason:   fmt.Sprintf("superfluid payments stream on %v, not %s", superfluidPaymentChains, chain),
+		})
+	}
+
+	if !common.IsHexAddress(receiver) {
+		failures = append(failures, p.CheckFailure{
+			Property: "payment.receiver",
+			Reason:   fmt.Sprintf("superfluid payments require the receiver address, got %q", receiver),
+		})
+	}
+
+	return failures
+}
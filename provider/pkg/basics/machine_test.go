@@ -0,0 +1,42 @@
+package basics
+
+import "testing"
+
+func TestCheckMachineResources(t *testing.T) {
+	if failures := checkMachineResources(1, 2048); len(failures) != 0 {
+		t.Fatalf("expected valid resources, got %v", failures)
+	}
+
+	failures := checkMachineResources(0, 0)
+	if len(failures) != 2 || failures[0].Property != "resources.vcpus" || failures[1].Property != "resources.memory" {
+		t.Fatalf("expected vcpus and memory failures, got %v", failures)
+	}
+}
+
+func TestCheckPayment(t *testing.T) {
+	receiver := "0x4145f182EF2F06b45E50468519C1B92C60FBd4A0"
+
+	cases := []struct {
+		chain       MessageChain
+		receiver    string
+		paymentType PaymentType
+		failures    int
+	}{
+		{EthereumChain, "", HoldPaymentType, 0},
+		{SolanaChain, "", HoldPaymentType, 0},
+		{BaseChain, receiver, SuperfluidPaymentType, 0},
+		{AvalancheChain, receiver, SuperfluidPaymentType, 0},
+		{EthereumChain, "", "", 1},
+		{EthereumChain, "", "credit", 1},
+		{"DOGE", "", HoldPaymentType, 1},
+		{EthereumChain, receiver, SuperfluidPaymentType, 1},
+		{BaseChain, "", SuperfluidPaymentType, 1},
+		{BaseChain, "crn", SuperfluidPaymentType, 1},
+	}
+
+	for i, c := range cases {
+		if failures := checkPayment(c.chain, c.receiver, c.paymentType); len(failures) != c.failures {
+			t.Errorf("case %d: expected %d failures, got %v", i, c.failures, failures)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package basics
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common/hexutil"
@@ -36,7 +37,10 @@ const (
 	RejectedMessageStatus  MessageStatus = "rejected"
 	ForgottenMessageStatus MessageStatus = "forgotten"
 
-	EthereumChain MessageChain = "ETH"
+	EthereumChain  MessageChain = "ETH"
+	SolanaChain    MessageChain = "SOL"
+	AvalancheChain MessageChain = "AVAX"
+	BaseChain      MessageChain = "BASE"
 
 	HostVolumePersistence  VolumePersistence = "host"
 	StoreVolumePersistence VolumePersistence = "store"
@@ -71,6 +75,10 @@ type Message struct {
 
 	Confirmations []MessageConfirmation `json:"confirmations,omitempty"`
 	Confirmed     bool                  `json:"confirmed,omitempty"`
+
+	// Content is the item content completed by the node (e.g. the size of stored files),
+	// only set on messages read from the API.
+	Content json.RawMessage `json:"content,omitempty"`
 }
 
 type GetMessageResponse struct {
@@ -88,6 +96,15 @@ type StoreMessageContent struct {
 	ItemType MessageItemType `json:"item_type"`
 	ItemHash string          `json:"item_hash"`
 	Ref      string          `json:"ref,omitempty"`
+
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+type AggregateMessageContent struct {
+	Key     string                 `json:"key"`
+	Address string                 `json:"address"`
+	Time    float64                `json:"time"`
+	Content map[string]interface{} `json:"content"`
 }
 
 type ForgetMessageContent struct {
@@ -97,6 +114,7 @@ type ForgetMessageContent struct {
 }
 
 type ProgramMessageContent struct {
+	On             FunctionTriggers    `json:"on"`
 	Time           float64             `json:"time"`
 	Address        string              `json:"address"`
 	AllowAmend     bool                `json:"allow_amend"`
@@ -127,6 +145,11 @@ type InstanceMessageContent struct {
 	Replaces string        `json:"replaces,omitempty"`
 }
 
+type FunctionTriggers struct {
+	Http       bool `json:"http"`
+	Persistent bool `json:"persistent"`
+}
+
 type FunctionEnvironment struct {
 	Reproducible bool `json:"reproducible"`
 	Internet     bool `json:"internet"`
@@ -178,6 +201,8 @@ type PersistentVolume struct {
 	SizeMib     uint64            `json:"size_mib"` //Limit to 1 GiB
 }
 
+// Payment of a VM. A hold payment locks ALEPH tokens on the chain. A superfluid payment
+// streams them to the receiver, the address of the CRN operator, on AVAX or BASE.
 type Payment struct {
 	Chain    MessageChain `json:"chain"`
 	Receiver string       `json:"receiver,omitempty"`
@@ -230,12 +255,58 @@ type BroadcastResponse struct {
 	Response []byte        `json:"response"`
 }
 
+type PublicationStatus struct {
+	Status MessageStatus `json:"status"`
+	Failed []string      `json:"failed"`
+}
+
 type MessageResponse struct {
-	PublicationStatus struct {
-		Status MessageStatus `json:"status"`
-		Failed []string      `json:"failed"`
-	} `json:"publication_status"`
-	Status MessageStatus `json:"message_status"`
+	PublicationStatus PublicationStatus `json:"publication_status"`
+	Status            MessageStatus     `json:"message_status"`
+}
+
+// publicationError returns why the broadcast of the message failed, naming the nodes
+// that didn't publish it, or nil once it is published and not rejected.
+func (response MessageResponse) publicationError(kind string) error {
+	if response.Status == RejectedMessageStatus {
+		return fmt.Errorf("an error occured on %s message: rejected", kind)
+	}
+
+	if response.PublicationStatus.Status != SucceedMessageStatus {
+		if len(response.PublicationStatus.Failed) > 0 {
+			return fmt.Errorf("an error occured on %s message: publication status %q, failed on %s", kind, response.PublicationStatus.Status, strings.Join(response.PublicationStatus.Failed, ", "))
+		}
+
+		return fmt.Errorf("an error occured on %s message: publication status %q", kind, response.PublicationStatus.Status)
+	}
+
+	return nil
+}
+
+// storeFilePublicationError checks the answer of the storage endpoint to a file stored
+// along with its message. It carries the publication status of the message when the
+// node reports one, and only the status of the upload otherwise.
+func storeFilePublicationError(body []byte, response StoreIPFSFileResponse) error {
+	var publication MessageResponse
+	if err := json.Unmarshal(body, &publication); err != nil {
+		return err
+	}
+
+	if publication.PublicationStatus.Status != "" {
+		return publication.publicationError("store")
+	}
+
+	if response.Status != SucceedMessageStatus {
+		return fmt.Errorf("an error occured on store message: %s", body)
+	}
+
+	return nil
+}
+
+type MessageStatusResponse struct {
+	Status        MessageStatus `json:"status"`
+	ItemHash      string        `json:"item_hash"`
+	ReceptionTime string        `json:"reception_time"`
 }
 
 type SchedulerAllocation struct {
@@ -264,26 +335,41 @@ func (msg Message) getVerificationPayload() []byte {
 }
 
 func (msg *Message) SignMessage(pkey string) error {
-	messageHash := accounts.TextHash(msg.getVerificationPayload())
-	privateKeyBytes, err := hexutil.Decode(pkey)
+	sign := signPayload
+	if msg.Chain == SolanaChain {
+		sign = signSolanaPayload
+	}
+
+	signature, err := sign(pkey, msg.getVerificationPayload())
 	if err != nil {
 		return err
 	}
 
+	msg.Signature = signature
+	return nil
+}
+
+// signPayload produces an Ethereum personal signature (EIP-191) of the payload.
+func signPayload(pkey string, payload []byte) (string, error) {
+	messageHash := accounts.TextHash(payload)
+	privateKeyBytes, err := hexutil.Decode(pkey)
+	if err != nil {
+		return "", err
+	}
+
 	key, err := crypto.ToECDSA(privateKeyBytes)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	signature, err := crypto.Sign(messageHash, key)
 	if err != nil {
-		return err
+		return "", err
 	}
 
 	signature[crypto.RecoveryIDOffset] += 27
 
-	msg.Signature = hexutil.Encode(signature)
-	return nil
+	return hexutil.Encode(signature), nil
 }
 
 func (msg *Message) JSON() []byte {
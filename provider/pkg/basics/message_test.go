@@ -0,0 +1,128 @@
+package basics
+
+import (
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/mr-tron/base58"
+)
+
+func TestSignMessageRoundTrip(t *testing.T) {
+	ethereumKey, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	solanaSeed := make([]byte, ed25519.SeedSize)
+	for i := 0; i < len(solanaSeed); i++ {
+		solanaSeed[i] = byte(i)
+	}
+	solanaKeypair := ed25519.NewKeyFromSeed(solanaSeed)
+
+	cases := []struct {
+		chain   MessageChain
+		key     string
+		address string
+	}{
+		{EthereumChain, hexutil.Encode(crypto.FromECDSA(ethereumKey)), crypto.PubkeyToAddress(ethereumKey.PublicKey).Hex()},
+		{SolanaChain, base58.Encode(solanaSeed), solanaAddress(solanaKeypair)},
+		{SolanaChain, base58.Encode(solanaKeypair), solanaAddress(solanaKeypair)},
+	}
+
+	for i := 0; i < len(cases); i++ {
+		message := Message{
+			Chain:    cases[i].chain,
+			Sender:   cases[i].address,
+			Type:     PostMessageType,
+			ItemHash: "6e30de68c6cedfa6b45240c2b51e52495ac6fb1bd4b36457b3d5ca307594d595",
+		}
+
+		if err := message.SignMessage(cases[i].key); err != nil {
+			t.Fatalf("%s: %s", cases[i].chain, err)
+		}
+		if err := message.VerifySignature(); err != nil {
+			t.Fatalf("%s: %s", cases[i].chain, err)
+		}
+
+		message.ItemHash = "tampered"
+		if err := message.VerifySignature(); !errors.Is(err, ErrSignatureMismatch) {
+			t.Fatalf("%s: expected a signature mismatch on a tampered message, got %v", cases[i].chain, err)
+		}
+	}
+}
+
+func TestSolanaKey(t *testing.T) {
+	if _, err := solanaKey(base58.Encode([]byte{1, 2, 3})); err == nil {
+		t.Fatal("expected an error on a short key")
+	}
+
+	keypair := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	mismatched := append([]byte{}, keypair...)
+	mismatched[ed25519.PrivateKeySize-1] ^= 1
+	if _, err := solanaKey(base58.Encode(mismatched)); err == nil {
+		t.Fatal("expected an error on a keypair with a foreign public key")
+	}
+}
+
+func TestPaymentJSON(t *testing.T) {
+	client := NewTwentySixClient(TwentySixAccountState{}, "TEST")
+	content := client.instanceArgsToMessage(testInstanceArgs())
+
+	encoded, err := json.Marshal(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(encoded), `"payment":{"chain":"ETH","type":"hold"}`) {
+		t.Fatalf("expected a lowercase hold payment type, got %s", encoded)
+	}
+}
+
+func TestSuperfluidPaymentJSON(t *testing.T) {
+	args := testInstanceArgs()
+	args.Payment = TwentySixInstancePayment{
+		Chain:    BaseChain,
+		Receiver: "0x4145f182EF2F06b45E50468519C1B92C60FBd4A0",
+		Type:     SuperfluidPaymentType,
+	}
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "TEST")
+	encoded, err := json.Marshal(client.instanceArgsToMessage(args))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `"payment":{"chain":"BASE","receiver":"0x4145f182EF2F06b45E50468519C1B92C60FBd4A0","type":"superfluid"}`
+	if !strings.Contains(string(encoded), expected) {
+		t.Fatalf("expected %s, got %s", expected, encoded)
+	}
+}
+
+func TestPublicationError(t *testing.T) {
+	var published MessageResponse
+	if err := json.Unmarshal([]byte(`{"publication_status":{"status":"success","failed":[]},"message_status":"pending"}`), &published); err != nil {
+		t.Fatal(err)
+	}
+	if err := published.publicationError("instance"); err != nil {
+		t.Fatalf("expected a published message, got %s", err)
+	}
+
+	var partial MessageResponse
+	if err := json.Unmarshal([]byte(`{"publication_status":{"status":"warning","failed":["p2p","ipfs"]},"message_status":"pending"}`), &partial); err != nil {
+		t.Fatal(err)
+	}
+	expected := `an error occured on instance message: publication status "warning", failed on p2p, ipfs`
+	if err := partial.publicationError("instance"); err == nil || err.Error() != expected {
+		t.Fatalf("expected %q, got %v", expected, err)
+	}
+
+	rejected := MessageResponse{PublicationStatus: PublicationStatus{Status: SucceedMessageStatus}, Status: RejectedMessageStatus}
+	if err := rejected.publicationError("instance"); err == nil {
+		t.Fatal("expected a rejected message to fail")
+	}
+}
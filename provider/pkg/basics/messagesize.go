@@ -0,0 +1,20 @@
+package basics
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxItemContentSize is the largest inline item content aleph nodes accept, in bytes.
+const MaxItemContentSize = 200 * 1000
+
+var ErrMessageTooLarge = errors.New("message too large")
+
+// checkMessageSize rejects a message whose item content nodes would refuse.
+func checkMessageSize(message Message) error {
+	if len(message.ItemContent) <= MaxItemContentSize {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d bytes of item content, at most %d are allowed; move large content to storage, e.g. with the storageContentThreshold provider setting", ErrMessageTooLarge, len(message.ItemContent), MaxItemContentSize)
+}
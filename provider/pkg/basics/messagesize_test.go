@@ -0,0 +1,20 @@
+package basics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCheckMessageSize(t *testing.T) {
+	message := Message{ItemHash: "hash", ItemContent: strings.Repeat("a", MaxItemContentSize)}
+	if err := checkMessageSize(message); err != nil {
+		t.Fatalf("expected a content at the limit to pass, got %s", err)
+	}
+
+	message.ItemContent += "a"
+	err := checkMessageSize(message)
+	if !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("expected ErrMessageTooLarge, got %v", err)
+	}
+}
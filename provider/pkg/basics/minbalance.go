@@ -0,0 +1,54 @@
+package basics
+
+import (
+	"fmt"
+	"sync"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi-go-provider/infer"
+)
+
+// Outcome of the balance check of each account, checked once per provider process so
+// that a deployment makes a single balance request per account.
+var minBalanceChecks = struct {
+	sync.Mutex
+	results map[string]error
+}{results: map[string]error{}}
+
+// checkMinBalance makes sure the account signing a new resource holds at least the
+// requireMinBalance of the provider configuration, not counting the ALEPH already held
+// by its messages.
+func checkMinBalance(ctx p.Context, client *TwentySixClient) error {
+	minBalance := infer.GetConfig[TwentySixConfig](ctx).RequireMinBalance
+	if minBalance <= 0 {
+		return nil
+	}
+
+	address := client.account.Address
+
+	minBalanceChecks.Lock()
+	defer minBalanceChecks.Unlock()
+
+	if err, checked := minBalanceChecks.results[address]; checked {
+		return err
+	}
+
+	balance, err := client.GetBalance(address)
+	if err != nil {
+		// a failed request isn't a verdict, the next resource checks again
+		return fmt.Errorf("unable to check the balance of %s: %w", address, err)
+	}
+
+	err = minBalanceError(address, balance, minBalance)
+	minBalanceChecks.results[address] = err
+	return err
+}
+
+func minBalanceError(address string, balance AddressBalanceResponse, minBalance float64) error {
+	available := balance.Balance - balance.LockedAmount
+	if available >= minBalance {
+		return nil
+	}
+
+	return fmt.Errorf("account %s has %g ALEPH available (%g held by its messages), below the required minimum of %g", address, available, balance.LockedAmount, minBalance)
+}
@@ -0,0 +1,52 @@
+package basics
+
+import (
+	"net/url"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+)
+
+// selectAffinityNode returns the first read node answering, empty when none does.
+func (client *TwentySixClient) selectAffinityNode() string {
+	for i := 0; i < len(client.readNodes); i++ {
+		if client.PingEndpoint(client.readNodes[i] + client.apiPath("/info/public.json")).Reachable {
+			return client.readNodes[i]
+		}
+	}
+
+	return ""
+}
+
+// pinNode sends the API requests of the client to the node first, falling back to the
+// API endpoints when the node can't be reached.
+func (client *TwentySixClient) pinNode(node string) {
+	if node == "" || client.failover == nil {
+		return
+	}
+
+	endpoint, err := url.ParseRequestURI(node)
+	if err != nil {
+		return
+	}
+
+	client.failover.pin(endpoint)
+}
+
+// pinAffinityNode pins the client of a created resource to a node chosen when
+// nodeAffinity is set, and returns the node to record in the state. The following
+// operations of the resource pin the same node, which most likely holds its message.
+func pinAffinityNode(ctx p.Context, client *TwentySixClient, name string, nodeAffinity bool) string {
+	if !nodeAffinity {
+		return ""
+	}
+
+	node := client.selectAffinityNode()
+	if node == "" {
+		ctx.Logf(diag.Warning, "%s: no read node is reachable, the resource isn't pinned to a node", name)
+		return ""
+	}
+
+	client.pinNode(node)
+	return node
+}
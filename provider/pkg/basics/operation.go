@@ -0,0 +1,103 @@
+package basics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi-go-provider/infer"
+)
+
+type operationKey struct{}
+
+// operationContext is the context of a resource operation: it logs through the Pulumi
+// context, and its deadline and cancellation bound every request and wait of the
+// operation.
+type operationContext struct {
+	p.Context
+	ctx context.Context
+}
+
+func (operation operationContext) Deadline() (time.Time, bool) {
+	return operation.ctx.Deadline()
+}
+
+func (operation operationContext) Done() <-chan struct{} {
+	return operation.ctx.Done()
+}
+
+func (operation operationContext) Err() error {
+	return operation.ctx.Err()
+}
+
+func (operation operationContext) Value(key any) any {
+	return operation.ctx.Value(key)
+}
+
+// startOperation bounds the operation to the provider operationTimeout, on top of any
+// deadline set by Pulumi. The name describes the operation in timeout errors.
+func startOperation(ctx p.Context, name string) (p.Context, context.CancelFunc) {
+	config := infer.GetConfig[TwentySixConfig](ctx)
+
+	var operation context.Context = ctx
+	var cancel context.CancelFunc
+	if config.OperationTimeout > 0 {
+		operation, cancel = context.WithTimeout(operation, time.Duration(config.OperationTimeout)*time.Second)
+	} else {
+		operation, cancel = context.WithCancel(operation)
+	}
+
+	return operationContext{Context: ctx, ctx: context.WithValue(operation, operationKey{}, name)}, cancel
+}
+
+// operationError returns the error naming the operation once its context is done.
+func operationError(ctx context.Context) error {
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+
+	name, _ := ctx.Value(operationKey{}).(string)
+	if name == "" {
+		name = "operation"
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%s exceeded the operation timeout: %w", name, err)
+	}
+
+	return fmt.Errorf("%s was cancelled: %w", name, err)
+}
+
+// sleepContext sleeps for the duration, or until the context is done.
+func sleepContext(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return operationError(ctx)
+	case <-timer.C:
+		return nil
+	}
+}
+
+// contextTransport sends every request with the operation context.
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (transport *contextTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	response, err := transport.base.RoundTrip(request.WithContext(transport.ctx))
+	if err != nil {
+		if opErr := operationError(transport.ctx); opErr != nil {
+			return nil, opErr
+		}
+	}
+
+	return response, err
+}
@@ -0,0 +1,53 @@
+package basics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWaitUntilOperationTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	ctx = context.WithValue(ctx, operationKey{}, "creating volume data")
+
+	err := waitUntil(ctx, WaitOptions{Timeout: time.Minute, Interval: 5 * time.Millisecond}, func() (bool, error) {
+		return false, nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+	if !strings.HasPrefix(err.Error(), "creating volume data exceeded the operation timeout") {
+		t.Fatalf("expected the operation to be named, got %v", err)
+	}
+}
+
+func TestCancelledOperationAbortsRequests(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = context.WithValue(ctx, operationKey{}, "deleting instance vm")
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+	client.http.Transport = &contextTransport{ctx: ctx, base: client.http.Transport}
+
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := client.http.Get(server.URL)
+	if !errors.Is(err, context.Canceled) || !strings.Contains(err.Error(), "deleting instance vm was cancelled") {
+		t.Fatalf("expected the cancelled operation error, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Fatal("expected the request to abort promptly")
+	}
+}
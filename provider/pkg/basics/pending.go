@@ -0,0 +1,118 @@
+package basics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+)
+
+// pendingInstance is an instance message broadcast by an interrupted Create, still
+// waiting for its allocation. Pulumi doesn't record anything of a Create that never
+// returned, so it is kept on disk until the instance gets allocated. The channel,
+// which may be a secret, isn't written: the message was sent on the input channel.
+type pendingInstance struct {
+	MessageHash string  `json:"message_hash"`
+	MessageTime float64 `json:"message_time"`
+}
+
+func pendingInstancesDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(cacheDir, "pulumi-twentysix", "pending-instances"), nil
+}
+
+// pendingInstanceKey identifies the Create of a resource, same name and same inputs.
+func pendingInstanceKey(name string, input TwentySixInstanceArgs) (string, error) {
+	content, err := json.Marshal(struct {
+		Name  string
+		Input TwentySixInstanceArgs
+	}{name, input})
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(content)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func loadPendingInstance(key string) (pendingInstance, bool) {
+	dir, err := pendingInstancesDir()
+	if err != nil {
+		return pendingInstance{}, false
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, key+".json"))
+	if err != nil {
+		return pendingInstance{}, false
+	}
+
+	var pending pendingInstance
+	if err := json.Unmarshal(content, &pending); err != nil || pending.MessageHash == "" {
+		return pendingInstance{}, false
+	}
+
+	return pending, true
+}
+
+func savePendingInstance(key string, pending pendingInstance) error {
+	dir, err := pendingInstancesDir()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	content, err := json.Marshal(pending)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(dir, key+".json"), content, 0o600)
+}
+
+func removePendingInstance(key string) error {
+	dir, err := pendingInstancesDir()
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(filepath.Join(dir, key+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// resumePendingInstance tells whether the Create can resume waiting for the pending
+// message. Only a rejected or forgotten message is broadcast again, an unknown status
+// fails the Create rather than risking a second instance.
+func resumePendingInstance(ctx p.Context, client *TwentySixClient, key string, pending pendingInstance) (bool, error) {
+	status, err := client.GetMessageStatus(pending.MessageHash)
+	if err != nil {
+		return false, fmt.Errorf("unable to check the status of the pending instance message %s: %w", pending.MessageHash, err)
+	}
+
+	if status.Status != RejectedMessageStatus && status.Status != ForgottenMessageStatus {
+		return true, nil
+	}
+
+	ctx.Logf(diag.Info, "pending instance message %s is %s, broadcasting it again", pending.MessageHash, status.Status)
+	if err := removePendingInstance(key); err != nil {
+		ctx.Logf(diag.Warning, "unable to discard the pending instance message %s: %s", pending.MessageHash, err)
+	}
+
+	return false, nil
+}
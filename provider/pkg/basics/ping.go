@@ -0,0 +1,130 @@
+package basics
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi-go-provider/infer"
+)
+
+type PingStatus string
+
+const (
+	OkPingStatus                PingStatus = "ok"
+	DnsFailurePingStatus        PingStatus = "dns_failure"
+	ConnectionRefusedPingStatus PingStatus = "connection_refused"
+	HttpErrorPingStatus         PingStatus = "http_error"
+	ErrorPingStatus             PingStatus = "error"
+)
+
+type PingEndpointResult struct {
+	Url        string     `pulumi:"url"`
+	Reachable  bool       `pulumi:"reachable"`
+	Status     PingStatus `pulumi:"status"`
+	HttpStatus int        `pulumi:"httpStatus"`
+	LatencyMs  float64    `pulumi:"latencyMs"`
+	Error      string     `pulumi:"error"`
+}
+
+// PingEndpoint issues a GET request to the url and classifies how it went.
+func (client *TwentySixClient) PingEndpoint(url string) PingEndpointResult {
+	return pingEndpoint(&client.http, url)
+}
+
+func pingEndpoint(httpClient *http.Client, url string) PingEndpointResult {
+	result := PingEndpointResult{Url: url}
+
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		result.Status = ErrorPingStatus
+		result.Error = err.Error()
+		return result
+	}
+
+	startAt := time.Now()
+	response, err := httpClient.Do(request)
+	result.LatencyMs = float64(time.Since(startAt).Microseconds()) / 1000
+	if err != nil {
+		result.Status = pingErrorStatus(err)
+		result.Error = err.Error()
+		return result
+	}
+
+	defer response.Body.Close()
+
+	result.HttpStatus = response.StatusCode
+	if response.StatusCode >= 400 {
+		result.Status = HttpErrorPingStatus
+		result.Error = response.Status
+		return result
+	}
+
+	result.Status = OkPingStatus
+	result.Reachable = true
+	return result
+}
+
+// pingApis pings every API endpoint once, without the retries and the failover of the
+// client which would report a healthy endpoint for a broken one.
+func pingApis(httpClient *http.Client, apiUrls []string, path string) []PingEndpointResult {
+	results := make([]PingEndpointResult, len(apiUrls))
+	for i := 0; i < len(apiUrls); i++ {
+		results[i] = pingEndpoint(httpClient, apiUrls[i]+path)
+	}
+
+	return results
+}
+
+func pingErrorStatus(err error) PingStatus {
+	var dnsError *net.DNSError
+	if errors.As(err, &dnsError) {
+		return DnsFailurePingStatus
+	}
+
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ConnectionRefusedPingStatus
+	}
+
+	return ErrorPingStatus
+}
+
+// Ping is a provider function checking the Aleph API endpoints and the scheduler can
+// be reached.
+type Ping struct{}
+
+type PingArgs struct{}
+
+// PingResult reports each configured API endpoint in apis, api being the first one.
+type PingResult struct {
+	Api       PingEndpointResult   `pulumi:"api"`
+	Apis      []PingEndpointResult `pulumi:"apis"`
+	Scheduler PingEndpointResult   `pulumi:"scheduler"`
+}
+
+func (Ping) Call(ctx p.Context, args PingArgs) (PingResult, error) {
+	config := infer.GetConfig[TwentySixConfig](ctx)
+	client := NewConfiguredClient(ctx, TwentySixAccountState{}, "")
+
+	apiUrls := DefaultApiUrls
+	if len(config.ApiUrls) > 0 {
+		apiUrls = config.ApiUrls
+	}
+
+	userAgent := defaultUserAgent()
+	if config.UserAgent != "" {
+		userAgent = config.UserAgent
+	}
+
+	httpClient := &http.Client{Timeout: client.http.Timeout, Transport: newUserAgentTransport(userAgent)}
+
+	apis := pingApis(httpClient, apiUrls, client.apiPath("/info/public.json"))
+	return PingResult{
+		Api:       apis[0],
+		Apis:      apis,
+		Scheduler: pingEndpoint(httpClient, SchedulerApiUrl+"/api/v0/plan"),
+	}, nil
+}
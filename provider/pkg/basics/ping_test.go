@@ -0,0 +1,63 @@
+package basics
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPingEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closedUrl := "http://" + listener.Addr().String()
+	listener.Close()
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+
+	cases := map[string]PingStatus{
+		server.URL:                      OkPingStatus,
+		server.URL + "/broken":          HttpErrorPingStatus,
+		closedUrl:                       ConnectionRefusedPingStatus,
+		"http://twentysix.invalid/ping": DnsFailurePingStatus,
+	}
+	for url, expected := range cases {
+		result := client.PingEndpoint(url)
+		if result.Status != expected {
+			t.Errorf("%s: expected status %s, got %s (%s)", url, expected, result.Status, result.Error)
+		}
+		if result.Reachable != (expected == OkPingStatus) {
+			t.Errorf("%s: unexpected reachable %v", url, result.Reachable)
+		}
+	}
+}
+
+func TestPingApis(t *testing.T) {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer healthy.Close()
+
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	results := pingApis(&http.Client{}, []string{broken.URL, healthy.URL}, "/api/v0/info/public.json")
+	if len(results) != 2 {
+		t.Fatalf("expected a result per endpoint, got %v", results)
+	}
+	if results[0].Url != broken.URL+"/api/v0/info/public.json" || results[0].Status != HttpErrorPingStatus {
+		t.Fatalf("expected the broken endpoint to be reported, got %+v", results[0])
+	}
+	if results[1].Url != healthy.URL+"/api/v0/info/public.json" || !results[1].Reachable {
+		t.Fatalf("expected the healthy endpoint to be reachable, got %+v", results[1])
+	}
+}
@@ -0,0 +1,59 @@
+package basics
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	DefaultPropagationTimeout int64 = 60
+
+	propagationInitialBackoff = 500 * time.Millisecond
+	propagationMaxBackoff     = 8 * time.Second
+)
+
+var ErrPropagationTimeout = errors.New("message not propagated")
+
+// propagationWaitOptions back off exponentially up to the propagation timeout.
+func (client *TwentySixClient) propagationWaitOptions() WaitOptions {
+	return WaitOptions{
+		Timeout:     time.Duration(client.propagationTimeout) * time.Second,
+		Interval:    propagationInitialBackoff,
+		MaxInterval: propagationMaxBackoff,
+		Backoff:     2,
+	}
+}
+
+// waitVolumePropagated looks the STORE message of an uploaded file up until the nodes
+// know about it.
+func (client *TwentySixClient) waitVolumePropagated(fileHash string) (Message, error) {
+	return retryReadAfterWrite(client.ctx, client.propagationWaitOptions(), func() (Message, error) {
+		return client.GetVolumeByItemHash(fileHash)
+	})
+}
+
+// retryReadAfterWrite retries a lookup as long as it reports the message as not found.
+// Any other error is returned as is.
+func retryReadAfterWrite(ctx context.Context, options WaitOptions, lookup func() (Message, error)) (Message, error) {
+	var message Message
+
+	err := waitUntil(ctx, options, func() (bool, error) {
+		var err error
+		message, err = lookup()
+		if err == nil {
+			return true, nil
+		}
+
+		if err.Error() != "volume not found" && err.Error() != "message not found" {
+			return false, err
+		}
+
+		return false, nil
+	})
+	if errors.Is(err, errWaitTimeout) {
+		return Message{}, ErrPropagationTimeout
+	}
+
+	return message, err
+}
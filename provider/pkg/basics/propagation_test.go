@@ -0,0 +1,38 @@
+package basics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryReadAfterWrite(t *testing.T) {
+	attempts := 0
+	message, err := retryReadAfterWrite(context.Background(), WaitOptions{Timeout: time.Second, Interval: time.Millisecond, Backoff: 2}, func() (Message, error) {
+		attempts++
+		if attempts < 3 {
+			return Message{}, errors.New("volume not found")
+		}
+		return Message{ItemHash: "found"}, nil
+	})
+	if err != nil || message.ItemHash != "found" || attempts != 3 {
+		t.Fatalf("expected the message on the third attempt, got %v after %d attempts", err, attempts)
+	}
+
+	_, err = retryReadAfterWrite(context.Background(), WaitOptions{Timeout: 10 * time.Millisecond, Interval: time.Millisecond, Backoff: 2}, func() (Message, error) {
+		return Message{}, errors.New("volume not found")
+	})
+	if !errors.Is(err, ErrPropagationTimeout) {
+		t.Fatalf("expected a propagation timeout, got %v", err)
+	}
+
+	attempts = 0
+	_, err = retryReadAfterWrite(context.Background(), WaitOptions{Timeout: time.Second, Interval: time.Millisecond, Backoff: 2}, func() (Message, error) {
+		attempts++
+		return Message{}, errors.New("connection refused")
+	})
+	if err == nil || attempts != 1 {
+		t.Fatalf("expected other errors to be returned at once, got %v after %d attempts", err, attempts)
+	}
+}
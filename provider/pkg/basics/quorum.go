@@ -0,0 +1,82 @@
+package basics
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+)
+
+// VerifyQuorum queries the API and the read nodes concurrently until at least quorum
+// of them return the message, and returns the nodes which confirmed it.
+func (client *TwentySixClient) VerifyQuorum(hash string, quorum int, options WaitOptions) ([]string, error) {
+	nodes := client.readUrls()
+	if quorum > len(nodes) {
+		return nil, fmt.Errorf("quorum of %d can't be reached with %d nodes", quorum, len(nodes))
+	}
+
+	confirmed := map[string]bool{}
+
+	err := waitUntil(client.ctx, options, func() (bool, error) {
+		// every goroutine only writes its own slot, confirmed is updated once they are done
+		found := make([]bool, len(nodes))
+		var wg sync.WaitGroup
+
+		for i, node := range nodes {
+			if confirmed[node] {
+				continue
+			}
+
+			wg.Add(1)
+			go func(i int, node string) {
+				defer wg.Done()
+
+				if _, err := client.getMessageByHash(node, hash); err == nil {
+					found[i] = true
+				}
+			}(i, node)
+		}
+		wg.Wait()
+
+		for i, node := range nodes {
+			if found[i] {
+				confirmed[node] = true
+			}
+		}
+
+		return len(confirmed) >= quorum, nil
+	})
+
+	confirmedNodes := []string{}
+	for _, node := range nodes {
+		if confirmed[node] {
+			confirmedNodes = append(confirmedNodes, node)
+		}
+	}
+
+	if errors.Is(err, errWaitTimeout) {
+		return confirmedNodes, fmt.Errorf("message %s only confirmed by %d of the %d required nodes", hash, len(confirmedNodes), quorum)
+	}
+
+	return confirmedNodes, err
+}
+
+// verifyBroadcast waits for the configured quorum of nodes to serve a newly
+// broadcast message, so that dependent resources can reference it right away.
+// Without a quorum configured it returns nothing.
+func verifyBroadcast(ctx p.Context, client *TwentySixClient, hash string) ([]string, error) {
+	if client.verifyQuorum == 0 {
+		return nil, nil
+	}
+
+	nodes, err := client.VerifyQuorum(hash, client.verifyQuorum, client.propagationWaitOptions())
+	if err != nil {
+		return nodes, err
+	}
+
+	ctx.Logf(diag.Info, "message %s confirmed by %s", hash, strings.Join(nodes, ", "))
+	return nodes, nil
+}
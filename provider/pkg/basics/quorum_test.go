@@ -0,0 +1,45 @@
+package basics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVerifyQuorum(t *testing.T) {
+	found := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"messages":[{"item_hash":"hash"}],"pagination_total":1}`))
+	}))
+	defer found.Close()
+
+	missing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"messages":[],"pagination_total":0}`))
+	}))
+	defer missing.Close()
+
+	// the load balancer doesn't have the message yet
+	transport, err := newFailoverTransport([]string{missing.URL}, NoFailover, 0, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+	client.http.Transport = transport
+	client.readNodes = []string{found.URL, missing.URL}
+
+	options := WaitOptions{Timeout: 50 * time.Millisecond, Interval: 10 * time.Millisecond}
+
+	nodes, err := client.VerifyQuorum("hash", 1, options)
+	if err != nil || len(nodes) != 1 || nodes[0] != found.URL {
+		t.Fatalf("expected %s to confirm the message, got %v, %v", found.URL, nodes, err)
+	}
+
+	if nodes, err := client.VerifyQuorum("hash", 2, options); err == nil || len(nodes) != 1 {
+		t.Fatalf("expected a quorum of 2 to time out with a single confirmation, got %v, %v", nodes, err)
+	}
+
+	if _, err := client.VerifyQuorum("hash", 4, options); err == nil {
+		t.Fatal("expected an error for a quorum larger than the nodes")
+	}
+}
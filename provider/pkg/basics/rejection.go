@@ -0,0 +1,119 @@
+package basics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Rejection error codes of aleph nodes, see aleph-message ErrorCode.
+var rejectionReasons = map[int]string{
+	-1:  "internal error",
+	0:   "invalid format",
+	1:   "invalid signature",
+	2:   "permission denied",
+	3:   "content unavailable",
+	4:   "file unavailable",
+	5:   "insufficient balance",
+	100: "post amend without target",
+	101: "post amend target not found",
+	102: "post amend of an amend",
+	200: "store reference not found",
+	201: "store update of an update",
+	300: "vm reference not found",
+	301: "vm volume not found",
+	302: "vm amend not allowed",
+	303: "vm update of an update",
+	304: "vm volume too small",
+	500: "forget without target",
+	501: "forget target not found",
+	502: "forget of a forget",
+	503: "forget not allowed",
+	504: "forgotten duplicate",
+}
+
+type RejectedMessageResponse struct {
+	Status    MessageStatus   `json:"status"`
+	ErrorCode int             `json:"error_code"`
+	Details   json.RawMessage `json:"details"`
+}
+
+// Reason describes why the message was rejected, e.g. "insufficient balance (5)".
+func (rejection RejectedMessageResponse) Reason() string {
+	reason, ok := rejectionReasons[rejection.ErrorCode]
+	if !ok {
+		reason = "unknown error"
+	}
+
+	reason = fmt.Sprintf("%s (%d)", reason, rejection.ErrorCode)
+	if len(rejection.Details) > 0 && string(rejection.Details) != "null" {
+		reason += ": " + string(rejection.Details)
+	}
+
+	return reason
+}
+
+// GetRejectionReason fetches the reason a rejected message was refused by the node.
+func (client *TwentySixClient) GetRejectionReason(hash string) (string, error) {
+	request, err := http.NewRequest("GET", AlephApiUrl+client.apiPath("/messages/"+hash), nil)
+	if err != nil {
+		return "", err
+	}
+
+	request.Header.Add("Accept", "application/json")
+
+	response, err := client.http.Do(request)
+	if err != nil {
+		return "", err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode == http.StatusNotFound {
+		return "", errors.New("message not found")
+	}
+
+	resultBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var result RejectedMessageResponse
+	if err := json.Unmarshal(resultBody, &result); err != nil {
+		return "", err
+	}
+
+	if result.Status != RejectedMessageStatus {
+		return "", fmt.Errorf("message %s is %s, not rejected", hash, result.Status)
+	}
+
+	return result.Reason(), nil
+}
+
+// readMessageState tells whether a message is gone, forgotten or unknown to every read
+// node, and returns its rejection reason, empty unless it was rejected.
+func readMessageState(client *TwentySixClient, hash string) (bool, string, error) {
+	status, err := client.GetMessageStatus(hash)
+	if err != nil && err.Error() == "message not found" {
+		// the load balancer may not know the message yet, the read nodes tell
+		_, err := client.GetMessageByHash(hash)
+		if err != nil && err.Error() == "message not found" {
+			return true, "", nil
+		}
+		return false, "", err
+	} else if err != nil {
+		return false, "", err
+	}
+
+	switch status.Status {
+	case ForgottenMessageStatus:
+		return true, "", nil
+	case RejectedMessageStatus:
+		reason, err := client.GetRejectionReason(hash)
+		return false, reason, err
+	}
+
+	return false, "", nil
+}
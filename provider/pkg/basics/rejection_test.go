@@ -0,0 +1,60 @@
+package basics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReadMessageState(t *testing.T) {
+	statuses := map[string]string{
+		"processed": `{"status":"processed"}`,
+		"forgotten": `{"status":"forgotten"}`,
+		"rejected":  `{"status":"rejected","error_code":5,"details":null}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/messages.json") {
+			w.Write([]byte(`{"messages":[],"pagination_total":0}`))
+			return
+		}
+
+		hash := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v0/messages/"), "/status")
+		status, ok := statuses[hash]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(status))
+	}))
+	defer server.Close()
+
+	transport, err := newFailoverTransport([]string{server.URL}, NoFailover, 0, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+	client.http.Transport = transport
+	client.readNodes = nil
+
+	cases := []struct {
+		hash   string
+		gone   bool
+		reason string
+	}{
+		{"processed", false, ""},
+		{"forgotten", true, ""},
+		{"unknown", true, ""},
+		{"rejected", false, "insufficient balance (5)"},
+	}
+	for i := 0; i < len(cases); i++ {
+		gone, reason, err := readMessageState(&client, cases[i].hash)
+		if err != nil {
+			t.Fatalf("%s: %s", cases[i].hash, err)
+		}
+		if gone != cases[i].gone || reason != cases[i].reason {
+			t.Fatalf("%s: expected gone %v and reason %q, got %v and %q", cases[i].hash, cases[i].gone, cases[i].reason, gone, reason)
+		}
+	}
+}
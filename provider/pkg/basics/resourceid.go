@@ -0,0 +1,35 @@
+package basics
+
+import (
+	"sync"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+)
+
+// Message hashes used as resource IDs by this provider process, with the name of the
+// resource which claimed them.
+var claimedResourceIds = struct {
+	sync.Mutex
+	names map[string]string
+}{names: map[string]string{}}
+
+// resourceId is the ID returned by Create: the resource name, or the message item hash
+// when useMessageHash is set. A hash already claimed by another resource of the
+// deployment, e.g. a deduplicated volume, falls back to the name to keep IDs unique.
+func resourceId(ctx p.Context, name string, messageHash string, useMessageHash bool) string {
+	if !useMessageHash || messageHash == "" {
+		return name
+	}
+
+	claimedResourceIds.Lock()
+	defer claimedResourceIds.Unlock()
+
+	if owner, claimed := claimedResourceIds.names[messageHash]; claimed && owner != name {
+		ctx.Logf(diag.Warning, "message %s is already the ID of %s, %s keeps its name as ID", messageHash, owner, name)
+		return name
+	}
+
+	claimedResourceIds.names[messageHash] = name
+	return messageHash
+}
@@ -0,0 +1,94 @@
+package basics
+
+import (
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// DefaultRetryAttempts is the number of times a request failing with a server error
+// or a network error is sent before giving up.
+const DefaultRetryAttempts = 5
+
+// Delay before the first retry, doubled on every attempt up to retryMaxDelay.
+var (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 8 * time.Second
+)
+
+// retryTransport sends again the requests failing with a 5xx status or a network
+// error, with an exponential backoff plus jitter. Other statuses, validation errors
+// included, are returned right away, and so are the requests with a body that can't
+// be replayed.
+type retryTransport struct {
+	attempts int
+	base     http.RoundTripper
+}
+
+func newRetryTransport(attempts int, base http.RoundTripper) *retryTransport {
+	if attempts <= 0 {
+		attempts = DefaultRetryAttempts
+	}
+
+	return &retryTransport{attempts: attempts, base: base}
+}
+
+// retryDelay returns the backoff before the retry following the attempt.
+func retryDelay(attempt int) time.Duration {
+	delay := retryMaxDelay
+	if attempt < 16 && retryBaseDelay<<attempt < retryMaxDelay {
+		delay = retryBaseDelay << attempt
+	}
+
+	if delay > 1 {
+		delay += time.Duration(rand.Int63n(int64(delay / 2)))
+	}
+
+	return delay
+}
+
+func (transport *retryTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	attempts := transport.attempts
+	if request.Body != nil && request.Body != http.NoBody && request.GetBody == nil {
+		attempts = 1
+	}
+
+	var response *http.Response
+	var err error
+	for i := 0; i < attempts; i++ {
+		attempt := request
+		if i > 0 {
+			if err := sleepContext(request.Context(), retryDelay(i-1)); err != nil {
+				return nil, err
+			}
+
+			attempt = request.Clone(request.Context())
+			if request.GetBody != nil {
+				body, bodyErr := request.GetBody()
+				if bodyErr != nil {
+					return nil, bodyErr
+				}
+				attempt.Body = body
+			}
+		}
+
+		response, err = transport.base.RoundTrip(attempt)
+		last := i == attempts-1
+		if err != nil {
+			if request.Context().Err() != nil || last {
+				return nil, err
+			}
+			continue
+		}
+
+		if response.StatusCode < 500 || last {
+			return response, nil
+		}
+
+		io.Copy(io.Discard, response.Body)
+		response.Body.Close()
+	}
+
+	return response, err
+}
@@ -0,0 +1,84 @@
+package basics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func withRetryDelay(t *testing.T, delay time.Duration) {
+	base, max := retryBaseDelay, retryMaxDelay
+	retryBaseDelay, retryMaxDelay = delay, delay
+	t.Cleanup(func() { retryBaseDelay, retryMaxDelay = base, max })
+}
+
+func TestRetryTransportServerErrors(t *testing.T) {
+	withRetryDelay(t, time.Millisecond)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"messages":[{"item_hash":"retried"}],"pagination_total":1}`))
+	}))
+	defer server.Close()
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+	client.http.Transport = newRetryTransport(0, http.DefaultTransport)
+
+	message, err := client.getMessageByHash(server.URL, "retried")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.ItemHash != "retried" {
+		t.Fatalf("expected the retried message, got %q", message.ItemHash)
+	}
+	if requests != 3 {
+		t.Fatalf("expected 3 requests, got %d", requests)
+	}
+}
+
+func TestRetryTransportValidationError(t *testing.T) {
+	withRetryDelay(t, time.Millisecond)
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}))
+	defer server.Close()
+
+	client := http.Client{Transport: newRetryTransport(0, http.DefaultTransport)}
+	response, err := client.Post(server.URL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusUnprocessableEntity || requests != 1 {
+		t.Fatalf("expected a single request failing with 422, got %d after %d requests", response.StatusCode, requests)
+	}
+}
+
+func TestRetryTransportNetworkError(t *testing.T) {
+	withRetryDelay(t, time.Millisecond)
+
+	client := http.Client{Transport: newRetryTransport(3, http.DefaultTransport)}
+	if _, err := client.Get("http://127.0.0.1:1"); err == nil {
+		t.Fatal("expected the request to fail once the attempts are exhausted")
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempt := 0; attempt < 40; attempt++ {
+		delay := retryDelay(attempt)
+		if delay < retryBaseDelay || delay > retryMaxDelay*3/2 {
+			t.Fatalf("attempt %d: delay %s out of bounds", attempt, delay)
+		}
+	}
+}
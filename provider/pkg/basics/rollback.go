@@ -0,0 +1,104 @@
+package basics
+
+import (
+	"sync"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+)
+
+// createdVolume is a volume stored by this provider process, which lives as long as
+// the Pulumi operation.
+type createdVolume struct {
+	Names          []string
+	Holders        []string
+	Account        TwentySixAccountState
+	Channel        string
+	ForgetTimeout  int64
+	ForgetInterval int64
+}
+
+var createdVolumes = struct {
+	sync.Mutex
+	byHash map[string]createdVolume
+}{byHash: map[string]createdVolume{}}
+
+// registerCreatedVolume records the volume name storing the message hash, identical
+// volumes sharing the message are recorded together.
+func registerCreatedVolume(hash string, name string, holders []string, args TwentySixVolumeArgs) {
+	createdVolumes.Lock()
+	defer createdVolumes.Unlock()
+
+	if volume, ok := createdVolumes.byHash[hash]; ok {
+		volume.Names = append(volume.Names, name)
+		createdVolumes.byHash[hash] = volume
+		return
+	}
+
+	createdVolumes.byHash[hash] = createdVolume{
+		Names:          []string{name},
+		Holders:        holders,
+		Account:        args.Account,
+		Channel:        args.Channel,
+		ForgetTimeout:  args.ForgetTimeout,
+		ForgetInterval: args.ForgetInterval,
+	}
+}
+
+// takeCreatedVolumes removes and returns the volumes created during this operation
+// which are referenced by the VM content.
+func takeCreatedVolumes(content interface{}) map[string]createdVolume {
+	createdVolumes.Lock()
+	defer createdVolumes.Unlock()
+
+	volumes := map[string]createdVolume{}
+	for hash, volume := range createdVolumes.byHash {
+		if containsRef(content, hash) {
+			volumes[hash] = volume
+			delete(createdVolumes.byHash, hash)
+		}
+	}
+
+	return volumes
+}
+
+// rolledBackVolumeHeld releases the message hash of the rolled back volumes, like their
+// deletion would, and tells whether other volumes sharing it may still hold it.
+func rolledBackVolumeHeld(ctx p.Context, hash string, volume createdVolume) bool {
+	held := false
+	for i := 0; i < len(volume.Names); i++ {
+		held = volumeMessageHeld(ctx, volume.Names[i], hash, otherVolumeHolders(volume.Holders, volume.Names[i]))
+	}
+
+	return held
+}
+
+// rollbackInstance forgets, on a best effort basis, the instance message and the
+// volumes it mounts that were stored earlier in the same operation, unless volumes it
+// doesn't mount share their message. Pulumi keeps those volumes in its state, the next
+// refresh or update finds their message gone and stores them again.
+func rollbackInstance(ctx p.Context, client *TwentySixClient, input TwentySixInstanceArgs, messageHash string) {
+	if messageHash != "" {
+		ctx.Logf(diag.Warning, "rolling back instance message %s", messageHash)
+		if err := forgetAndWait(ctx, client, []string{messageHash}, input.ForgetTimeout, input.ForgetInterval); err != nil {
+			ctx.Logf(diag.Warning, "unable to forget instance message %s: %s", messageHash, err.Error())
+		}
+	}
+
+	content := map[string]interface{}{
+		"rootfs":  map[string]interface{}{"parent": map[string]interface{}{"ref": input.Rootfs.Parent.Ref}},
+		"volumes": input.Volumes,
+	}
+
+	for hash, volume := range takeCreatedVolumes(content) {
+		if rolledBackVolumeHeld(ctx, hash, volume) {
+			continue
+		}
+
+		ctx.Logf(diag.Warning, "rolling back volume %s", hash)
+		volumeClient := NewConfiguredClient(ctx, volume.Account, volume.Channel)
+		if err := forgetAndWait(ctx, &volumeClient, []string{hash}, volume.ForgetTimeout, volume.ForgetInterval); err != nil {
+			ctx.Logf(diag.Warning, "unable to forget volume %s: %s", hash, err.Error())
+		}
+	}
+}
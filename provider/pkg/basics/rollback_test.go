@@ -0,0 +1,55 @@
+package basics
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTakeCreatedVolumes(t *testing.T) {
+	registerCreatedVolume("mounted", "mounted", []string{"mounted"}, TwentySixVolumeArgs{Channel: "TEST"})
+	registerCreatedVolume("unrelated", "unrelated", []string{"unrelated"}, TwentySixVolumeArgs{Channel: "TEST"})
+	defer takeCreatedVolumes(map[string]interface{}{"volumes": []interface{}{map[string]interface{}{"ref": "unrelated"}}})
+
+	content := map[string]interface{}{
+		"volumes": []interface{}{map[string]interface{}{"mount": "/data", "ref": "mounted"}},
+	}
+
+	volumes := takeCreatedVolumes(content)
+	if _, ok := volumes["mounted"]; !ok || len(volumes) != 1 {
+		t.Fatalf("expected only the mounted volume, got %v", volumes)
+	}
+
+	if volumes := takeCreatedVolumes(content); len(volumes) != 0 {
+		t.Fatalf("a volume must only be rolled back once, got %v", volumes)
+	}
+}
+
+func TestRolledBackVolumeHeld(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	registerCreatedVolume("shared", "data", []string{"data", "copy", "backup"}, TwentySixVolumeArgs{Channel: "TEST"})
+	registerCreatedVolume("shared", "copy", []string{"data", "copy", "backup"}, TwentySixVolumeArgs{Channel: "TEST"})
+
+	volumes := takeCreatedVolumes(map[string]interface{}{"volumes": []interface{}{map[string]interface{}{"ref": "shared"}}})
+	volume := volumes["shared"]
+	if !slices.Equal(volume.Names, []string{"data", "copy"}) {
+		t.Fatalf("expected both volumes sharing the message, got %v", volume.Names)
+	}
+
+	if !rolledBackVolumeHeld(newTestContext(), "shared", volume) {
+		t.Fatal("expected the message held by backup to be kept")
+	}
+
+	// once backup is deleted as well, a rollback forgets the message
+	if _, err := releaseVolumeMessage("released", "backup", []string{"data", "copy"}); err != nil {
+		t.Fatal(err)
+	}
+	if rolledBackVolumeHeld(newTestContext(), "released", volume) {
+		t.Fatal("expected the message released by every volume to be forgotten")
+	}
+
+	if rolledBackVolumeHeld(newTestContext(), "unshared", createdVolume{Names: []string{"data"}, Holders: []string{"data"}}) {
+		t.Fatal("expected an unshared message to be forgotten")
+	}
+}
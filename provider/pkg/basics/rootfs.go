@@ -0,0 +1,98 @@
+package basics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// ResolveRootfsParent checks the parent image of an instance rootfs exists and is
+// a STORE message. With useLatest, the latest amend of the image is resolved and
+// checked as well. The validated message is returned.
+func (client *TwentySixClient) ResolveRootfsParent(ref string, useLatest bool) (Message, error) {
+	parent, err := client.GetMessageByHash(ref)
+	if err != nil {
+		return Message{}, fmt.Errorf("rootfs parent image %s: %w", ref, err)
+	}
+
+	if parent.Type != StoreMessageType {
+		return Message{}, fmt.Errorf("rootfs parent image %s is a %s message, expected %s", ref, parent.Type, StoreMessageType)
+	}
+
+	if !useLatest {
+		return parent, nil
+	}
+
+	latest, found, err := client.getLatestStoreAmend(ref, parent.Sender)
+	if err != nil {
+		return Message{}, fmt.Errorf("rootfs parent image %s: %w", ref, err)
+	}
+
+	if !found {
+		return parent, nil
+	}
+
+	if latest.Type != StoreMessageType {
+		return Message{}, fmt.Errorf("latest version %s of rootfs parent image %s is a %s message, expected %s", latest.ItemHash, ref, latest.Type, StoreMessageType)
+	}
+
+	return latest, nil
+}
+
+// ResolveRootfsCandidates resolves the first usable parent image of refs, tried in
+// order, and returns its ref along with the validated message.
+func (client *TwentySixClient) ResolveRootfsCandidates(refs []string, useLatest bool) (string, Message, error) {
+	errs := []error{}
+	for i := 0; i < len(refs); i++ {
+		image, err := client.ResolveRootfsParent(refs[i], useLatest)
+		if err == nil {
+			return refs[i], image, nil
+		}
+		errs = append(errs, err)
+	}
+
+	return "", Message{}, fmt.Errorf("no usable rootfs parent image: %w", errors.Join(errs...))
+}
+
+// getLatestStoreAmend returns the most recent STORE message of the owner referencing ref.
+func (client *TwentySixClient) getLatestStoreAmend(ref string, owner string) (Message, bool, error) {
+	params := url.Values{}
+	params.Add("msgTypes", string(StoreMessageType))
+	params.Add("refs", ref)
+	params.Add("addresses", owner)
+	params.Add("size", "1")
+	params.Add("sort_order", "-1")
+
+	request, err := http.NewRequest("GET", AlephApiUrl+client.apiPath("/messages.json?")+params.Encode(), nil)
+	if err != nil {
+		return Message{}, false, err
+	}
+
+	request.Header.Add("Accept", "application/json")
+
+	response, err := client.http.Do(request)
+	if err != nil {
+		return Message{}, false, err
+	}
+
+	defer response.Body.Close()
+
+	resultBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return Message{}, false, err
+	}
+
+	var result GetMessageResponse
+	if err := json.Unmarshal(resultBody, &result); err != nil {
+		return Message{}, false, err
+	}
+
+	if len(result.Messages) == 0 {
+		return Message{}, false, nil
+	}
+
+	return result.Messages[0], true, nil
+}
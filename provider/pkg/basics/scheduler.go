@@ -0,0 +1,100 @@
+package basics
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+)
+
+const (
+	DefaultSchedulerPollInterval int64 = 10
+	DefaultSchedulerPollJitter   int64 = 5
+
+	allocationTimeout = 30 * time.Minute
+)
+
+// allocationWaitOptions polls the scheduler every poll interval plus a random share
+// of the jitter, so VMs created together don't poll the scheduler in lockstep.
+func (client *TwentySixClient) allocationWaitOptions() WaitOptions {
+	return WaitOptions{
+		Timeout:  allocationTimeout,
+		Interval: time.Duration(client.schedulerPollInterval) * time.Second,
+		Jitter:   time.Duration(client.schedulerPollJitter) * time.Second,
+	}
+}
+
+// WaitAllocation polls the scheduler until the VM of the message is allocated.
+func (client *TwentySixClient) WaitAllocation(hash string, options WaitOptions) (SchedulerAllocation, error) {
+	var allocation SchedulerAllocation
+
+	err := waitUntil(client.ctx, options, func() (bool, error) {
+		var err error
+		allocation, err = client.GetInstanceState(hash)
+		if err == nil {
+			err = checkAllocation(hash, allocation)
+		}
+		if err != nil {
+			log.Println("error on retrieve instance state: ", err.Error())
+			return false, nil
+		}
+
+		return true, nil
+	})
+	if errors.Is(err, errWaitTimeout) {
+		return SchedulerAllocation{}, errors.New("timeout waiting for instance")
+	}
+
+	return allocation, err
+}
+
+// checkAllocation makes sure the scheduler answered with the allocation of the
+// requested VM, and not an empty or stale one for another VM.
+func checkAllocation(hash string, allocation SchedulerAllocation) error {
+	if allocation.VmHash != hash {
+		return fmt.Errorf("scheduler returned the allocation of vm %q for %s", allocation.VmHash, hash)
+	}
+
+	return nil
+}
+
+func waitForSchedule(option *bool) bool {
+	return option == nil || *option
+}
+
+// readAllocation reads the current allocation of a VM, which fills the allocation of
+// a VM created without waiting for its schedule and follows a VM moved to another
+// node. The known allocation is kept while the scheduler can't tell.
+func readAllocation(ctx p.Context, client *TwentySixClient, hash string, allocation SchedulerAllocation) SchedulerAllocation {
+	scheduled, err := client.GetInstanceState(hash)
+	if err == nil {
+		err = checkAllocation(hash, scheduled)
+	}
+	if err != nil {
+		if allocation.VmHash == "" {
+			ctx.Logf(diag.Info, "vm of message %s is not scheduled yet", hash)
+		}
+		return allocation
+	}
+
+	return scheduled
+}
+
+// TwentySixVmAddress tells where an allocated VM can be reached, empty until the
+// scheduler allocates it.
+type TwentySixVmAddress struct {
+	Ipv6    string `pulumi:"ipv6,optional"`
+	NodeUrl string `pulumi:"nodeUrl,optional"`
+	VmHash  string `pulumi:"vmHash,optional"`
+}
+
+func allocationAddress(allocation SchedulerAllocation) TwentySixVmAddress {
+	return TwentySixVmAddress{
+		Ipv6:    vmAddress(allocation),
+		NodeUrl: allocation.Node.Url,
+		VmHash:  allocation.VmHash,
+	}
+}
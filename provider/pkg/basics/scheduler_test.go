@@ -0,0 +1,54 @@
+package basics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAllocationWaitOptionsJitter(t *testing.T) {
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+	client.schedulerPollInterval = 10
+	client.schedulerPollJitter = 5
+
+	options := client.allocationWaitOptions()
+	for i := 0; i < 100; i++ {
+		delay := options.delay(options.Interval)
+		if delay < 10*time.Second || delay >= 15*time.Second {
+			t.Fatalf("delay %s out of [10s, 15s)", delay)
+		}
+	}
+
+	client.schedulerPollJitter = 0
+	options = client.allocationWaitOptions()
+	if delay := options.delay(options.Interval); delay != 10*time.Second {
+		t.Fatalf("expected 10s without jitter, got %s", delay)
+	}
+}
+
+func TestCheckAllocation(t *testing.T) {
+	if err := checkAllocation("vm", SchedulerAllocation{VmHash: "vm"}); err != nil {
+		t.Fatalf("expected the allocation to match, got %v", err)
+	}
+
+	if err := checkAllocation("vm", SchedulerAllocation{VmHash: "other"}); err == nil {
+		t.Fatal("expected an error for the allocation of another vm")
+	}
+
+	if err := checkAllocation("vm", SchedulerAllocation{}); err == nil {
+		t.Fatal("expected an error for an empty allocation")
+	}
+}
+
+func TestAllocationAddress(t *testing.T) {
+	allocation := SchedulerAllocation{VmHash: "vm", VmIPV6: "2a01:4f8:1:2::1/124"}
+	allocation.Node.Url = "https://crn.example.org"
+
+	address := allocationAddress(allocation)
+	if address.Ipv6 != "2a01:4f8:1:2::1" || address.NodeUrl != "https://crn.example.org" || address.VmHash != "vm" {
+		t.Fatalf("unexpected address %+v", address)
+	}
+
+	if address := allocationAddress(SchedulerAllocation{}); address != (TwentySixVmAddress{}) {
+		t.Fatalf("expected an empty address before the allocation, got %+v", address)
+	}
+}
@@ -0,0 +1,91 @@
+package basics
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mr-tron/base58"
+)
+
+// solanaSignature is the signature of a SOL message, a JSON document holding the
+// base58 signature and the base58 public key, which is also the sender address.
+type solanaSignature struct {
+	Signature string `json:"signature"`
+	PublicKey string `json:"publicKey"`
+}
+
+// solanaKey decodes a base58 Solana private key, either a 32 bytes seed or a 64 bytes
+// keypair as exported by the Solana CLI and wallets.
+func solanaKey(pkey string) (ed25519.PrivateKey, error) {
+	keyBytes, err := base58.Decode(pkey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid solana private key: %w", err)
+	}
+
+	switch len(keyBytes) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(keyBytes), nil
+	case ed25519.PrivateKeySize:
+		key := ed25519.NewKeyFromSeed(keyBytes[:ed25519.SeedSize])
+		if !bytes.Equal(key.Public().(ed25519.PublicKey), keyBytes[ed25519.SeedSize:]) {
+			return nil, errors.New("invalid solana private key: public key does not match the seed")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("invalid solana private key length %d", len(keyBytes))
+	}
+}
+
+// solanaAddress returns the address of a Solana key, its base58 public key.
+func solanaAddress(key ed25519.PrivateKey) string {
+	return base58.Encode(key.Public().(ed25519.PublicKey))
+}
+
+// signSolanaPayload produces the signature of the payload aleph expects from a SOL sender.
+func signSolanaPayload(pkey string, payload []byte) (string, error) {
+	key, err := solanaKey(pkey)
+	if err != nil {
+		return "", err
+	}
+
+	signature, err := json.Marshal(solanaSignature{
+		Signature: base58.Encode(ed25519.Sign(key, payload)),
+		PublicKey: solanaAddress(key),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(signature), nil
+}
+
+// verifySolanaSignature checks the signature of a SOL message was made by its sender.
+func (msg Message) verifySolanaSignature() error {
+	signature := solanaSignature{}
+	if err := json.Unmarshal([]byte(msg.Signature), &signature); err != nil {
+		return fmt.Errorf("%w: %s", ErrSignatureMismatch, err.Error())
+	}
+
+	if signature.PublicKey != msg.Sender {
+		return fmt.Errorf("%w: signed by %s, expected %s", ErrSignatureMismatch, signature.PublicKey, msg.Sender)
+	}
+
+	publicKey, err := base58.Decode(signature.PublicKey)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return fmt.Errorf("%w: invalid public key %s", ErrSignatureMismatch, signature.PublicKey)
+	}
+
+	signatureBytes, err := base58.Decode(signature.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrSignatureMismatch, err.Error())
+	}
+
+	if !ed25519.Verify(publicKey, msg.getVerificationPayload(), signatureBytes) {
+		return fmt.Errorf("%w: invalid signature", ErrSignatureMismatch)
+	}
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package basics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/diskfs/go-diskfs/filesystem/squashfs"
+)
+
+var squashfsModePattern = regexp.MustCompile(`^0?[0-7]{3}$`)
+
+const (
+	minSquashfsBlockSize int64 = 4 * 1024
+	maxSquashfsBlockSize int64 = 1024 * 1024
+)
+
+// squashfsOptions tune how a folder is packed into a squashfs image.
+type squashfsOptions struct {
+	// Larger blocks compress big sequential files better, while smaller blocks waste
+	// less space and are faster to read for folders made of many tiny files.
+	// Zero keeps the default of 128 KiB.
+	BlockSize int64
+
+	// Octal modes forced on the root directory, every directory and every file.
+	// Empty modes preserve the ones of the source folder.
+	RootMode string
+	DirMode  string
+	FileMode string
+
+	// Inodes and fragments are compressed and small files packed into shared fragment
+	// blocks by default, which keeps images of many tiny files small.
+	NoCompressInodes    bool
+	NoCompressFragments bool
+	NoFragments         bool
+}
+
+func validateSquashfsBlockSize(size int64) error {
+	if size == 0 {
+		return nil
+	}
+
+	if size < minSquashfsBlockSize || size > maxSquashfsBlockSize || size&(size-1) != 0 {
+		return fmt.Errorf("invalid squashfs block size %d: expected a power of two between %d and %d", size, minSquashfsBlockSize, maxSquashfsBlockSize)
+	}
+
+	return nil
+}
+
+func validateSquashfsMode(name string, mode string) error {
+	if mode == "" {
+		return nil
+	}
+
+	if !squashfsModePattern.MatchString(mode) {
+		return fmt.Errorf("invalid %s %q: expected an octal mode such as 0755", name, mode)
+	}
+
+	return nil
+}
+
+func (options squashfsOptions) validate() error {
+	if err := validateSquashfsBlockSize(options.BlockSize); err != nil {
+		return err
+	}
+
+	if err := validateSquashfsMode("rootMode", options.RootMode); err != nil {
+		return err
+	}
+
+	if err := validateSquashfsMode("dirMode", options.DirMode); err != nil {
+		return err
+	}
+
+	if err := validateSquashfsMode("fileMode", options.FileMode); err != nil {
+		return err
+	}
+
+	if options.NoFragments && options.NoCompressFragments {
+		return errors.New("noCompressFragments has no effect when noFragments is set")
+	}
+
+	return nil
+}
+
+// cacheKey encodes the options handed to go-diskfs and the modes forced on the folder,
+// which key the build cache along with the content of the folder.
+func (options squashfsOptions) cacheKey() string {
+	// the options are plain values, they always marshal
+	key, _ := json.Marshal(struct {
+		BlockSize int64
+		RootMode  string
+		DirMode   string
+		FileMode  string
+		Finalize  squashfs.FinalizeOptions
+	}{options.BlockSize, options.RootMode, options.DirMode, options.FileMode, options.finalizeOptions()})
+
+	return string(key)
+}
+
+func (options squashfsOptions) finalizeOptions() squashfs.FinalizeOptions {
+	return squashfs.FinalizeOptions{
+		NoCompressInodes:    options.NoCompressInodes,
+		NoCompressFragments: options.NoCompressFragments,
+		NoFragments:         options.NoFragments,
+	}
+}
+
+// applyModes forces the modes of the options on the copy of the folder about to be packed.
+func (options squashfsOptions) applyModes(root string) error {
+	modes := map[string]string{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case path == root:
+			modes[path] = options.RootMode
+		case info.IsDir():
+			modes[path] = options.DirMode
+		case info.Mode().IsRegular():
+			modes[path] = options.FileMode
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// directories are walked before their content, change their mode once the walk is over
+	for path, mode := range modes {
+		if mode == "" {
+			continue
+		}
+
+		perm, err := strconv.ParseUint(mode, 8, 32)
+		if err != nil {
+			return err
+		}
+
+		if err := os.Chmod(path, os.FileMode(perm)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// buildSquashfs packs a copy of the folder into a squashfs image, in process so that no
+// mksquashfs binary is needed.
+func buildSquashfs(folder string, target string, options squashfsOptions) error {
+	file, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	image, err := squashfs.Create(file, 0, 0, options.BlockSize)
+	if err != nil {
+		return err
+	}
+
+	// go-diskfs packs its workspace on Finalize
+	workspace := image.Workspace()
+	defer removeSnapshot(workspace)
+
+	if err := snapshotFolder(folder, workspace); err != nil {
+		return err
+	}
+
+	if err := options.applyModes(workspace); err != nil {
+		return err
+	}
+
+	if err := image.Finalize(options.finalizeOptions()); err != nil {
+		return fmt.Errorf("building squashfs image: %w", err)
+	}
+
+	return file.Close()
+}
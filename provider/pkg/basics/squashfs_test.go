@@ -0,0 +1,56 @@
+package basics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildSquashfs(t *testing.T) {
+	folder := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(folder, "app", "static", "css"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(folder, "app", "main.py"), []byte("print('hello')\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(folder, "app", "static", "css", "site.css"), []byte("body {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	target := filepath.Join(t.TempDir(), "volume.squashfs")
+	if err := buildSquashfs(folder, target, squashfsOptions{FileMode: "0644"}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() == 0 {
+		t.Fatal("expected a non empty image")
+	}
+
+	entries, err := listSquashfsImage(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	modes := map[string]string{}
+	for i := 0; i < len(entries); i++ {
+		modes[entries[i].Path] = entries[i].Mode
+	}
+
+	expected := []string{"app", "app/main.py", "app/static", "app/static/css", "app/static/css/site.css"}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, entries)
+	}
+	for i := 0; i < len(expected); i++ {
+		if _, ok := modes[expected[i]]; !ok {
+			t.Fatalf("expected %s in the image, got %v", expected[i], entries)
+		}
+	}
+	if modes["app/main.py"] != "-rw-r--r--" {
+		t.Fatalf("expected the forced file mode, got %s", modes["app/main.py"])
+	}
+}
@@ -0,0 +1,178 @@
+package basics
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// A build failing on a file briefly locked, or changed by a process writing into the
+// folder, is retried from scratch a few times before giving up.
+const squashfsBuildAttempts = 3
+
+var squashfsBuildRetryDelay = 2 * time.Second
+
+// squashfsBuildError is a failed build, with the file it failed on when known.
+type squashfsBuildError struct {
+	File      string
+	Transient bool
+	Err       error
+}
+
+func (err *squashfsBuildError) Error() string {
+	if err.File == "" {
+		return err.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", err.File, err.Err)
+}
+
+func (err *squashfsBuildError) Unwrap() error {
+	return err.Err
+}
+
+func isTransientFileError(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) ||
+		errors.Is(err, syscall.EAGAIN) ||
+		errors.Is(err, syscall.EBUSY) ||
+		errors.Is(err, syscall.ETXTBSY)
+}
+
+// buildSquashfsRetrying builds the image and retries the whole build on transient errors.
+func buildSquashfsRetrying(folder string, target string, options squashfsOptions) error {
+	var err error
+	for attempt := 1; attempt <= squashfsBuildAttempts; attempt++ {
+		err = buildSquashfs(folder, target, options)
+		if err == nil {
+			return nil
+		}
+
+		var buildError *squashfsBuildError
+		if !errors.As(err, &buildError) || !buildError.Transient {
+			return err
+		}
+
+		if attempt < squashfsBuildAttempts {
+			time.Sleep(squashfsBuildRetryDelay)
+		}
+	}
+
+	var buildError *squashfsBuildError
+	if errors.As(err, &buildError) && buildError.File != "" {
+		return fmt.Errorf("building the volume image failed %d times, %s kept failing: %w", squashfsBuildAttempts, buildError.File, err)
+	}
+
+	return fmt.Errorf("building the volume image failed %d times: %w", squashfsBuildAttempts, err)
+}
+
+// snapshotFolder copies the folder into target, an existing empty directory, keeping
+// the modes and modification times packed into the image.
+func snapshotFolder(folder string, target string) error {
+	type dirAttributes struct {
+		path    string
+		mode    os.FileMode
+		modTime time.Time
+	}
+	dirs := []dirAttributes{}
+
+	err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return &squashfsBuildError{File: path, Transient: isTransientFileError(err), Err: err}
+		}
+
+		relativePath, err := filepath.Rel(folder, path)
+		if err != nil {
+			return err
+		}
+		targetPath := filepath.Join(target, relativePath)
+
+		switch {
+		case info.IsDir():
+			// a read-only directory would refuse its content, its mode is set once it is copied
+			if err := os.MkdirAll(targetPath, 0o700); err != nil {
+				return err
+			}
+			dirs = append(dirs, dirAttributes{targetPath, info.Mode().Perm(), info.ModTime()})
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return &squashfsBuildError{File: path, Transient: isTransientFileError(err), Err: err}
+			}
+			if err := os.Symlink(link, targetPath); err != nil {
+				return err
+			}
+		case info.Mode().IsRegular():
+			if err := snapshotFile(path, targetPath, info); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// children update the modification time of their directory, set the directory
+	// attributes last and deepest first
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chmod(dirs[i].path, dirs[i].mode); err != nil {
+			return err
+		}
+		if err := os.Chtimes(dirs[i].path, dirs[i].modTime, dirs[i].modTime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeSnapshot removes a folder copied by snapshotFolder, making its read-only
+// directories writable first.
+func removeSnapshot(path string) error {
+	err := filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Mode().Perm()&0o700 != 0o700 {
+			return os.Chmod(path, info.Mode().Perm()|0o700)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return os.RemoveAll(path)
+}
+
+func snapshotFile(path string, targetPath string, info os.FileInfo) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return &squashfsBuildError{File: path, Transient: isTransientFileError(err), Err: err}
+	}
+	defer source.Close()
+
+	target, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	copied, err := io.Copy(target, source)
+	if closeErr := target.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return &squashfsBuildError{File: path, Transient: isTransientFileError(err), Err: err}
+	}
+
+	if copied != info.Size() {
+		return &squashfsBuildError{File: path, Transient: true, Err: errors.New("file changed while copying")}
+	}
+
+	return os.Chtimes(targetPath, info.ModTime(), info.ModTime())
+}
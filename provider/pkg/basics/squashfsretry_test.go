@@ -0,0 +1,99 @@
+package basics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotFolder(t *testing.T) {
+	folder := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(folder, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(folder, "bin", "run"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("bin/run", filepath.Join(folder, "run")); err != nil {
+		t.Fatal(err)
+	}
+
+	target := t.TempDir()
+	if err := snapshotFolder(folder, target); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(filepath.Join(target, "bin", "run"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o755 {
+		t.Fatalf("expected the file mode to be kept, got %v", info.Mode())
+	}
+
+	link, err := os.Readlink(filepath.Join(target, "run"))
+	if err != nil || link != "bin/run" {
+		t.Fatalf("expected the symlink to be kept, got %q %v", link, err)
+	}
+}
+
+func TestSnapshotReadOnlyFolder(t *testing.T) {
+	folder := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(folder, "etc", "conf.d"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(folder, "etc", "conf.d", "app.conf"), []byte("port=80\n"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{filepath.Join(folder, "etc", "conf.d"), filepath.Join(folder, "etc")} {
+		if err := os.Chmod(dir, 0o555); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { removeSnapshot(folder) })
+
+	target := filepath.Join(t.TempDir(), "snapshot")
+	if err := os.Mkdir(target, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := snapshotFolder(folder, target); err != nil {
+		t.Fatalf("expected read-only directories to be copied, got %s", err)
+	}
+
+	for _, dir := range []string{filepath.Join(target, "etc"), filepath.Join(target, "etc", "conf.d")} {
+		info, err := os.Stat(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0o555 {
+			t.Fatalf("expected %s to keep its mode, got %v", dir, info.Mode())
+		}
+	}
+
+	if content, err := os.ReadFile(filepath.Join(target, "etc", "conf.d", "app.conf")); err != nil || string(content) != "port=80\n" {
+		t.Fatalf("expected the file to be copied, got %q %v", content, err)
+	}
+
+	if err := removeSnapshot(target); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatalf("expected the snapshot to be removed, got %v", err)
+	}
+}
+
+func TestBuildSquashfsRetryingReportsFile(t *testing.T) {
+	delay := squashfsBuildRetryDelay
+	squashfsBuildRetryDelay = 0
+	defer func() { squashfsBuildRetryDelay = delay }()
+
+	folder := filepath.Join(t.TempDir(), "missing")
+	err := buildSquashfsRetrying(folder, filepath.Join(t.TempDir(), "image.squashfs"), squashfsOptions{})
+	if err == nil {
+		t.Fatal("expected the build of a missing folder to fail")
+	}
+	if !strings.Contains(err.Error(), "failed 3 times, "+folder+" kept failing") {
+		t.Fatalf("expected the failing file in the error, got %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package basics
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"golang.org/x/crypto/ssh"
+)
+
+// SSH public key types accepted in the authorized keys of a VM.
+var supportedSSHKeyTypes = []string{
+	ssh.KeyAlgoRSA,
+	ssh.KeyAlgoED25519,
+	ssh.KeyAlgoECDSA256,
+	ssh.KeyAlgoECDSA384,
+	ssh.KeyAlgoECDSA521,
+	ssh.KeyAlgoSKED25519,
+	ssh.KeyAlgoSKECDSA256,
+}
+
+func validateAuthorizedKey(key string) error {
+	if strings.Contains(key, "PRIVATE KEY") {
+		return errors.New("private keys must never be used as authorized keys")
+	}
+
+	publicKey, _, _, rest, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return err
+	}
+
+	if len(strings.TrimSpace(string(rest))) > 0 {
+		return errors.New("expected a single key per entry")
+	}
+
+	if !slices.Contains(supportedSSHKeyTypes, publicKey.Type()) {
+		return fmt.Errorf("unsupported key type %s", publicKey.Type())
+	}
+
+	return nil
+}
+
+// checkAuthorizedKeys reports every invalid authorized key and its index.
+func checkAuthorizedKeys(keys []string) []p.CheckFailure {
+	failures := []p.CheckFailure{}
+
+	for i := 0; i < len(keys); i++ {
+		if err := validateAuthorizedKey(keys[i]); err != nil {
+			failures = append(failures, p.CheckFailure{
+				Property: "authorizedKeys",
+				Reason:   fmt.Sprintf("invalid authorized key at index %d: %s", i, err),
+			})
+		}
+	}
+
+	return failures
+}
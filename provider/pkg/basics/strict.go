@@ -0,0 +1,31 @@
+package basics
+
+import (
+	"slices"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi-go-provider/infer"
+)
+
+// strictReplaceEnabled resolves the strictReplace option of a resource, falling back
+// to the provider configuration when the resource doesn't set it.
+func strictReplaceEnabled(ctx p.Context, override *bool) bool {
+	if override != nil {
+		return *override
+	}
+
+	return infer.GetConfig[TwentySixConfig](ctx).StrictReplace
+}
+
+// strictDiffResponse turns every change of the message into a delete-before-replace.
+// Provider options don't touch the message and are still updated in place.
+func strictDiffResponse(diff map[string]p.PropertyDiff) p.DiffResponse {
+	for property, change := range diff {
+		if !slices.Contains(providerOptionProperties, property) {
+			change.Kind = p.UpdateReplace
+			diff[property] = change
+		}
+	}
+
+	return diffResponse(diff)
+}
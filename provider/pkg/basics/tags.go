@@ -0,0 +1,82 @@
+package basics
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	p "github.com/pulumi/pulumi-go-provider"
+)
+
+// Metadata keys managed by the provider or by Aleph itself, which tags can't override.
+var reservedMetadataKeys = []string{"name", ProviderMetadataKey, ProviderVersionMetadataKey}
+
+func checkTags(tags map[string]string) []p.CheckFailure {
+	failures := []p.CheckFailure{}
+
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if slices.Contains(reservedMetadataKeys, key) {
+			failures = append(failures, p.CheckFailure{
+				Property: "tags",
+				Reason:   fmt.Sprintf("tag %q collides with a reserved metadata key", key),
+			})
+		}
+	}
+
+	return failures
+}
+
+// mergeTags returns the message metadata with the resource tags merged in.
+func mergeTags(metadata map[string]string, tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return metadata
+	}
+
+	merged := map[string]string{}
+	for key, value := range tags {
+		merged[key] = value
+	}
+	for key, value := range metadata {
+		merged[key] = value
+	}
+
+	return merged
+}
+
+// Metadata keys stamped on messages when stampProviderMetadata is enabled.
+const (
+	ProviderMetadataKey        = "pulumi_provider"
+	ProviderVersionMetadataKey = "pulumi_provider_version"
+)
+
+// ProviderVersion is set by the provider package to its build version.
+var ProviderVersion string
+
+// providerMetadata is the metadata identifying this provider build. The language of the
+// Pulumi program isn't known to providers, so only the provider itself is recorded.
+func providerMetadata() map[string]string {
+	version := ProviderVersion
+	if version == "" {
+		version = "unknown"
+	}
+
+	return map[string]string{
+		ProviderMetadataKey:        "twentysix",
+		ProviderVersionMetadataKey: version,
+	}
+}
+
+// stampMetadata adds the provider metadata to the message metadata when enabled.
+func (client *TwentySixClient) stampMetadata(metadata map[string]string) map[string]string {
+	if !client.stampProviderMetadata {
+		return metadata
+	}
+
+	return mergeTags(metadata, providerMetadata())
+}
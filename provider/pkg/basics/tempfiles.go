@@ -0,0 +1,47 @@
+package basics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Volume images are built in the temporary directory under this name pattern.
+const (
+	squashfsTempDir    = "/tmp"
+	squashfsTempPrefix = "pulumi-squashfs-"
+	squashfsTempSuffix = ".squashfs"
+)
+
+// DefaultTempFilesMaxAge is the age after which a leftover volume image is considered orphaned.
+const DefaultTempFilesMaxAge int64 = 24 * 60 * 60
+
+func squashfsTempPath() string {
+	return filepath.Join(squashfsTempDir, squashfsTempPrefix+fmt.Sprint(time.Now().UnixNano())+squashfsTempSuffix)
+}
+
+// cleanupSquashfsTempFiles removes the volume images older than maxAge seconds left
+// behind by crashed runs. It returns the removed paths.
+func cleanupSquashfsTempFiles(maxAge int64) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(squashfsTempDir, squashfsTempPrefix+"*"+squashfsTempSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	removed := []string{}
+	threshold := time.Now().Add(-time.Duration(maxAge) * time.Second)
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() || info.ModTime().After(threshold) {
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, path)
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,37 @@
+package basics
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestCleanupSquashfsTempFiles(t *testing.T) {
+	stale := squashfsTempPath()
+	if err := os.WriteFile(stale, []byte("stale"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(stale)
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	fresh := squashfsTempPath()
+	if err := os.WriteFile(fresh, []byte("fresh"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fresh)
+
+	if _, err := cleanupSquashfsTempFiles(60 * 60); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Fatal("expected the stale image to be removed")
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Fatal("expected the fresh image to be kept")
+	}
+}
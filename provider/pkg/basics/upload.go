@@ -0,0 +1,181 @@
+package basics
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"time"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+)
+
+// Uploads report their progress every 5% of the body, and at most every 10 MiB.
+const (
+	uploadProgressSteps   = 20
+	uploadProgressMinStep = 10 * 1024 * 1024
+)
+
+// UploadStats describes the last file upload performed by a client.
+type UploadStats struct {
+	Bytes    int64
+	Duration time.Duration
+}
+
+// Throughput returns the measured upload speed in bytes per second.
+func (stats UploadStats) Throughput() float64 {
+	if stats.Duration <= 0 {
+		return 0
+	}
+
+	return float64(stats.Bytes) / stats.Duration.Seconds()
+}
+
+// countingReader counts the bytes read through it without altering them, and calls
+// progress every step bytes when set.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+
+	progress func(count int64)
+	step     int64
+	next     int64
+}
+
+func (counter *countingReader) Read(buffer []byte) (int, error) {
+	n, err := counter.reader.Read(buffer)
+	counter.count += int64(n)
+
+	if counter.progress != nil && counter.step > 0 && counter.count >= counter.next {
+		counter.progress(counter.count)
+		for counter.next <= counter.count {
+			counter.next += counter.step
+		}
+	}
+
+	return n, err
+}
+
+// uploadProgressStep returns the bytes sent between two progress reports of an upload.
+func uploadProgressStep(size int64) int64 {
+	return max(size/uploadProgressSteps, uploadProgressMinStep)
+}
+
+// logUploadProgress reports the progress of an upload made within a resource operation.
+func (client *TwentySixClient) logUploadProgress(sent int64, size int64) {
+	ctx, ok := client.ctx.(p.Context)
+	if !ok || size <= 0 {
+		return
+	}
+
+	ctx.LogStatusf(diag.Info, "uploaded %d of %d MiB (%d%%)", sent>>20, size>>20, sent*100/size)
+}
+
+// multipartUpload is a multipart body holding a file, read from disk while it is sent so
+// that uploading a large image doesn't hold it in memory.
+type multipartUpload struct {
+	body        io.Reader
+	size        int64
+	contentType string
+	file        *os.File
+}
+
+func (upload *multipartUpload) Close() error {
+	return upload.file.Close()
+}
+
+// newMultipartUpload frames the file, after a metadata field when it is set. Only the
+// part headers and boundaries are built in memory.
+func (client *TwentySixClient) newMultipartUpload(filePath string, metadata []byte) (*multipartUpload, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	framing := &bytes.Buffer{}
+	writer := multipart.NewWriter(framing)
+
+	if metadata != nil {
+		metadataPart, err := writer.CreateFormField("metadata")
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		if _, err := metadataPart.Write(metadata); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+
+	if _, err := client.createFilePart(writer, filePath); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	// the file content goes between the part header and the closing boundary
+	header := framing.Len()
+	if err := writer.Close(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	framed := framing.Bytes()
+
+	return &multipartUpload{
+		body:        io.MultiReader(bytes.NewReader(framed[:header]), file, bytes.NewReader(framed[header:])),
+		size:        int64(len(framed)) + info.Size(),
+		contentType: writer.FormDataContentType(),
+		file:        file,
+	}, nil
+}
+
+func (client *TwentySixClient) upload(endpoint string, contentType string, body io.Reader, size int64) (*http.Response, error) {
+	counter := &countingReader{reader: body}
+	if size > 0 {
+		counter.step = uploadProgressStep(size)
+		counter.next = counter.step
+		counter.progress = func(sent int64) { client.logUploadProgress(sent, size) }
+	}
+
+	request, err := http.NewRequest("POST", endpoint, counter)
+	if err != nil {
+		return nil, err
+	}
+
+	request.ContentLength = size
+	request.Header.Add("Content-Type", contentType)
+	request.Header.Add("Accept", "application/json")
+
+	// the whole image is sent within the request, only the operation context bounds it
+	uploadClient := client.http
+	uploadClient.Timeout = 0
+
+	startAt := time.Now()
+	response, err := uploadClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	client.lastUpload = UploadStats{
+		Bytes:    counter.count,
+		Duration: time.Since(startAt),
+	}
+
+	log.Printf("uploaded %d bytes in %s (%.2f KiB/s)", client.lastUpload.Bytes, client.lastUpload.Duration.Round(time.Millisecond), client.lastUpload.Throughput()/1024)
+
+	return response, nil
+}
+
+// LastUploadStats returns the measurements of the last upload made by the client.
+func (client *TwentySixClient) LastUploadStats() UploadStats {
+	return client.lastUpload
+}
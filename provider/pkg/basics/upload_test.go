@@ -0,0 +1,117 @@
+package basics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestUploadStreamsLargeFile(t *testing.T) {
+	const fileSize = 64 * 1024 * 1024
+
+	filePath := filepath.Join(t.TempDir(), "rootfs.squashfs")
+	file, err := os.Create(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := file.Truncate(fileSize); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	var metadata string
+	var received int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := r.MultipartReader()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			if part.FormName() == "metadata" {
+				content, _ := io.ReadAll(part)
+				metadata = string(content)
+			} else {
+				received, _ = io.Copy(io.Discard, part)
+			}
+		}
+
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer server.Close()
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+	client.detectContentType = false
+
+	upload, err := client.newMultipartUpload(filePath, []byte(`{"message":{}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer upload.Close()
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	response, err := client.upload(server.URL, upload.contentType, upload.body, upload.size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+
+	runtime.ReadMemStats(&after)
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("expected the upload to succeed, got %d", response.StatusCode)
+	}
+	if received != fileSize || metadata != `{"message":{}}` {
+		t.Fatalf("expected the metadata and %d bytes of file, got %q and %d bytes", fileSize, metadata, received)
+	}
+	if client.LastUploadStats().Bytes != upload.size {
+		t.Fatalf("expected %d bytes sent, got %d", upload.size, client.LastUploadStats().Bytes)
+	}
+
+	// buffering the body would allocate at least the size of the file
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > fileSize/4 {
+		t.Fatalf("expected the upload to stream the file, %d bytes were allocated", allocated)
+	}
+}
+
+func TestCountingReaderProgress(t *testing.T) {
+	reports := []int64{}
+	counter := &countingReader{
+		reader:   strings.NewReader(strings.Repeat("x", 100)),
+		step:     30,
+		next:     30,
+		progress: func(count int64) { reports = append(reports, count) },
+	}
+
+	buffer := make([]byte, 20)
+	for {
+		if _, err := counter.Read(buffer); err == io.EOF {
+			break
+		}
+	}
+
+	if len(reports) != 3 || reports[0] != 40 || reports[2] != 100 {
+		t.Fatalf("expected a report every 30 bytes, got %v", reports)
+	}
+	if uploadProgressStep(1<<30) != (1<<30)/uploadProgressSteps || uploadProgressStep(1<<20) != uploadProgressMinStep {
+		t.Fatal("expected reports every 5% of large uploads and every 10 MiB of small ones")
+	}
+}
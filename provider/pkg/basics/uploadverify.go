@@ -0,0 +1,94 @@
+package basics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// squashfsMagic starts every squashfs image, "hsqs" being 0x73717368 little endian.
+var squashfsMagic = []byte("hsqs")
+
+// ipfsSizeOverhead bounds the size the UnixFS DAG adds to an image stored with ipfs.
+const ipfsSizeOverhead = 0.05
+
+// storedSizeMismatch describes a stored size which differs from the local image size
+// more than the engine explains, or returns an empty string.
+func storedSizeMismatch(itemType MessageItemType, localSize int64, storedSize int64) string {
+	if storedSize == 0 {
+		return ""
+	}
+
+	if itemType == IpfsMessageItem {
+		if storedSize < localSize || float64(storedSize) > float64(localSize)*(1+ipfsSizeOverhead)+4096 {
+			return fmt.Sprintf("ipfs stored %d bytes for an image of %d bytes", storedSize, localSize)
+		}
+		return ""
+	}
+
+	if storedSize != localSize {
+		return fmt.Sprintf("storage stored %d bytes for an image of %d bytes", storedSize, localSize)
+	}
+
+	return ""
+}
+
+// VerifyStoredImage downloads the head of stored content and checks it starts with
+// the squashfs magic, to catch an upload the node truncated or corrupted.
+func (client *TwentySixClient) VerifyStoredImage(itemType MessageItemType, hash string) error {
+	var urls []string
+	if itemType == IpfsMessageItem {
+		for _, gateway := range client.ipfsGateways {
+			urls = append(urls, gatewayUrl(gateway, hash))
+		}
+	} else {
+		urls = []string{AlephApiUrl + client.apiPath("/storage/raw/") + hash}
+	}
+
+	var lastErr error = errors.New("no ipfs gateway configured")
+	for _, url := range urls {
+		head, err := client.readHead(url, len(squashfsMagic))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		if !bytes.Equal(head, squashfsMagic) {
+			return fmt.Errorf("stored content %s is not a squashfs image, it starts with %q", hash, head)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unable to read back stored content %s: %w", hash, lastErr)
+}
+
+func (client *TwentySixClient) readHead(url string, size int) ([]byte, error) {
+	request, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Add("Range", fmt.Sprintf("bytes=0-%d", size-1))
+
+	response, err := client.http.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("%s returned status %d", url, response.StatusCode)
+	}
+
+	head := make([]byte, size)
+	read, err := io.ReadFull(response.Body, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+
+	return head[:read], nil
+}
@@ -0,0 +1,50 @@
+package basics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStoredSizeMismatch(t *testing.T) {
+	cases := []struct {
+		itemType   MessageItemType
+		localSize  int64
+		storedSize int64
+		mismatch   bool
+	}{
+		{StorageMessageItem, 4096, 4096, false},
+		{StorageMessageItem, 4096, 2048, true},
+		{IpfsMessageItem, 1 << 20, 1<<20 + 1024, false},
+		{IpfsMessageItem, 1 << 20, 1 << 19, true},
+		{IpfsMessageItem, 1 << 20, 2 << 20, true},
+		{IpfsMessageItem, 1 << 20, 0, false},
+	}
+
+	for _, c := range cases {
+		if mismatch := storedSizeMismatch(c.itemType, c.localSize, c.storedSize); (mismatch != "") != c.mismatch {
+			t.Errorf("%s %d/%d: expected mismatch %v, got %q", c.itemType, c.localSize, c.storedSize, c.mismatch, mismatch)
+		}
+	}
+}
+
+func TestVerifyStoredImage(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/image" {
+			w.Write([]byte("hsqs rest of the image"))
+			return
+		}
+		w.Write([]byte("<html>"))
+	}))
+	defer server.Close()
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+	client.ipfsGateways = []string{server.URL}
+
+	if err := client.VerifyStoredImage(IpfsMessageItem, "image"); err != nil {
+		t.Fatalf("expected a valid image, got %v", err)
+	}
+	if err := client.VerifyStoredImage(IpfsMessageItem, "truncated"); err == nil {
+		t.Fatal("expected an error for content without the squashfs magic")
+	}
+}
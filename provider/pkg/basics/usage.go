@@ -0,0 +1,150 @@
+package basics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	p "github.com/pulumi/pulumi-go-provider"
+)
+
+const usageCacheTTL = time.Minute
+
+type AddressBalanceResponse struct {
+	Address      string  `json:"address"`
+	Balance      float64 `json:"balance"`
+	LockedAmount float64 `json:"locked_amount"`
+}
+
+type TwentySixAccountUsage struct {
+	Address        string         `pulumi:"address"`
+	TotalMessages  int            `pulumi:"totalMessages"`
+	MessagesByType map[string]int `pulumi:"messagesByType"`
+	StoredFiles    int            `pulumi:"storedFiles"`
+	StoredBytes    int64          `pulumi:"storedBytes"`
+	// ALEPH held by the account messages, and the whole balance.
+	HeldAmount float64 `pulumi:"heldAmount"`
+	Balance    float64 `pulumi:"balance"`
+}
+
+var accountUsageCache = struct {
+	sync.Mutex
+	entries map[string]cachedAccountUsage
+}{entries: map[string]cachedAccountUsage{}}
+
+type cachedAccountUsage struct {
+	Usage     TwentySixAccountUsage
+	ExpiresAt time.Time
+}
+
+// GetBalance fetches the balance of an address and the amount held by its messages.
+func (client *TwentySixClient) GetBalance(address string) (AddressBalanceResponse, error) {
+	balanceEndpoint := AlephApiUrl + client.apiPath("/addresses/") + address + "/balance"
+	request, err := http.NewRequest("GET", balanceEndpoint, nil)
+	if err != nil {
+		return AddressBalanceResponse{}, err
+	}
+
+	request.Header.Add("Accept", "application/json")
+
+	response, err := client.http.Do(request)
+	if err != nil {
+		return AddressBalanceResponse{}, err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return AddressBalanceResponse{}, fmt.Errorf("unable to get the balance of %s: %s", address, response.Status)
+	}
+
+	resultBody, err := io.ReadAll(response.Body)
+	if err != nil {
+		return AddressBalanceResponse{}, err
+	}
+
+	var result AddressBalanceResponse
+	if err := json.Unmarshal(resultBody, &result); err != nil {
+		return AddressBalanceResponse{}, err
+	}
+
+	return result, nil
+}
+
+// GetAccountUsage pages through all the messages of the address to count them and sum
+// the size of its stored files. Results are cached for a minute.
+func (client *TwentySixClient) GetAccountUsage(address string) (TwentySixAccountUsage, error) {
+	accountUsageCache.Lock()
+	cached, ok := accountUsageCache.entries[address]
+	accountUsageCache.Unlock()
+	if ok && time.Now().Before(cached.ExpiresAt) {
+		return cached.Usage, nil
+	}
+
+	usage := TwentySixAccountUsage{Address: address, MessagesByType: map[string]int{}}
+
+	messages, err := client.GetAllMessages(client.ctx, MessageFilter{Addresses: []string{address}})
+	if err != nil {
+		return TwentySixAccountUsage{}, err
+	}
+
+	addMessagesUsage(&usage, messages)
+
+	balance, err := client.GetBalance(address)
+	if err != nil {
+		return TwentySixAccountUsage{}, err
+	}
+
+	usage.HeldAmount = balance.LockedAmount
+	usage.Balance = balance.Balance
+
+	accountUsageCache.Lock()
+	accountUsageCache.entries[address] = cachedAccountUsage{Usage: usage, ExpiresAt: time.Now().Add(usageCacheTTL)}
+	accountUsageCache.Unlock()
+
+	return usage, nil
+}
+
+func addMessagesUsage(usage *TwentySixAccountUsage, messages []Message) {
+	for i := 0; i < len(messages); i++ {
+		usage.TotalMessages++
+		usage.MessagesByType[string(messages[i].Type)]++
+
+		if messages[i].Type != StoreMessageType {
+			continue
+		}
+
+		var content struct {
+			Size int64 `json:"size"`
+		}
+		if err := json.Unmarshal(messages[i].Content, &content); err == nil {
+			usage.StoredFiles++
+			usage.StoredBytes += content.Size
+		}
+	}
+}
+
+// GetAccountUsage is a provider function summarizing the footprint of an account.
+type GetAccountUsage struct{}
+
+type GetAccountUsageArgs struct {
+	Address string `pulumi:"address"`
+}
+
+type GetAccountUsageResult struct {
+	Usage TwentySixAccountUsage `pulumi:"usage"`
+}
+
+func (GetAccountUsage) Call(ctx p.Context, args GetAccountUsageArgs) (GetAccountUsageResult, error) {
+	client := NewConfiguredClient(ctx, TwentySixAccountState{}, "")
+
+	usage, err := client.GetAccountUsage(args.Address)
+	if err != nil {
+		return GetAccountUsageResult{}, err
+	}
+
+	return GetAccountUsageResult{Usage: usage}, nil
+}
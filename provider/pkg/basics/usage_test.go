@@ -0,0 +1,36 @@
+package basics
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAddMessagesUsage(t *testing.T) {
+	usage := TwentySixAccountUsage{MessagesByType: map[string]int{}}
+
+	addMessagesUsage(&usage, []Message{
+		{Type: StoreMessageType, Content: json.RawMessage(`{"item_hash":"a","size":1024}`)},
+		{Type: StoreMessageType, Content: json.RawMessage(`{"item_hash":"b","size":2048}`)},
+		{Type: InstanceMessageType, Content: json.RawMessage(`{"rootfs":{}}`)},
+	})
+
+	if usage.TotalMessages != 3 || usage.MessagesByType["STORE"] != 2 || usage.MessagesByType["INSTANCE"] != 1 {
+		t.Fatalf("unexpected message counts %+v", usage)
+	}
+	if usage.StoredFiles != 2 || usage.StoredBytes != 3072 {
+		t.Fatalf("expected 2 files of 3072 bytes, got %d files of %d bytes", usage.StoredFiles, usage.StoredBytes)
+	}
+}
+
+func TestMinBalanceError(t *testing.T) {
+	balance := AddressBalanceResponse{Balance: 1000, LockedAmount: 950}
+
+	if err := minBalanceError("0xabc", balance, 10); err != nil {
+		t.Fatalf("expected 50 available ALEPH to be enough, got %v", err)
+	}
+
+	err := minBalanceError("0xabc", balance, 100)
+	if err == nil || err.Error() != "account 0xabc has 50 ALEPH available (950 held by its messages), below the required minimum of 100" {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
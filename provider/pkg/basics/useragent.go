@@ -0,0 +1,29 @@
+package basics
+
+import "net/http"
+
+// defaultUserAgent identifies the provider build to Aleph node operators.
+func defaultUserAgent() string {
+	version := ProviderVersion
+	if version == "" {
+		version = "dev"
+	}
+
+	return "pulumi-twentysix/" + version
+}
+
+// userAgentTransport sets the User-Agent header of every request going through it.
+type userAgentTransport struct {
+	userAgent string
+	base      http.RoundTripper
+}
+
+func (transport *userAgentTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	request = request.Clone(request.Context())
+	request.Header.Set("User-Agent", transport.userAgent)
+	return transport.base.RoundTrip(request)
+}
+
+func newUserAgentTransport(userAgent string) http.RoundTripper {
+	return &userAgentTransport{userAgent: userAgent, base: http.DefaultTransport}
+}
@@ -0,0 +1,27 @@
+package basics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUserAgentTransport(t *testing.T) {
+	var userAgent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+	}))
+	defer server.Close()
+
+	client := NewTwentySixClient(TwentySixAccountState{}, "")
+	client.PingEndpoint(server.URL)
+	if userAgent != defaultUserAgent() {
+		t.Fatalf("expected user agent %q, got %q", defaultUserAgent(), userAgent)
+	}
+
+	client.http.Transport = newUserAgentTransport("custom/1.0")
+	client.PingEndpoint(server.URL)
+	if userAgent != "custom/1.0" {
+		t.Fatalf("expected user agent custom/1.0, got %q", userAgent)
+	}
+}
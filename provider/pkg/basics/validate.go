@@ -0,0 +1,129 @@
+package basics
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	p "github.com/pulumi/pulumi-go-provider"
+)
+
+var (
+	ErrItemHashMismatch  = errors.New("item hash does not match the item content")
+	ErrSignatureMismatch = errors.New("signature does not recover the sender address")
+)
+
+// VerifyItemHash checks an inline message item hash is the sha256 of its content.
+// Storage and ipfs items are addressed by their remote content and are not checked.
+func (msg Message) VerifyItemHash() error {
+	if msg.ItemType != InlineMessageItem {
+		return nil
+	}
+
+	contentHash := sha256.Sum256([]byte(msg.ItemContent))
+	expected := hex.EncodeToString(contentHash[:])
+	if msg.ItemHash != expected {
+		return fmt.Errorf("%w: got %s, expected %s", ErrItemHashMismatch, msg.ItemHash, expected)
+	}
+
+	return nil
+}
+
+// VerifySignature checks the message signature recovers the sender address.
+func (msg Message) VerifySignature() error {
+	if msg.Chain == SolanaChain {
+		return msg.verifySolanaSignature()
+	}
+
+	signature, err := hexutil.Decode(msg.Signature)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrSignatureMismatch, err.Error())
+	}
+
+	if len(signature) != crypto.SignatureLength {
+		return fmt.Errorf("%w: invalid signature length %d", ErrSignatureMismatch, len(signature))
+	}
+
+	signature[crypto.RecoveryIDOffset] -= 27
+
+	publicKey, err := crypto.SigToPub(accounts.TextHash(msg.getVerificationPayload()), signature)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrSignatureMismatch, err.Error())
+	}
+
+	recovered := crypto.PubkeyToAddress(*publicKey).Hex()
+	if !strings.EqualFold(recovered, msg.Sender) {
+		return fmt.Errorf("%w: recovered %s, expected %s", ErrSignatureMismatch, recovered, msg.Sender)
+	}
+
+	return nil
+}
+
+// ValidateMessage signs the message with the client account and checks both the
+// signature and the item hash, without broadcasting anything.
+func (client *TwentySixClient) ValidateMessage(msg Message) error {
+	if err := msg.SignMessage(client.account.signingKey()); err != nil {
+		return err
+	}
+
+	if err := msg.VerifySignature(); err != nil {
+		return err
+	}
+
+	return msg.VerifyItemHash()
+}
+
+// ValidateMessage is a provider function dry-running the signature of a message.
+type ValidateMessage struct{}
+
+type ValidateMessageArgs struct {
+	Account     TwentySixAccountState `pulumi:"account"`
+	Channel     string                `pulumi:"channel"`
+	Type        MessageType           `pulumi:"type"`
+	ItemContent string                `pulumi:"itemContent"`
+	ItemHash    string                `pulumi:"itemHash,optional"`
+}
+
+type ValidateMessageResult struct {
+	ItemHash  string `pulumi:"itemHash"`
+	Signature string `pulumi:"signature"`
+	Sender    string `pulumi:"sender"`
+}
+
+func (ValidateMessage) Call(ctx p.Context, args ValidateMessageArgs) (ValidateMessageResult, error) {
+	client := NewConfiguredClient(ctx, args.Account, args.Channel)
+
+	itemHash := args.ItemHash
+	if itemHash == "" {
+		contentHash := sha256.Sum256([]byte(args.ItemContent))
+		itemHash = hex.EncodeToString(contentHash[:])
+	}
+
+	message := Message{
+		Type:        args.Type,
+		Chain:       args.Account.chain(),
+		Sender:      args.Account.Address,
+		Time:        client.now(),
+		Channel:     args.Channel,
+		ItemHash:    itemHash,
+		ItemType:    InlineMessageItem,
+		ItemContent: args.ItemContent,
+	}
+
+	if err := client.ValidateMessage(message); err != nil {
+		return ValidateMessageResult{}, err
+	}
+
+	message.SignMessage(args.Account.signingKey())
+
+	return ValidateMessageResult{
+		ItemHash:  message.ItemHash,
+		Signature: message.Signature,
+		Sender:    message.Sender,
+	}, nil
+}
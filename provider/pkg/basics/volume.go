@@ -4,13 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"time"
-
-	"github.com/gosimple/hashdir"
+	"strings"
 
 	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi-go-provider/infer"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
 )
 
 // Each resource has a controlling struct.
@@ -23,8 +23,16 @@ import (
 // - Delete: Custom logic when the resource is deleted.
 // - Annotate: Describe fields and set defaults for a resource.
 // - WireDependencies: Control how outputs and secrets flows through values.
+//
+// Volumes packing the same folder with the same options, sender and tags within one
+// deployment are uploaded once and share the same STORE message hash.
 type TwentySixVolume struct{}
 
+func (volume *TwentySixVolume) Annotate(a infer.Annotator) {
+	a.Describe(&volume, "A volume stored on aleph by a STORE message: a folder packed into a squashfs image, "+
+		"or a prebuilt image, mounted by instances and functions by its message hash.")
+}
+
 // Each resource has an input struct, defining what arguments it accepts.
 type TwentySixVolumeArgs struct {
 	// Fields projected into Pulumi must be public and hava a `pulumi:"..."` tag.
@@ -32,9 +40,270 @@ type TwentySixVolumeArgs struct {
 	// good idea.
 
 	Account    TwentySixAccountState `pulumi:"account"`
-	Channel    string                `pulumi:"channel"`
-	FolderPath string                `pulumi:"folderPath"`
-	Size       int64                 `pulumi:"size,optional"`
+	Channel    string                `pulumi:"channel,optional"`
+	FolderPath string                `pulumi:"folderPath,optional"`
+
+	// Prebuilt image (squashfs, ext4...) stored as is instead of a squashfs image of
+	// folderPath, exclusive with folderPath.
+	FilePath string `pulumi:"filePath,optional"`
+
+	// Engine the image is uploaded through, "storage" or "ipfs". Defaults to ipfs when
+	// ipfsOptions is set, storage otherwise.
+	StorageEngine MessageItemType `pulumi:"storageEngine,optional"`
+
+	// Add options of the IPFS engine, which pins a CIDv0 by default.
+	IpfsOptions *TwentySixVolumeIpfsOptions `pulumi:"ipfsOptions,optional"`
+
+	// Item type of the stored content, "storage" or "ipfs". It must match the engine,
+	// and defaults to the engine's item type.
+	ItemType MessageItemType `pulumi:"itemType,optional"`
+
+	ReportUploadStats bool `pulumi:"reportUploadStats,optional"`
+
+	// Download the head of the stored content after the upload and check it is a
+	// squashfs image, to catch truncated uploads before a VM fails to mount it.
+	VerifyUpload bool `pulumi:"verifyUpload,optional"`
+
+	// Squashfs block size in bytes, a power of two between 4 KiB and 1 MiB.
+	// Larger blocks suit big sequential files, smaller ones folders of many tiny files.
+	BlockSize int64 `pulumi:"blockSize,optional"`
+
+	// Octal modes (e.g. "0755") forced on the image root directory, directories and files.
+	// When unset the modes of the source folder are preserved.
+	RootMode string `pulumi:"rootMode,optional"`
+	DirMode  string `pulumi:"dirMode,optional"`
+	FileMode string `pulumi:"fileMode,optional"`
+
+	// Disable inode compression, fragment compression or fragment packing of the image.
+	// All of them are enabled by default.
+	NoCompressInodes    bool `pulumi:"noCompressInodes,optional"`
+	NoCompressFragments bool `pulumi:"noCompressFragments,optional"`
+	NoFragments         bool `pulumi:"noFragments,optional"`
+
+	// Tags are stored in the STORE message metadata.
+	Tags map[string]string `pulumi:"tags,optional"`
+
+	// Seconds Delete waits for the message to be forgotten, and between two status polls.
+	// Zero uses the provider configuration.
+	ForgetTimeout  int64 `pulumi:"forgetTimeout,optional"`
+	ForgetInterval int64 `pulumi:"forgetInterval,optional"`
+
+	// Seconds Create waits for aleph to process the message, and between two status polls.
+	// Zero waits up to 120 seconds, polling every 5 seconds.
+	ConfirmationTimeout  int64 `pulumi:"confirmationTimeout,optional"`
+	ConfirmationInterval int64 `pulumi:"confirmationInterval,optional"`
+
+	// Replace the resource on any input change, defaults to the provider strictReplace.
+	StrictReplace *bool `pulumi:"strictReplace,optional"`
+
+	// Refuse to forget the volume while an instance or function still mounts it,
+	// otherwise Delete only warns about it.
+	ProtectReferenced bool `pulumi:"protectReferenced,optional"`
+
+	// Use the message item hash as the resource ID instead of the resource name, so
+	// that a resource can be imported from its hash. The ID is set at creation: a
+	// renamed resource keeps it, and a replaced one gets the hash of its new message.
+	UseMessageHashId bool `pulumi:"useMessageHashId,optional"`
+
+	// Refuse to forget the message on delete. Disable it and update the resource before
+	// destroying it.
+	DeleteProtection bool `pulumi:"deleteProtection,optional"`
+
+	// Pin the operations of the resource to a read node chosen at creation, recorded
+	// in pinnedNode, to read its message back from the node which received it. The
+	// API endpoints are used when the node can't be reached.
+	NodeAffinity bool `pulumi:"nodeAffinity,optional"`
+}
+
+// sourceHash is the hash of the volume source, the folder hash or the sha256 of the
+// prebuilt image.
+func (args TwentySixVolumeArgs) sourceHash() (string, error) {
+	if args.FilePath != "" {
+		return hashFileSha256(args.FilePath)
+	}
+
+	return cachedHashFolder(args.FolderPath)
+}
+
+// checkVolumeSource requires exactly one of folderPath and filePath. Unknown values
+// count as set.
+func checkVolumeSource(inputs resource.PropertyMap) []p.CheckFailure {
+	folder := inputSet(inputs, "folderPath")
+	file := inputSet(inputs, "filePath")
+
+	if folder && file {
+		return []p.CheckFailure{{
+			Property: "filePath",
+			Reason:   "filePath can't be set along with folderPath",
+		}}
+	}
+
+	if !folder && !file {
+		return []p.CheckFailure{{
+			Property: "folderPath",
+			Reason:   "one of folderPath or filePath is required",
+		}}
+	}
+
+	if verify := inputs["verifyUpload"]; file && verify.IsBool() && verify.BoolValue() {
+		return []p.CheckFailure{{
+			Property: "verifyUpload",
+			Reason:   "verifyUpload checks for a squashfs image built from folderPath, it can't be used with filePath",
+		}}
+	}
+
+	return nil
+}
+
+// inputSet reports whether the input has a value or is unknown.
+func inputSet(inputs resource.PropertyMap, key string) bool {
+	input, ok := inputs[resource.PropertyKey(key)]
+	if !ok || input.IsNull() {
+		return false
+	}
+
+	if input.IsString() {
+		return input.StringValue() != ""
+	}
+
+	return true
+}
+
+// storageEngine is the engine the image is uploaded through.
+func (args TwentySixVolumeArgs) storageEngine() MessageItemType {
+	if args.StorageEngine != "" {
+		return args.StorageEngine
+	}
+
+	if args.IpfsOptions != nil {
+		return IpfsMessageItem
+	}
+
+	return StorageMessageItem
+}
+
+// storeItemType is the item type of the stored content, the one of the upload engine
+// unless set explicitly.
+func (args TwentySixVolumeArgs) storeItemType() MessageItemType {
+	if args.ItemType != "" {
+		return args.ItemType
+	}
+
+	return args.storageEngine()
+}
+
+// ipfsAddOptions are the add options of the IPFS engine, the defaults when
+// ipfsOptions is unset.
+func (args TwentySixVolumeArgs) ipfsAddOptions() (IpfsAddOptions, error) {
+	if args.IpfsOptions == nil {
+		return TwentySixVolumeIpfsOptions{}.toAddOptions()
+	}
+
+	return args.IpfsOptions.toAddOptions()
+}
+
+func (args TwentySixVolumeArgs) checkItemType() []p.CheckFailure {
+	failures := []p.CheckFailure{}
+
+	switch args.StorageEngine {
+	case "", IpfsMessageItem:
+	case StorageMessageItem:
+		if args.IpfsOptions != nil {
+			failures = append(failures, p.CheckFailure{
+				Property: "storageEngine",
+				Reason:   "the storage engine can't be used with ipfsOptions, which configure the IPFS engine",
+			})
+		}
+	default:
+		return append(failures, p.CheckFailure{
+			Property: "storageEngine",
+			Reason:   fmt.Sprintf("invalid storage engine %q: expected %q or %q", args.StorageEngine, StorageMessageItem, IpfsMessageItem),
+		})
+	}
+
+	switch args.ItemType {
+	case "":
+	case StorageMessageItem, IpfsMessageItem:
+		if args.ItemType != args.storageEngine() {
+			failures = append(failures, p.CheckFailure{
+				Property: "itemType",
+				Reason:   fmt.Sprintf("item type %s doesn't match the %s storage engine", args.ItemType, args.storageEngine()),
+			})
+		}
+	default:
+		failures = append(failures, p.CheckFailure{
+			Property: "itemType",
+			Reason:   fmt.Sprintf("invalid item type %q: expected %q or %q", args.ItemType, StorageMessageItem, IpfsMessageItem),
+		})
+	}
+
+	return failures
+}
+
+func (args TwentySixVolumeArgs) squashfsOptions() squashfsOptions {
+	return squashfsOptions{
+		BlockSize: args.BlockSize,
+		RootMode:  args.RootMode,
+		DirMode:   args.DirMode,
+		FileMode:  args.FileMode,
+
+		NoCompressInodes:    args.NoCompressInodes,
+		NoCompressFragments: args.NoCompressFragments,
+		NoFragments:         args.NoFragments,
+	}
+}
+
+type TwentySixVolumeIpfsOptions struct {
+	Pin        *bool `pulumi:"pin,optional"`
+	CidVersion int   `pulumi:"cidVersion,optional"`
+	RawLeaves  bool  `pulumi:"rawLeaves,optional"`
+}
+
+func (options TwentySixVolumeIpfsOptions) toAddOptions() (IpfsAddOptions, error) {
+	if options.CidVersion < 0 || options.CidVersion > 1 {
+		return IpfsAddOptions{}, errors.New("ipfs cid version must be 0 or 1")
+	}
+
+	pin := true
+	if options.Pin != nil {
+		pin = *options.Pin
+	}
+
+	return IpfsAddOptions{
+		Pin:        pin,
+		CidVersion: uint64(options.CidVersion),
+		RawLeaves:  options.RawLeaves,
+	}, nil
+}
+
+func (args *TwentySixVolumeArgs) Annotate(a infer.Annotator) {
+	a.Describe(&args.Account, "Account signing the STORE message.")
+	a.Describe(&args.Channel, "Channel of the STORE message.")
+	a.SetDefault(&args.Channel, DefaultChannel)
+	a.Describe(&args.FolderPath, "Folder packed into a squashfs image, exclusive with filePath.")
+	a.Describe(&args.FilePath, "Prebuilt image (squashfs, ext4...) stored as is, exclusive with folderPath.")
+	a.Describe(&args.StorageEngine, "Engine the image is uploaded through, storage or ipfs. ipfs when ipfsOptions is set, storage otherwise.")
+	a.Describe(&args.IpfsOptions, "Add options of the IPFS engine.")
+	a.Describe(&args.ItemType, "Item type of the stored content, storage or ipfs. It must match the engine.")
+	a.Describe(&args.ReportUploadStats, "Report the size, duration and throughput of the upload in uploadStats.")
+	a.Describe(&args.VerifyUpload, "Read back the head of the stored content and check it is a squashfs image.")
+	a.Describe(&args.BlockSize, "Squashfs block size in bytes, a power of two between 4 KiB and 1 MiB.")
+	a.Describe(&args.RootMode, "Octal mode (e.g. 0755) forced on the image root directory.")
+	a.Describe(&args.DirMode, "Octal mode (e.g. 0755) forced on the image directories.")
+	a.Describe(&args.FileMode, "Octal mode (e.g. 0644) forced on the image files.")
+	a.Describe(&args.NoCompressInodes, "Disable inode compression.")
+	a.Describe(&args.NoCompressFragments, "Disable fragment compression.")
+	a.Describe(&args.NoFragments, "Disable fragment packing.")
+	a.Describe(&args.Tags, "Tags stored in the STORE message metadata.")
+	a.Describe(&args.ForgetTimeout, "Seconds Delete waits for the message to be forgotten, 0 uses the provider configuration.")
+	a.Describe(&args.ForgetInterval, "Seconds between two status polls while waiting for the message to be forgotten.")
+	a.Describe(&args.ConfirmationTimeout, "Seconds Create waits for aleph to process the message, 120 when 0.")
+	a.Describe(&args.ConfirmationInterval, "Seconds between two status polls while waiting for the message to be processed, 5 when 0.")
+	a.Describe(&args.StrictReplace, "Replace the volume on any input change, defaults to the provider strictReplace.")
+	a.Describe(&args.ProtectReferenced, "Refuse to forget the volume while an instance or function mounts it.")
+	a.Describe(&args.UseMessageHashId, "Use the message hash as the resource ID, so that volumes can be imported from their hash.")
+	a.Describe(&args.DeleteProtection, "Refuse to forget the message on delete.")
+	a.Describe(&args.NodeAffinity, "Pin the operations of the volume to the read node which received its message.")
 }
 
 // Each resource has a state, describing the fields that exist on the created resource.
@@ -42,91 +311,475 @@ type TwentySixVolumeState struct {
 	// It is generally a good idea to embed args in outputs, but it isn't strictly necessary.
 	TwentySixVolumeArgs
 
-	// Here we define a required output called result.
-	FolderHash  string `pulumi:"folderHash"`
-	FileHash    string `pulumi:"fileHash"`
+	// Hash of the source, the folder hash or the sha256 of filePath.
+	FolderHash string `pulumi:"folderHash"`
+	FileHash   string `pulumi:"fileHash"`
+	// Hash of the image computed locally before the upload, checked against fileHash.
+	// Previews compute it as well for the ipfs engine, along with folderHash.
+	LocalHash   string `pulumi:"localHash,optional"`
 	MessageHash string `pulumi:"messageHash"`
+
+	// Timestamp and channel the message was stamped with.
+	MessageTime    float64 `pulumi:"messageTime"`
+	MessageChannel string  `pulumi:"messageChannel"`
+
+	// Other volumes of the deployment sharing the STORE message, see dedupeVolumeUpload.
+	SharedWith []string `pulumi:"sharedWith,optional"`
+
+	// Why aleph rejected the message, set by a refresh finding it rejected.
+	RejectionReason string `pulumi:"rejectionReason,optional"`
+
+	// ALEPH cost of the resource, read from aleph once the message is accepted.
+	Cost *TwentySixResourceCost `pulumi:"cost,optional"`
+
+	// Nodes which served the message right after it was broadcast, see verifyQuorum.
+	ConfirmedNodes []string `pulumi:"confirmedNodes,optional"`
+
+	UploadStats *TwentySixVolumeUploadStats `pulumi:"uploadStats,optional"`
+
+	// Size in bytes of the squashfs image built locally, and of the content aleph stored
+	// and accounts for. Size is the stored size.
+	Size       int64 `pulumi:"size,optional"`
+	LocalSize  int64 `pulumi:"localSize,optional"`
+	StoredSize int64 `pulumi:"storedSize,optional"`
+
+	// Files packed into the squashfs image, relative to the folder path.
+	Manifest []TwentySixVolumeManifestEntry `pulumi:"manifest"`
+
+	// Read node the resource operations are pinned to, see nodeAffinity.
+	PinnedNode string `pulumi:"pinnedNode,optional"`
+}
+
+func (state *TwentySixVolumeState) Annotate(a infer.Annotator) {
+	a.Describe(&state.Size, "Size in bytes aleph stored and accounts for.")
+	a.Describe(&state.LocalSize, "Size in bytes of the image built locally.")
+	a.Describe(&state.StoredSize, "Size in bytes aleph stored, equal to size.")
+	a.Describe(&state.SharedWith, "Other volumes of the deployment sharing the STORE message, which is forgotten once all of them are deleted.")
+}
+
+type TwentySixVolumeManifestEntry struct {
+	Path string `pulumi:"path"`
+	Size int64  `pulumi:"size"`
+}
+
+type TwentySixVolumeUploadStats struct {
+	Bytes           int64   `pulumi:"bytes"`
+	DurationSeconds float64 `pulumi:"durationSeconds"`
+	BytesPerSecond  float64 `pulumi:"bytesPerSecond"`
 }
 
 // All resources must implement Create at a minimum.
 func (volume TwentySixVolume) Create(ctx p.Context, name string, input TwentySixVolumeArgs, preview bool) (string, TwentySixVolumeState, error) {
+	ctx, cancel := startOperation(ctx, "creating volume "+name)
+	defer cancel()
+
 	state := TwentySixVolumeState{TwentySixVolumeArgs: input}
 	if preview {
+		previewVolumeContent(ctx, name, &state)
 		return name, state, nil
 	}
 
-	if state.FolderPath == "" && !folderExists(state.FolderPath) {
+	if state.FilePath != "" {
+		if !folderExists(state.FilePath) {
+			return "", TwentySixVolumeState{}, errors.New("file doesn't exist")
+		}
+	} else if state.FolderPath == "" && !folderExists(state.FolderPath) {
 		return "", TwentySixVolumeState{}, errors.New("folder dosn't exists")
 	}
 
-	dirHash, err := hashdir.Make(state.FolderPath, "sha256")
+	buildOptions := state.squashfsOptions()
+	if err := buildOptions.validate(); err != nil {
+		return "", TwentySixVolumeState{}, err
+	}
+
+	var addOptions IpfsAddOptions
+	if state.storageEngine() == IpfsMessageItem {
+		options, err := state.ipfsAddOptions()
+		if err != nil {
+			return "", TwentySixVolumeState{}, err
+		}
+		addOptions = options
+	}
+
+	dirHash, err := state.sourceHash()
 	if err != nil {
 		return "", TwentySixVolumeState{}, err
 	}
 
-	filesystemPath := "/tmp/pulumi-squashfs-" + fmt.Sprint(time.Now().Unix()) + ".squashfs"
+	//store volume on aleph
+	client := NewConfiguredClient(ctx, input.Account, state.Channel)
+	state.PinnedNode = pinAffinityNode(ctx, &client, name, input.NodeAffinity)
 
-	// create a new *Cmd instance
-	// here we pass the command as the first argument and the arguments to pass to the command as the
-	// remaining arguments in the function
-	cmd := exec.Command("mksquashfs", state.FolderPath, filesystemPath)
+	if err := checkMinBalance(ctx, &client); err != nil {
+		return "", TwentySixVolumeState{}, err
+	}
 
-	// The `Output` method executes the command and
-	// collects the output, returning its value
-	_, err = cmd.Output()
+	uploadKey, err := volumeUploadKey(input.Account.Address, dirHash, input)
 	if err != nil {
 		return "", TwentySixVolumeState{}, err
 	}
 
-	size, err := FolderSize(filesystemPath)
+	upload, holders, err := dedupeVolumeUpload(uploadKey, name, func() (volumeUploadResult, error) {
+		return buildAndStoreVolume(ctx, &client, state.TwentySixVolumeArgs, dirHash, buildOptions, addOptions)
+	})
 	if err != nil {
 		return "", TwentySixVolumeState{}, err
 	}
 
-	state.Size = size
+	if mismatch := storedSizeMismatch(input.storeItemType(), upload.Size, upload.StoredSize); mismatch != "" {
+		ctx.Logf(diag.Warning, "volume %s: %s, the upload may be incomplete", name, mismatch)
+	}
+
+	// aleph accounts for the stored size, which differs from the image size with ipfs
+	state.Size = upload.StoredSize
+	state.LocalSize = upload.Size
+	state.StoredSize = upload.StoredSize
+	state.Manifest = upload.Manifest
+	state.FolderHash = dirHash
+	state.FileHash = upload.FileHash
+	state.LocalHash = upload.LocalHash
+	state.MessageHash = string(upload.Message.ItemHash)
+	state.MessageTime = upload.Message.Time
+	state.MessageChannel = upload.Message.Channel
+	state.SharedWith = otherVolumeHolders(holders, name)
+	registerCreatedVolume(state.MessageHash, name, holders, input)
 
-	//store volume on aleph
-	client := NewTwentySixClient(input.Account, state.Channel)
-	message, fileHash, err := client.StoreFile(filesystemPath)
-	os.Remove(filesystemPath)
+	if err := waitConfirmation(&client, state.MessageHash, input.ConfirmationTimeout, input.ConfirmationInterval); err != nil {
+		return "", TwentySixVolumeState{}, abandonVolume(ctx, &client, name, state, err)
+	}
+
+	state.ConfirmedNodes, err = verifyBroadcast(ctx, &client, state.MessageHash)
 	if err != nil {
-		return "", TwentySixVolumeState{}, err
+		return "", TwentySixVolumeState{}, abandonVolume(ctx, &client, name, state, err)
+	}
+
+	state.Cost = resourceCost(ctx, &client, state.MessageHash)
+
+	if state.ReportUploadStats {
+		stats := upload.Stats
+		state.UploadStats = &TwentySixVolumeUploadStats{
+			Bytes:           stats.Bytes,
+			DurationSeconds: stats.Duration.Seconds(),
+			BytesPerSecond:  stats.Throughput(),
+		}
+	}
+
+	return resourceId(ctx, name, state.MessageHash, input.UseMessageHashId), state, nil
+}
+
+// buildAndStoreVolume packs the volume folder into a squashfs image, or takes the
+// prebuilt image, and stores it on aleph. A stored image failing its checks has its
+// STORE message forgotten, Pulumi wouldn't track it.
+func buildAndStoreVolume(ctx p.Context, client *TwentySixClient, args TwentySixVolumeArgs, folderHash string, buildOptions squashfsOptions, addOptions IpfsAddOptions) (volumeUploadResult, error) {
+	filesystemPath, cleanup, err := volumeImage(client.buildCacheDir, args, folderHash, buildOptions)
+	if err != nil {
+		return volumeUploadResult{}, err
+	}
+	defer cleanup()
+
+	manifest := []TwentySixVolumeManifestEntry{}
+	if args.FilePath == "" {
+		manifest, err = volumeManifest(args.FolderPath)
+		if err != nil {
+			return volumeUploadResult{}, err
+		}
+	}
+
+	size, err := FolderSize(filesystemPath)
+	if err != nil {
+		return volumeUploadResult{}, err
+	}
+
+	localHash, err := localImageHash(filesystemPath, args, addOptions)
+	if err != nil {
+		return volumeUploadResult{}, err
+	}
+
+	var message Message
+	var stored StoreIPFSFileResponse
+	if args.storageEngine() == IpfsMessageItem {
+		message, stored, err = client.StoreIPFSFile(filesystemPath, addOptions, args.Tags)
+	} else {
+		message, stored, err = client.StoreFile(filesystemPath, args.Tags)
+	}
+	if err != nil {
+		return volumeUploadResult{}, err
+	}
+
+	fileHash := stored.Hash
+
+	if fileHash != localHash {
+		err := fmt.Errorf("stored image hash %s does not match the local hash %s (message %s)", fileHash, localHash, message.ItemHash)
+		return volumeUploadResult{}, forgetFailedStore(ctx, client, args, message.ItemHash, err)
+	}
+
+	if args.VerifyUpload {
+		if err := client.VerifyStoredImage(args.storeItemType(), fileHash); err != nil {
+			return volumeUploadResult{}, forgetFailedStore(ctx, client, args, message.ItemHash, err)
+		}
+	}
+
+	return volumeUploadResult{
+		Message:    message,
+		FileHash:   fileHash,
+		LocalHash:  localHash,
+		Size:       size,
+		StoredSize: int64(stored.Size),
+		Manifest:   manifest,
+		Stats:      client.LastUploadStats(),
+	}, nil
+}
+
+// forgetFailedStore forgets the STORE message of an upload failing with err, and
+// returns err along with the reason the message couldn't be forgotten.
+func forgetFailedStore(ctx p.Context, client *TwentySixClient, args TwentySixVolumeArgs, hash string, err error) error {
+	ctx.Logf(diag.Warning, "forgetting message %s of the failed upload", hash)
+	if forgetErr := forgetAndWait(ctx, client, []string{hash}, args.ForgetTimeout, args.ForgetInterval); forgetErr != nil {
+		return fmt.Errorf("%w, and its message %s couldn't be forgotten: %s", err, hash, forgetErr)
+	}
+
+	return err
+}
+
+// abandonVolume releases the STORE message of a volume failing after it was stored,
+// Pulumi doesn't record the resources whose creation failed. The message is forgotten
+// unless other volumes of the deployment hold it.
+func abandonVolume(ctx p.Context, client *TwentySixClient, name string, state TwentySixVolumeState, err error) error {
+	if volumeMessageHeld(ctx, name, state.MessageHash, state.SharedWith) {
+		return err
+	}
+
+	return forgetFailedStore(ctx, client, state.TwentySixVolumeArgs, state.MessageHash, err)
+}
+
+// volumeMessageHeld releases the STORE message of the volume name and tells whether
+// other volumes sharing it may still hold it, in which case it mustn't be forgotten.
+func volumeMessageHeld(ctx p.Context, name string, hash string, sharedWith []string) bool {
+	holders, err := releaseVolumeMessage(hash, name, sharedWith)
+	if err != nil {
+		ctx.Logf(diag.Warning, "unable to record the release of message %s by volume %s: %s", hash, name, err)
+	}
+	if len(holders) == 0 {
+		return false
+	}
+
+	ctx.Logf(diag.Info, "message %s may still be held by %s, it isn't forgotten until they are deleted", hash, strings.Join(holders, ", "))
+	return true
+}
+
+// volumeImage returns the path of the image to store, the prebuilt file or the
+// squashfs image of the folder, and the cleanup removing a built image.
+func volumeImage(cacheDir string, args TwentySixVolumeArgs, folderHash string, buildOptions squashfsOptions) (string, func(), error) {
+	if args.FilePath != "" {
+		return args.FilePath, func() {}, nil
+	}
+
+	return buildVolumeImage(cacheDir, args.FolderPath, folderHash, buildOptions)
+}
+
+// localImageHash is the hash the node should compute for the image, a CID for the
+// ipfs engine and a sha256 for the storage engine.
+func localImageHash(filesystemPath string, args TwentySixVolumeArgs, addOptions IpfsAddOptions) (string, error) {
+	if args.storageEngine() == IpfsMessageItem {
+		return localCid(filesystemPath, addOptions)
+	}
+
+	return hashFileSha256(filesystemPath)
+}
+
+// previewVolumeContent fills the folder hash of a previewed volume and, for the ipfs
+// engine, the CID of its image, so that the plan shows whether the content changed.
+// The image is built without being uploaded, and kept when a build cache is set.
+// Failures only leave the values unknown, Create reports them.
+func previewVolumeContent(ctx p.Context, name string, state *TwentySixVolumeState) {
+	source := state.FolderPath
+	if state.FilePath != "" {
+		source = state.FilePath
+	}
+	if source == "" || !folderExists(source) {
+		return
 	}
 
+	dirHash, err := state.sourceHash()
+	if err != nil {
+		ctx.Logf(diag.Debug, "volume %s: unable to hash the source during preview: %s", name, err)
+		return
+	}
 	state.FolderHash = dirHash
-	state.FileHash = fileHash
-	state.MessageHash = string(message.ItemHash)
 
-	return name, state, nil
+	if state.storageEngine() != IpfsMessageItem {
+		return
+	}
+
+	buildOptions := state.squashfsOptions()
+	addOptions, err := state.ipfsAddOptions()
+	if err != nil || buildOptions.validate() != nil {
+		return
+	}
+
+	client := NewConfiguredClient(ctx, state.Account, state.Channel)
+	filesystemPath, cleanup, err := volumeImage(client.buildCacheDir, state.TwentySixVolumeArgs, dirHash, buildOptions)
+	if err != nil {
+		ctx.Logf(diag.Debug, "volume %s: unable to build the image during preview: %s", name, err)
+		return
+	}
+	defer cleanup()
+
+	cid, err := localImageHash(filesystemPath, state.TwentySixVolumeArgs, addOptions)
+	if err != nil {
+		ctx.Logf(diag.Debug, "volume %s: unable to compute the image CID during preview: %s", name, err)
+		return
+	}
+	state.LocalHash = cid
+}
+
+func (volume TwentySixVolume) Check(ctx p.Context, name string, oldInputs resource.PropertyMap, newInputs resource.PropertyMap) (TwentySixVolumeArgs, []p.CheckFailure, error) {
+	args, failures, err := infer.DefaultCheck[TwentySixVolumeArgs](newInputs)
+	if err != nil {
+		return args, failures, err
+	}
+
+	if propertyUnset(newInputs, "channel") {
+		args.Channel = DefaultChannel
+	}
+
+	failures = append(failures, checkTags(args.Tags)...)
+	failures = append(failures, checkSecretChannel(newInputs)...)
+	failures = append(failures, args.checkItemType()...)
+	failures = append(failures, checkVolumeSource(newInputs)...)
+
+	return args, failures, nil
+}
+
+func (volume TwentySixVolume) WireDependencies(f infer.FieldSelector, args *TwentySixVolumeArgs, state *TwentySixVolumeState) {
+	wireChannel(f, args, state, &args.Channel, &state.MessageChannel)
+
+	// the content hashes filled by previewVolumeContent are the ones Create stores,
+	// they are shown in the plan instead of being computed
+	if state.FolderHash != "" {
+		f.OutputField(&state.FolderHash).AlwaysKnown()
+	}
+
+	if state.LocalHash != "" {
+		f.OutputField(&state.LocalHash).AlwaysKnown()
+	}
 }
 
 func (volume TwentySixVolume) Diff(ctx p.Context, name string, olds TwentySixVolumeState, news TwentySixVolumeArgs) (p.DiffResponse, error) {
+	ctx, cancel := startOperation(ctx, "diffing volume "+name)
+	defer cancel()
 
-	dirHash, err := hashdir.Make(news.FolderPath, "sha256")
+	dirHash, err := news.sourceHash()
 	if err != nil {
 		return p.DiffResponse{}, err
 	}
 
-	client := NewTwentySixClient(news.Account, news.Channel)
+	client := NewConfiguredClient(ctx, news.Account, news.Channel)
+	client.pinNode(olds.PinnedNode)
 	_, err = client.GetMessageByHash(olds.MessageHash)
-
-	if olds.FolderHash == dirHash && err == nil {
-		return p.DiffResponse{
-			DeleteBeforeReplace: false,
-			HasChanges:          false,
-		}, nil
-	} else {
+	if err != nil {
+		logChanges(ctx, name, []string{"message " + olds.MessageHash + " not found"})
 		return p.DiffResponse{
-			DeleteBeforeReplace: err != nil,
+			DeleteBeforeReplace: true,
 			HasChanges:          true,
+			DetailedDiff: map[string]p.PropertyDiff{
+				"messageHash": {Kind: p.UpdateReplace},
+			},
 		}, nil
 	}
+
+	diff, reasons := volumeDiff(olds, news, dirHash)
+	logChanges(ctx, name, reasons)
+
+	if strictReplaceEnabled(ctx, news.StrictReplace) {
+		return strictDiffResponse(diff), nil
+	}
+
+	response := diffResponse(diff)
+	response.DeleteBeforeReplace = false
+	return response, nil
+}
+
+// volumeDiff compares the inputs recorded in the state, and the hash of their source,
+// with the new inputs. The stored content is immutable, any change but the provider
+// options stores a new volume.
+func volumeDiff(olds TwentySixVolumeState, news TwentySixVolumeArgs, dirHash string) (map[string]p.PropertyDiff, []string) {
+	diff := diffArgs(olds.TwentySixVolumeArgs, news, providerOptionProperties...)
+	reasons := changeReasons(olds.TwentySixVolumeArgs, news)
+
+	if olds.FolderHash != dirHash {
+		source := "folderPath"
+		if news.FilePath != "" {
+			source = "filePath"
+		}
+		diff[source] = p.PropertyDiff{Kind: p.UpdateReplace, InputDiff: true}
+		reasons = append(reasons, "folderHash changed")
+	}
+
+	return diff, reasons
+}
+
+// Update only applies the provider options, any other change stores a new volume.
+func (volume TwentySixVolume) Update(ctx p.Context, name string, olds TwentySixVolumeState, news TwentySixVolumeArgs, preview bool) (TwentySixVolumeState, error) {
+	state := olds
+	state.TwentySixVolumeArgs = news
+	return state, nil
+}
+
+func (volume TwentySixVolume) Read(ctx p.Context, id string, inputs TwentySixVolumeArgs, state TwentySixVolumeState) (string, TwentySixVolumeArgs, TwentySixVolumeState, error) {
+	ctx, cancel := startOperation(ctx, "reading volume "+id)
+	defer cancel()
+
+	// an imported resource only has its ID, the message item hash
+	if state.MessageHash == "" {
+		state.MessageHash = id
+		warnPartialImport(ctx, "volume", id)
+	}
+
+	client := NewConfiguredClient(ctx, state.Account, state.Channel)
+	client.pinNode(state.PinnedNode)
+
+	gone, reason, err := readMessageState(&client, state.MessageHash)
+	if err != nil {
+		return "", TwentySixVolumeArgs{}, TwentySixVolumeState{}, err
+	}
+
+	// an empty ID tells Pulumi the volume no longer exists, the next update creates it again
+	if gone {
+		ctx.Logf(diag.Warning, "message %s of %s was forgotten", state.MessageHash, id)
+		return "", TwentySixVolumeArgs{}, TwentySixVolumeState{}, nil
+	}
+
+	if reason != "" {
+		ctx.Logf(diag.Warning, "message %s was rejected: %s", state.MessageHash, reason)
+	}
+	state.RejectionReason = reason
+
+	return id, inputs, state, nil
 }
 
 func (volume TwentySixVolume) Delete(ctx p.Context, name string, olds TwentySixVolumeState) error {
+	ctx, cancel := startOperation(ctx, "deleting volume "+name)
+	defer cancel()
+
+	if err := checkDeleteProtection(name, olds.DeleteProtection); err != nil {
+		return err
+	}
 
-	client := NewTwentySixClient(olds.Account, olds.Channel)
-	message, err := client.GetMessageByHash(olds.MessageHash)
+	client := NewConfiguredClient(ctx, olds.Account, olds.Channel)
+	client.pinNode(olds.PinnedNode)
+
+	var message Message
+	var err error
+	if olds.MessageHash == "" && olds.FileHash != "" {
+		// the message hash was lost from the state, find the STORE message back from its content
+		message, err = client.GetVolumeByItemHash(olds.FileHash)
+		if err != nil && err.Error() == "volume not found" {
+			return nil
+		}
+	} else {
+		message, err = client.GetMessageByHash(olds.MessageHash)
+	}
 	if err != nil {
 		if err.Error() == "message not found" {
 			return nil
@@ -135,7 +788,17 @@ func (volume TwentySixVolume) Delete(ctx p.Context, name string, olds TwentySixV
 		}
 	}
 
-	_, err = client.ForgetMessage(message.ItemHash)
+	// identical volumes of a deployment share one STORE message, the last one forgets it
+	if volumeMessageHeld(ctx, name, message.ItemHash, olds.SharedWith) {
+		return nil
+	}
+
+	err = checkVolumeConsumers(ctx, &client, message.ItemHash, olds.ProtectReferenced)
+	if err != nil {
+		return err
+	}
+
+	err = forgetAndWait(ctx, &client, []string{message.ItemHash}, olds.ForgetTimeout, olds.ForgetInterval)
 	if err != nil {
 		return err
 	}
@@ -164,3 +827,28 @@ func FolderSize(path string) (int64, error) {
 	})
 	return size, err
 }
+
+// volumeManifest lists the files packed from a folder, in lexical order.
+func volumeManifest(folder string) ([]TwentySixVolumeManifestEntry, error) {
+	manifest := []TwentySixVolumeManifestEntry{}
+	err := filepath.Walk(folder, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relativePath, err := filepath.Rel(folder, path)
+		if err != nil {
+			return err
+		}
+
+		manifest = append(manifest, TwentySixVolumeManifestEntry{
+			Path: filepath.ToSlash(relativePath),
+			Size: info.Size(),
+		})
+		return nil
+	})
+	return manifest, err
+}
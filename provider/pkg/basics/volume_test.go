@@ -0,0 +1,258 @@
+package basics
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	p "github.com/pulumi/pulumi-go-provider"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/diag"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+// testContext is the operation context of resource methods called directly by the tests.
+type testContext struct {
+	context.Context
+}
+
+func newTestContext() testContext {
+	return testContext{context.Background()}
+}
+
+func (testContext) Log(diag.Severity, string)                {}
+func (testContext) Logf(diag.Severity, string, ...any)       {}
+func (testContext) LogStatus(diag.Severity, string)          {}
+func (testContext) LogStatusf(diag.Severity, string, ...any) {}
+func (testContext) RuntimeInformation() p.RunInfo            { return p.RunInfo{} }
+
+// storeServer answers the uploads with storedHash, and records the messages forgotten.
+type storeServer struct {
+	*httptest.Server
+
+	mutex     sync.Mutex
+	forgotten []string
+}
+
+func newStoreServer(t *testing.T, storedHash string) *storeServer {
+	server := &storeServer{}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/storage/add_file"):
+			fmt.Fprintf(w, `{"status":"success","hash":%q,"size":5}`, storedHash)
+		case strings.HasSuffix(r.URL.Path, "/status"):
+			w.Write([]byte(`{"status":"forgotten"}`))
+		case r.Method == "POST":
+			var request BroadcastRequest
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Errorf("unable to decode the broadcast: %s", err)
+			}
+
+			var content ForgetMessageContent
+			json.Unmarshal([]byte(request.Message.ItemContent), &content)
+
+			server.mutex.Lock()
+			server.forgotten = append(server.forgotten, content.Hashes...)
+			server.mutex.Unlock()
+
+			w.Write([]byte(`{"publication_status":{"status":"success"},"message_status":"pending"}`))
+		default:
+			message := Message{ItemHash: "store", Channel: "TEST", ItemContent: fmt.Sprintf(`{"item_hash":%q}`, storedHash)}
+			json.NewEncoder(w).Encode(GetMessageResponse{Messages: []Message{message}, PaginationTotal: 1})
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func (server *storeServer) client(t *testing.T) TwentySixClient {
+	transport, err := newFailoverTransport([]string{server.URL}, NoFailover, 0, http.DefaultTransport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	account := TwentySixAccountState{Address: "0x1a642f0E3c3aF545E7AcBD38b07251B3990914F1"}
+	account.PrivateKey = "0x" + strings.Repeat("01", 32)
+	client := NewTwentySixClient(account, "TEST")
+	client.http.Transport = transport
+	client.readNodes = nil
+
+	return client
+}
+
+func TestVolumeItemType(t *testing.T) {
+	cases := []struct {
+		args     TwentySixVolumeArgs
+		itemType MessageItemType
+		failures int
+	}{
+		{TwentySixVolumeArgs{}, StorageMessageItem, 0},
+		{TwentySixVolumeArgs{IpfsOptions: &TwentySixVolumeIpfsOptions{}}, IpfsMessageItem, 0},
+		{TwentySixVolumeArgs{ItemType: StorageMessageItem}, StorageMessageItem, 0},
+		{TwentySixVolumeArgs{ItemType: IpfsMessageItem, IpfsOptions: &TwentySixVolumeIpfsOptions{}}, IpfsMessageItem, 0},
+		{TwentySixVolumeArgs{ItemType: IpfsMessageItem}, IpfsMessageItem, 1},
+		{TwentySixVolumeArgs{ItemType: StorageMessageItem, IpfsOptions: &TwentySixVolumeIpfsOptions{}}, StorageMessageItem, 1},
+		{TwentySixVolumeArgs{ItemType: InlineMessageItem}, InlineMessageItem, 1},
+		{TwentySixVolumeArgs{StorageEngine: IpfsMessageItem}, IpfsMessageItem, 0},
+		{TwentySixVolumeArgs{StorageEngine: IpfsMessageItem, ItemType: IpfsMessageItem}, IpfsMessageItem, 0},
+		{TwentySixVolumeArgs{StorageEngine: IpfsMessageItem, ItemType: StorageMessageItem}, StorageMessageItem, 1},
+		{TwentySixVolumeArgs{StorageEngine: StorageMessageItem, IpfsOptions: &TwentySixVolumeIpfsOptions{}}, StorageMessageItem, 1},
+		{TwentySixVolumeArgs{StorageEngine: "arweave"}, "arweave", 1},
+	}
+
+	for _, c := range cases {
+		if itemType := c.args.storeItemType(); itemType != c.itemType {
+			t.Errorf("expected item type %q, got %q", c.itemType, itemType)
+		}
+		if failures := c.args.checkItemType(); len(failures) != c.failures {
+			t.Errorf("expected %d failures for item type %q, got %v", c.failures, c.args.ItemType, failures)
+		}
+	}
+}
+
+func TestCheckVolumeSource(t *testing.T) {
+	cases := []struct {
+		inputs   resource.PropertyMap
+		failures int
+	}{
+		{resource.PropertyMap{"folderPath": resource.NewStringProperty("./data")}, 0},
+		{resource.PropertyMap{"filePath": resource.NewStringProperty("./rootfs.ext4")}, 0},
+		{resource.PropertyMap{"filePath": resource.MakeComputed(resource.NewStringProperty(""))}, 0},
+		{resource.PropertyMap{}, 1},
+		{resource.PropertyMap{"folderPath": resource.NewStringProperty("")}, 1},
+		{resource.PropertyMap{"folderPath": resource.NewStringProperty("./data"), "filePath": resource.NewStringProperty("./rootfs.ext4")}, 1},
+		{resource.PropertyMap{"filePath": resource.NewStringProperty("./rootfs.ext4"), "verifyUpload": resource.NewBoolProperty(true)}, 1},
+	}
+
+	for i, c := range cases {
+		if failures := checkVolumeSource(c.inputs); len(failures) != c.failures {
+			t.Errorf("case %d: expected %d failures, got %v", i, c.failures, failures)
+		}
+	}
+}
+
+func TestVolumeCheckDefaults(t *testing.T) {
+	inputs := resource.PropertyMap{
+		"account":    resource.NewObjectProperty(resource.PropertyMap{"address": resource.NewStringProperty("0x0"), "publicKey": resource.NewStringProperty("0x0")}),
+		"folderPath": resource.NewStringProperty("./data"),
+	}
+
+	args, failures, err := TwentySixVolume{}.Check(nil, "volume", nil, inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(failures) != 0 {
+		t.Fatalf("unexpected failures %v", failures)
+	}
+	if args.Channel != DefaultChannel {
+		t.Errorf("expected channel %q, got %q", DefaultChannel, args.Channel)
+	}
+
+	inputs["channel"] = resource.NewStringProperty("TEST")
+	if args, _, _ = (TwentySixVolume{}).Check(nil, "volume", nil, inputs); args.Channel != "TEST" {
+		t.Errorf("expected channel TEST, got %q", args.Channel)
+	}
+}
+
+func TestVolumeDiffAfterCreate(t *testing.T) {
+	args := TwentySixVolumeArgs{Channel: "TEST", FolderPath: "./data"}
+
+	// the outputs Create sets must not read as input changes on the next deployment
+	olds := TwentySixVolumeState{TwentySixVolumeArgs: args, FolderHash: "folder"}
+	olds.Size = 4096
+	olds.LocalSize = 4096
+	olds.StoredSize = 4096
+
+	if diff, reasons := volumeDiff(olds, args, "folder"); len(diff) != 0 || len(reasons) != 0 {
+		t.Fatalf("expected no changes, got %v %v", diff, reasons)
+	}
+
+	if diff, _ := volumeDiff(olds, args, "changed"); diff["folderPath"].Kind != p.UpdateReplace {
+		t.Fatalf("expected a replacement of folderPath, got %v", diff)
+	}
+}
+
+func TestVolumeImageFromFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "rootfs.ext4")
+	if err := os.WriteFile(filePath, []byte("prebuilt image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	args := TwentySixVolumeArgs{FilePath: filePath}
+	imagePath, cleanup, err := volumeImage("", args, "", squashfsOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cleanup()
+
+	if imagePath != filePath {
+		t.Fatalf("expected the prebuilt image %s to be stored as is, got %s", filePath, imagePath)
+	}
+	if _, err := os.Stat(filePath); err != nil {
+		t.Fatalf("expected the prebuilt image to be kept: %s", err)
+	}
+
+	hash, err := args.sourceHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("prebuilt image"))
+	if hash != hex.EncodeToString(sum[:]) {
+		t.Fatalf("expected the sha256 of the file as source hash, got %s", hash)
+	}
+}
+
+func TestBuildAndStoreVolumeForgetsMismatch(t *testing.T) {
+	server := newStoreServer(t, "mismatch")
+	client := server.client(t)
+
+	image := filepath.Join(t.TempDir(), "image.squashfs")
+	if err := os.WriteFile(image, []byte("image"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	args := TwentySixVolumeArgs{FilePath: image, Channel: "TEST"}
+	_, err := buildAndStoreVolume(newTestContext(), &client, args, "", squashfsOptions{}, IpfsAddOptions{})
+	if err == nil || !strings.Contains(err.Error(), "does not match the local hash") {
+		t.Fatalf("expected a hash mismatch, got %v", err)
+	}
+
+	if len(server.forgotten) != 1 || server.forgotten[0] != "store" {
+		t.Fatalf("expected the STORE message of the failed upload to be forgotten, got %v", server.forgotten)
+	}
+}
+
+func TestAbandonVolume(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+
+	server := newStoreServer(t, "image")
+	client := server.client(t)
+	failure := errors.New("confirmation timeout")
+
+	state := TwentySixVolumeState{MessageHash: "store", SharedWith: []string{"second"}}
+	if err := abandonVolume(newTestContext(), &client, "first", state, failure); !errors.Is(err, failure) {
+		t.Fatalf("expected the creation error, got %v", err)
+	}
+	if len(server.forgotten) != 0 {
+		t.Fatalf("expected the message held by another volume to be kept, got %v", server.forgotten)
+	}
+
+	state.SharedWith = []string{"first"}
+	if err := abandonVolume(newTestContext(), &client, "second", state, failure); !errors.Is(err, failure) {
+		t.Fatalf("expected the creation error, got %v", err)
+	}
+	if len(server.forgotten) != 1 || server.forgotten[0] != "store" {
+		t.Fatalf("expected the message of the last holder to be forgotten, got %v", server.forgotten)
+	}
+}
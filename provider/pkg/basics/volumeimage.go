@@ -0,0 +1,159 @@
+package basics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/diskfs/go-diskfs/filesystem/squashfs"
+	p "github.com/pulumi/pulumi-go-provider"
+)
+
+type TwentySixVolumeImageEntry struct {
+	Path  string `pulumi:"path"`
+	Size  int64  `pulumi:"size"`
+	Mode  string `pulumi:"mode"`
+	IsDir bool   `pulumi:"isDir"`
+}
+
+// DownloadVolumeImage fetches the squashfs image stored by a volume STORE message into
+// destination, from the aleph storage or an IPFS gateway depending on its item type.
+func (client *TwentySixClient) DownloadVolumeImage(messageHash string, destination string) (StoreMessageContent, error) {
+	message, err := client.GetMessageByHash(messageHash)
+	if err != nil {
+		return StoreMessageContent{}, err
+	}
+
+	if message.Type != StoreMessageType {
+		return StoreMessageContent{}, fmt.Errorf("message %s is a %s message, not a volume", messageHash, message.Type)
+	}
+
+	var content StoreMessageContent
+	if err := json.Unmarshal([]byte(message.ItemContent), &content); err != nil {
+		return StoreMessageContent{}, err
+	}
+
+	if content.ItemType == IpfsMessageItem {
+		_, err = client.DownloadFile(content.ItemHash, destination)
+	} else {
+		err = client.downloadFrom(AlephApiUrl+client.apiPath("/storage/raw/")+content.ItemHash, destination)
+	}
+	if err != nil {
+		return StoreMessageContent{}, err
+	}
+
+	return content, nil
+}
+
+// listSquashfsImage walks a squashfs image and lists its entries, directories first
+// then their content, with paths relative to the image root.
+func listSquashfsImage(imagePath string) ([]TwentySixVolumeImageEntry, error) {
+	file, err := os.Open(imagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	fs, err := squashfs.Read(file, info.Size(), 0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid squashfs image: %w", err)
+	}
+
+	entries := []TwentySixVolumeImageEntry{}
+	return listSquashfsDirectory(fs, "/", entries)
+}
+
+func listSquashfsDirectory(fs *squashfs.FileSystem, dir string, entries []TwentySixVolumeImageEntry) ([]TwentySixVolumeImageEntry, error) {
+	infos, err := fs.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < len(infos); i++ {
+		entryPath := path.Join(dir, infos[i].Name())
+		entries = append(entries, TwentySixVolumeImageEntry{
+			Path:  entryPath[1:],
+			Size:  infos[i].Size(),
+			Mode:  infos[i].Mode().String(),
+			IsDir: infos[i].IsDir(),
+		})
+
+		if infos[i].IsDir() {
+			entries, err = listSquashfsDirectory(fs, entryPath, entries)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// MountVolumeImage is a provider function downloading the squashfs image of a volume
+// and listing its content, to inspect what a deployed volume holds.
+type MountVolumeImage struct{}
+
+type MountVolumeImageArgs struct {
+	MessageHash string `pulumi:"messageHash"`
+	// Directory the image is downloaded to, the system temporary directory by default.
+	Destination string `pulumi:"destination,optional"`
+}
+
+type MountVolumeImageResult struct {
+	// Local path of the downloaded image, e.g. for `mount -o loop` or `unsquashfs`.
+	Path     string                      `pulumi:"path"`
+	FileHash string                      `pulumi:"fileHash"`
+	ItemType MessageItemType             `pulumi:"itemType"`
+	Size     int64                       `pulumi:"size"`
+	Entries  []TwentySixVolumeImageEntry `pulumi:"entries"`
+}
+
+func (MountVolumeImage) Call(ctx p.Context, args MountVolumeImageArgs) (MountVolumeImageResult, error) {
+	if args.MessageHash == "" {
+		return MountVolumeImageResult{}, errors.New("messageHash is required")
+	}
+
+	destination := args.Destination
+	if destination == "" {
+		destination = os.TempDir()
+	}
+
+	if err := os.MkdirAll(destination, 0o755); err != nil {
+		return MountVolumeImageResult{}, err
+	}
+
+	imagePath := filepath.Join(destination, args.MessageHash+".squashfs")
+
+	client := NewConfiguredClient(ctx, TwentySixAccountState{}, "")
+	content, err := client.DownloadVolumeImage(args.MessageHash, imagePath)
+	if err != nil {
+		return MountVolumeImageResult{}, err
+	}
+
+	info, err := os.Stat(imagePath)
+	if err != nil {
+		return MountVolumeImageResult{}, err
+	}
+
+	entries, err := listSquashfsImage(imagePath)
+	if err != nil {
+		return MountVolumeImageResult{}, err
+	}
+
+	return MountVolumeImageResult{
+		Path:     imagePath,
+		FileHash: content.ItemHash,
+		ItemType: content.ItemType,
+		Size:     info.Size(),
+		Entries:  entries,
+	}, nil
+}
@@ -0,0 +1,63 @@
+package basics
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/diskfs/go-diskfs/filesystem/squashfs"
+)
+
+func TestListSquashfsImage(t *testing.T) {
+	imagePath := filepath.Join(t.TempDir(), "volume.squashfs")
+
+	file, err := os.Create(imagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	fs, err := squashfs.Create(file, 0, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Mkdir("/lib"); err != nil {
+		t.Fatal(err)
+	}
+	content, err := fs.OpenFile("/lib/module.py", os.O_CREATE|os.O_RDWR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := content.Write([]byte("print('hello')\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Finalize(squashfs.FinalizeOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := listSquashfsImage(imagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %v", entries)
+	}
+	if entries[0].Path != "lib" || !entries[0].IsDir {
+		t.Fatalf("expected the lib directory first, got %+v", entries[0])
+	}
+	if entries[1].Path != "lib/module.py" || entries[1].Size != 15 || entries[1].IsDir {
+		t.Fatalf("expected lib/module.py of 15 bytes, got %+v", entries[1])
+	}
+}
+
+func TestListSquashfsImageInvalid(t *testing.T) {
+	imagePath := filepath.Join(t.TempDir(), "volume.squashfs")
+	if err := os.WriteFile(imagePath, []byte("not a squashfs image"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := listSquashfsImage(imagePath); err == nil {
+		t.Fatal("expected an error for an invalid image")
+	}
+}
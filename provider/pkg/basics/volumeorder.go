@@ -0,0 +1,48 @@
+package basics
+
+import (
+	"path"
+	"sort"
+)
+
+// canonicalVolumes returns the volumes of a VM sorted by mount path, so that
+// reordering them in a program doesn't change the message content and its hash.
+//
+// The order is only significant for nested mounts: a volume mounted on /data
+// must be mounted before one on /data/cache. A path always sorts before the
+// paths it prefixes, so sorting keeps parents ahead of their children. Volumes
+// without a mount path keep their relative order after the mounted ones.
+func canonicalVolumes(volumes []interface{}) []interface{} {
+	if volumes == nil {
+		return nil
+	}
+
+	sorted := make([]interface{}, len(volumes))
+	copy(sorted, volumes)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		left, leftOk := volumeMount(sorted[i])
+		right, rightOk := volumeMount(sorted[j])
+		if !leftOk || !rightOk {
+			return leftOk && !rightOk
+		}
+		return left < right
+	})
+
+	return sorted
+}
+
+// volumeMount returns the cleaned mount path of a volume entry.
+func volumeMount(volume interface{}) (string, bool) {
+	entry, ok := volume.(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+
+	mount, ok := entry["mount"].(string)
+	if !ok || mount == "" {
+		return "", false
+	}
+
+	return path.Clean(mount), true
+}
@@ -0,0 +1,30 @@
+package basics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalVolumes(t *testing.T) {
+	data := map[string]interface{}{"mount": "/data", "ref": "a"}
+	cache := map[string]interface{}{"mount": "/data/cache", "ref": "b"}
+	logs := map[string]interface{}{"mount": "/var/log/", "ref": "c"}
+	ephemeral := map[string]interface{}{"ephemeral": true, "size_mib": 10}
+
+	volumes := []interface{}{cache, ephemeral, logs, data}
+	expected := []interface{}{data, cache, logs, ephemeral}
+
+	sorted := canonicalVolumes(volumes)
+	if !reflect.DeepEqual(sorted, expected) {
+		t.Fatalf("expected %v, got %v", expected, sorted)
+	}
+	if !reflect.DeepEqual(canonicalVolumes([]interface{}{logs, data, ephemeral, cache}), expected) {
+		t.Fatal("expected reordered volumes to have the same canonical order")
+	}
+	if !reflect.DeepEqual(volumes[0], cache) {
+		t.Fatal("expected the input volumes to be left untouched")
+	}
+	if canonicalVolumes(nil) != nil {
+		t.Fatal("expected nil volumes to stay nil")
+	}
+}
@@ -0,0 +1,88 @@
+package basics
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// WaitOptions control how the wait helpers poll until a condition holds.
+type WaitOptions struct {
+	// Give up once Timeout elapsed since the first attempt.
+	Timeout time.Duration
+	// Delay between two attempts, multiplied by Backoff after each attempt up to
+	// MaxInterval. A Backoff of 0 or 1 keeps a fixed interval.
+	Interval    time.Duration
+	MaxInterval time.Duration
+	Backoff     float64
+	// Random delay of up to Jitter added to every sleep, and waited before the first
+	// attempt, so that concurrent waits don't poll in lockstep.
+	Jitter time.Duration
+}
+
+var errWaitTimeout = errors.New("wait timeout")
+
+// delay is the sleep after an attempt made with the given interval.
+func (options WaitOptions) delay(interval time.Duration) time.Duration {
+	if options.Jitter > 0 {
+		interval += time.Duration(rand.Int63n(int64(options.Jitter)))
+	}
+
+	return interval
+}
+
+// next is the interval following the given one.
+func (options WaitOptions) next(interval time.Duration) time.Duration {
+	if options.Backoff > 1 {
+		interval = time.Duration(float64(interval) * options.Backoff)
+	}
+
+	if options.MaxInterval > 0 && interval > options.MaxInterval {
+		interval = options.MaxInterval
+	}
+
+	return interval
+}
+
+// waitUntil calls check until it reports done, fails, or the timeout elapses, in which
+// case errWaitTimeout is returned for the caller to translate. It stops early with the
+// operation error once ctx is done.
+func waitUntil(ctx context.Context, options WaitOptions, check func() (bool, error)) error {
+	deadline := time.Now().Add(options.Timeout)
+
+	if err := sleepContext(ctx, options.delay(0)); err != nil {
+		return err
+	}
+
+	interval := options.Interval
+	for {
+		if err := operationError(ctx); err != nil {
+			return err
+		}
+
+		done, err := check()
+		if err != nil {
+			return err
+		}
+		if done {
+			return nil
+		}
+
+		if time.Now().After(deadline) {
+			return errWaitTimeout
+		}
+
+		if err := sleepContext(ctx, options.delay(interval)); err != nil {
+			return err
+		}
+		interval = options.next(interval)
+	}
+}
+
+func secondsWaitOptions(timeout int64, interval int64) WaitOptions {
+	return WaitOptions{
+		Timeout:  time.Duration(timeout) * time.Second,
+		Interval: time.Duration(interval) * time.Second,
+	}
+}
@@ -28,14 +28,31 @@ var Version string
 const Name string = "twentysix"
 
 func Provider() p.Provider {
+	basics.ProviderVersion = Version
+
 	// We tell the provider what resources it needs to support.
 	// In this case, a single custom resource.
 	return infer.Provider(infer.Options{
 		Resources: []infer.InferredResource{
 			infer.Resource[basics.TwentySixAccount, basics.TwentySixAccountArgs, basics.TwentySixAccountState](),
+			infer.Resource[basics.TwentySixAccountSet, basics.TwentySixAccountSetArgs, basics.TwentySixAccountSetState](),
 			infer.Resource[basics.TwentySixVolume, basics.TwentySixVolumeArgs, basics.TwentySixVolumeState](),
 			infer.Resource[basics.TwentySixInstance, basics.TwentySixInstanceArgs, basics.TwentySixInstanceState](),
+			infer.Resource[basics.TwentySixAggregate, basics.TwentySixAggregateArgs, basics.TwentySixAggregateState](),
+		},
+		Functions: []infer.InferredFunction{
+			infer.Function[basics.RebootInstance, basics.RebootInstanceArgs, basics.RebootInstanceResult](),
+			infer.Function[basics.ValidateMessage, basics.ValidateMessageArgs, basics.ValidateMessageResult](),
+			infer.Function[basics.ExportKeystore, basics.ExportKeystoreArgs, basics.ExportKeystoreResult](),
+			infer.Function[basics.Ping, basics.PingArgs, basics.PingResult](),
+			infer.Function[basics.GetAccountUsage, basics.GetAccountUsageArgs, basics.GetAccountUsageResult](),
+			infer.Function[basics.MountVolumeImage, basics.MountVolumeImageArgs, basics.MountVolumeImageResult](),
+			infer.Function[basics.ListChannels, basics.ListChannelsArgs, basics.ListChannelsResult](),
+			infer.Function[basics.GetImportSpecs, basics.GetImportSpecsArgs, basics.GetImportSpecsResult](),
+			infer.Function[basics.GetAggregateHistory, basics.GetAggregateHistoryArgs, basics.GetAggregateHistoryResult](),
+			infer.Function[basics.GetInstanceLogs, basics.GetInstanceLogsArgs, basics.GetInstanceLogsResult](),
 		},
+		Config: infer.Config[basics.TwentySixConfig](),
 		ModuleMap: map[tokens.ModuleName]tokens.ModuleName{
 			"provider": "index",
 		},
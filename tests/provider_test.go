@@ -155,3 +155,61 @@ func urn(typ string) resource.URN {
 func provider() integration.Server {
 	return integration.NewServer(twentysix.Name, semver.MustParse("1.0.0"), twentysix.Provider())
 }
+
+func TestSecretChannelOutputs(t *testing.T) {
+	prov := provider()
+
+	for _, typ := range []string{"twentysix:basics:TwentySixVolume", "twentysix:basics:TwentySixInstance"} {
+		for _, secret := range []bool{true, false} {
+			channel := resource.NewStringProperty("SECRET-CHANNEL")
+			if secret {
+				channel = resource.MakeSecret(channel)
+			}
+
+			created, err := prov.Create(p.CreateRequest{
+				Urn: urn(typ),
+				Properties: resource.PropertyMap{
+					"account": resource.NewObjectProperty(resource.PropertyMap{"address": resource.NewStringProperty("0xabc")}),
+					"channel": channel,
+				},
+				Preview: true,
+			})
+
+			require.NoError(t, err)
+			assert.Equal(t, secret, created.Properties["messageChannel"].IsSecret(), "%s messageChannel secret", typ)
+		}
+	}
+}
+
+func TestPreviewVolumeContentHashes(t *testing.T) {
+	prov := provider()
+
+	folder := t.TempDir()
+	require.NoError(t, os.WriteFile(folder+"/index.html", []byte("hello"), 0o644))
+
+	for _, engine := range []string{"storage", "ipfs"} {
+		created, err := prov.Create(p.CreateRequest{
+			Urn: urn("twentysix:basics:TwentySixVolume"),
+			Properties: resource.PropertyMap{
+				"account":       resource.NewObjectProperty(resource.PropertyMap{"address": resource.NewStringProperty("0xabc")}),
+				"channel":       resource.NewStringProperty("TEST"),
+				"folderPath":    resource.NewStringProperty(folder),
+				"storageEngine": resource.NewStringProperty(engine),
+			},
+			Preview: true,
+		})
+
+		require.NoError(t, err)
+		folderHash := created.Properties["folderHash"]
+		require.True(t, folderHash.IsString(), "%s: folderHash must be known in preview, got %v", engine, folderHash)
+		assert.Len(t, folderHash.StringValue(), 64)
+
+		if engine == "ipfs" {
+			localHash := created.Properties["localHash"]
+			require.True(t, localHash.IsString(), "localHash must be known in preview, got %v", localHash)
+			assert.NotEmpty(t, localHash.StringValue())
+		}
+
+		assert.True(t, created.Properties["messageHash"].IsComputed(), "%s: messageHash must stay computed", engine)
+	}
+}